@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"quote-service/internal/service"
+)
+
+const keysUsage = `Использование:
+  quote-service keys issue -name <имя> -scopes read,write,admin [-roles contributor,moderator,admin]
+  quote-service keys list
+  quote-service keys revoke -id <id>
+`
+
+// runKeys выполняет административную команду управления API-ключами и возвращает код выхода
+func runKeys(ctx context.Context, keys *service.APIKeyService, args []string, out io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(out, keysUsage)
+		return 2
+	}
+
+	switch args[0] {
+	case "issue":
+		fs := flag.NewFlagSet("keys issue", flag.ContinueOnError)
+		fs.SetOutput(out)
+		name := fs.String("name", "", "имя владельца ключа")
+		scopes := fs.String("scopes", "read", "области доступа через запятую: read, write, admin")
+		roles := fs.String("roles", "", "роли через запятую: contributor, moderator, admin (по умолчанию выводятся из областей)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		plain, key, err := keys.Issue(ctx, *name, splitList(*scopes), splitList(*roles))
+		if err != nil {
+			fmt.Fprintf(out, "Ошибка выдачи ключа: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(out, "Ключ #%d (%s) выдан с областями %s\n", key.ID, key.Name, strings.Join(key.Scopes, ","))
+		fmt.Fprintf(out, "Сохраните ключ, он больше не будет показан:\n%s\n", plain)
+		return 0
+
+	case "list":
+		list, err := keys.List(ctx)
+		if err != nil {
+			fmt.Fprintf(out, "Ошибка получения ключей: %v\n", err)
+			return 1
+		}
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSCOPES\tROLES\tCREATED\tREVOKED")
+		for _, key := range list {
+			revoked := "-"
+			if key.RevokedAt != nil {
+				revoked = key.RevokedAt.Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix,
+				strings.Join(key.Scopes, ","), strings.Join(key.Roles, ","), key.CreatedAt.Format("2006-01-02 15:04"), revoked)
+		}
+		tw.Flush()
+		return 0
+
+	case "revoke":
+		fs := flag.NewFlagSet("keys revoke", flag.ContinueOnError)
+		fs.SetOutput(out)
+		id := fs.Int("id", 0, "ID ключа")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if err := keys.Revoke(ctx, *id); err != nil {
+			fmt.Fprintf(out, "Ошибка отзыва ключа: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(out, "Ключ #%d отозван\n", *id)
+		return 0
+	}
+
+	fmt.Fprint(out, keysUsage)
+	return 2
+}
+
+// splitList разбирает список значений через запятую
+func splitList(raw string) []string {
+	var list []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -2,21 +2,31 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"quote-service/internal/api/gql"
+	"quote-service/internal/api/grpcapi"
 	v1 "quote-service/internal/api/v1"
+	"quote-service/internal/api/ws"
+	"quote-service/internal/auth"
 	repoPostgres "quote-service/internal/repository/postgres"
+	"quote-service/internal/service"
 	quoteshttp "quote-service/pkg/http"
 	"quote-service/pkg/logger"
 	"quote-service/pkg/postgres"
+	"quote-service/pkg/ratelimit"
 	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -49,13 +59,285 @@ func main() {
 	defer db.Close()
 
 	// Инициализация репозитория
-	storage := repoPostgres.NewStorage(db.DB)
+	viper.SetDefault("outbox.enabled", true)
+	viper.SetDefault("outbox.poll_interval", 5*time.Second)
+	viper.SetDefault("outbox.retention", 24*time.Hour)
+	var storageOpts []repoPostgres.StorageOption
+	if viper.GetBool("outbox.enabled") {
+		storageOpts = append(storageOpts, repoPostgres.WithOutbox())
+	}
+	storage := repoPostgres.NewStorage(db.DB, storageOpts...)
+	apiKeys := service.NewAPIKeyService(storage)
+
+	// Административные команды: quote-service keys ...
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		code := runKeys(context.Background(), apiKeys, os.Args[2:], os.Stdout)
+		db.Close()
+		os.Exit(code)
+	}
+	// Полная переиндексация слов цитат: quote-service terms reindex
+	if len(os.Args) > 1 && os.Args[1] == "terms" {
+		code := runTerms(context.Background(), storage, os.Args[2:], os.Stdout)
+		db.Close()
+		os.Exit(code)
+	}
+
+	// Цитаты, сохраненные до появления индекса слов, индексируются тем же разбором текста, что и новые
+	if n, err := storage.BackfillTerms(context.Background(), false); err != nil {
+		logger.Error("Ошибка заполнения индекса слов", zap.Error(err))
+	} else if n > 0 {
+		logger.Info("Индекс слов заполнен", zap.Int("quotes", n))
+	}
+
+	// Счетчики выдач цитат копятся в памяти и сбрасываются в БД в фоне
+	viper.SetDefault("views.flush_interval", 10*time.Second)
+	views := service.NewViewCounter(storage)
+	viewsCtx, stopViews := context.WithCancel(context.Background())
+	viewsDone := make(chan struct{})
+	go func() {
+		defer close(viewsDone)
+		views.Run(viewsCtx, viper.GetDuration("views.flush_interval"))
+	}()
 
 	// Инициализация роутера
 	r := chi.NewRouter()
+	// request_id попадает в ответы об ошибках и в журнал
+	r.Use(middleware.RequestID)
+
+	viper.SetDefault("rate_limit.enabled", true)
+	viper.SetDefault("rate_limit.ip.rate", 50)
+	viper.SetDefault("rate_limit.ip.burst", 100)
+	// Лимиты общие для HTTP и gRPC API. Лимит по IP стоит до аутентификации,
+	// чтобы ограничить и запросы с неверными ключами.
+	var grpcLimits []grpcapi.Option
+	if viper.GetBool("rate_limit.enabled") && viper.GetFloat64("rate_limit.ip.rate") > 0 {
+		ipLimiter := v1.NewIPRateLimiter(rateLimitConfig("ip"), logger)
+		r.Use(ipLimiter.Handler)
+		grpcLimits = append(grpcLimits, grpcapi.WithIPRateLimit(ipLimiter))
+	}
+
+	viper.SetDefault("auth.enabled", true)
+	viper.SetDefault("auth.public_reads", true)
+	// tokens используется и HTTP, и gRPC API
+	var tokens v1.TokenVerifier
+	if viper.GetBool("auth.enabled") {
+		var authOpts []v1.AuthOption
+		if viper.GetBool("auth.jwt.enabled") {
+			verifier, err := newJWTVerifier()
+			if err != nil {
+				logger.Fatal("Ошибка загрузки JWKS", zap.Error(err))
+			}
+			tokens = verifier
+			authOpts = append(authOpts, v1.WithBearerTokens(verifier))
+		}
+		r.Use(v1.NewAuthMiddleware(apiKeys, viper.GetBool("auth.public_reads"), logger, authOpts...).Handler)
+	}
+
+	viper.SetDefault("rate_limit.read.rate", 20)
+	viper.SetDefault("rate_limit.read.burst", 40)
+	viper.SetDefault("rate_limit.write.rate", 2)
+	viper.SetDefault("rate_limit.write.burst", 10)
+	if viper.GetBool("rate_limit.enabled") {
+		limiter := v1.NewRateLimiter(rateLimitConfig("read"), rateLimitConfig("write"), logger)
+		r.Use(limiter.Handler)
+		grpcLimits = append(grpcLimits, grpcapi.WithRateLimit(limiter))
+	}
 
-	handler := v1.NewHandler(storage, logger)
+	viper.SetDefault("duplicates.threshold", 0.8)
+	viper.SetDefault("content_filter.enabled", true)
+	viper.SetDefault("content_filter.min_length", 3)
+	viper.SetDefault("content_filter.max_length", 1000)
+	viper.SetDefault("content_filter.max_author_length", 255)
+	viper.SetDefault("content_filter.spam", true)
+	serviceOpts := []service.Option{
+		service.WithDuplicateDetection(storage, viper.GetFloat64("duplicates.threshold")),
+		service.WithLoaders(storage),
+	}
+	if viper.GetBool("content_filter.enabled") {
+		rules, err := contentRules()
+		if err != nil {
+			logger.Fatal("Ошибка загрузки фильтра содержимого", zap.Error(err))
+		}
+		serviceOpts = append(serviceOpts, service.WithContentFilter(rules...))
+	}
+	if viper.GetBool("auth.enabled") {
+		// Роли и владельцы цитат проверяются только для аутентифицированных клиентов
+		serviceOpts = append(serviceOpts, service.WithPolicy(service.RolePolicy{}))
+	}
+	handlerOpts := []v1.Option{
+		v1.WithRatings(storage),
+		v1.WithViews(views),
+		v1.WithSimilarity(storage),
+		v1.WithServiceOptions(serviceOpts...),
+	}
+	// Без публичного чтения списки нельзя отдавать из общих кэшей (CDN)
+	viper.SetDefault("http_cache.max_age", time.Minute)
+	handlerOpts = append(handlerOpts, v1.WithListCaching(viper.GetDuration("http_cache.max_age"),
+		!viper.GetBool("auth.enabled") || viper.GetBool("auth.public_reads")))
+	viper.SetDefault("moderation.enabled", true)
+	// Без аутентификации модератора не отличить от автора, и одобрить цитату мог бы любой
+	moderation := viper.GetBool("moderation.enabled")
+	if moderation && !viper.GetBool("auth.enabled") {
+		logger.Warn("Модерация отключена: она требует auth.enabled")
+		moderation = false
+	}
+	if moderation {
+		handlerOpts = append(handlerOpts, v1.WithModeration(storage))
+	}
+	viper.SetDefault("idempotency.enabled", true)
+	viper.SetDefault("idempotency.ttl", 24*time.Hour)
+	viper.SetDefault("idempotency.lease", time.Minute)
+	if viper.GetBool("idempotency.enabled") {
+		handlerOpts = append(handlerOpts, v1.WithIdempotency(storage,
+			viper.GetDuration("idempotency.ttl"), viper.GetDuration("idempotency.lease")))
+	}
+	viper.SetDefault("stream.enabled", true)
+	viper.SetDefault("stream.history", 1000)
+	viper.SetDefault("stream.client_buffer", 64)
+	viper.SetDefault("stream.heartbeat", 15*time.Second)
+	viper.SetDefault("websocket.enabled", true)
+	viper.SetDefault("websocket.min_interval", 5*time.Second)
+	viper.SetDefault("websocket.max_subscriptions", 16)
+	// Брокер событий общий для SSE и подписок WebSocket
+	var events *service.EventBroker
+	if viper.GetBool("stream.enabled") || viper.GetBool("websocket.enabled") {
+		events = service.NewEventBroker(viper.GetInt("stream.history"), viper.GetInt("stream.client_buffer"))
+	}
+	if viper.GetBool("stream.enabled") {
+		handlerOpts = append(handlerOpts, v1.WithEvents(events, viper.GetDuration("stream.heartbeat")))
+	} else if events != nil {
+		handlerOpts = append(handlerOpts, v1.WithServiceOptions(service.WithEvents(events)))
+	}
+	// Вебхуки: события пишутся в исходящую очередь в БД и отправляются фоновым обработчиком
+	viper.SetDefault("webhooks.enabled", true)
+	viper.SetDefault("webhooks.poll_interval", 2*time.Second)
+	viper.SetDefault("webhooks.timeout", 10*time.Second)
+	viper.SetDefault("webhooks.max_attempts", 8)
+	viper.SetDefault("webhooks.backoff_base", 10*time.Second)
+	viper.SetDefault("webhooks.backoff_max", time.Hour)
+	var webhooks *service.WebhookService
+	webhooksCtx, stopWebhooks := context.WithCancel(context.Background())
+	webhooksDone := make(chan struct{})
+	if viper.GetBool("webhooks.enabled") {
+		webhookOpts := []service.WebhookOption{
+			service.WithWebhookTimeout(viper.GetDuration("webhooks.timeout")),
+			service.WithWebhookRetries(viper.GetInt("webhooks.max_attempts"),
+				viper.GetDuration("webhooks.backoff_base"), viper.GetDuration("webhooks.backoff_max")),
+		}
+		if viper.GetBool("webhooks.allow_private_targets") {
+			webhookOpts = append(webhookOpts, service.AllowPrivateWebhooks())
+		}
+		webhooks = service.NewWebhookService(storage, webhookOpts...)
+		handlerOpts = append(handlerOpts, v1.WithServiceOptions(service.WithEvents(webhooks)))
+		go func() {
+			defer close(webhooksDone)
+			webhooks.Run(webhooksCtx, viper.GetDuration("webhooks.poll_interval"))
+		}()
+	} else {
+		close(webhooksDone)
+	}
+	// Кэш списков одобренных цитат перед БД; сбрасывается и по событиям других экземпляров сервиса
+	viper.SetDefault("cache.enabled", true)
+	viper.SetDefault("cache.size", 1000)
+	viper.SetDefault("cache.ttl", 5*time.Minute)
+	var quotes service.Querier = storage
+	var transactions service.Transactor = storage
+	var cache *service.CachedQuerier
+	if viper.GetBool("cache.enabled") {
+		cache = service.NewCachedQuerier(storage, viper.GetInt("cache.size"), viper.GetDuration("cache.ttl"))
+		quotes = cache
+		// Пакет сбрасывает кэш только после фиксации и не кэширует то, что читает внутри транзакции
+		transactions = cache.Transactions(storage)
+		handlerOpts = append(handlerOpts, v1.WithServiceOptions(service.WithEvents(cache)))
+		expvar.Publish("quote_cache", expvar.Func(func() interface{} { return cache.Stats() }))
+	}
+	handlerOpts = append(handlerOpts, v1.WithBatch(transactions))
+	// С журналом событий QuoteService их не рассылает: диспетчер раздает события из журнала,
+	// включая изменения, сделанные другими экземплярами сервиса
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	dispatchDone := make(chan struct{})
+	if viper.GetBool("outbox.enabled") {
+		var publishers []service.EventPublisher
+		if events != nil {
+			publishers = append(publishers, events)
+		}
+		if webhooks != nil {
+			publishers = append(publishers, webhooks)
+		}
+		if cache != nil {
+			publishers = append(publishers, cache)
+		}
+		handlerOpts = append(handlerOpts, v1.WithServiceOptions(service.WithOutbox()))
+		dispatcher := service.NewEventDispatcher(storage, repoPostgres.NewListener(db.DB, repoPostgres.EventsChannel), publishers,
+			service.WithDispatchInterval(viper.GetDuration("outbox.poll_interval")),
+			service.WithEventRetention(viper.GetDuration("outbox.retention")),
+		)
+		go func() {
+			defer close(dispatchDone)
+			dispatcher.Run(dispatchCtx)
+		}()
+	} else {
+		close(dispatchDone)
+	}
+	handler := v1.NewHandler(quotes, logger, handlerOpts...)
 	r.Mount("/quotes", handler.Routes())
+	if moderation {
+		r.Mount("/moderation", handler.ModerationRoutes())
+	}
+	// Без аутентификации владельца подборки нет, и права не проверяются
+	var collectionPolicy service.CollectionPolicy
+	if viper.GetBool("auth.enabled") {
+		collectionPolicy = service.RolePolicy{}
+	}
+	r.Mount("/collections", v1.NewCollectionHandler(storage, collectionPolicy, logger).Routes())
+	if viper.GetBool("auth.enabled") {
+		r.Mount("/keys", v1.NewKeyHandler(apiKeys, service.RolePolicy{}, logger).Routes())
+		if webhooks != nil {
+			r.Mount("/webhooks", v1.NewWebhookHandler(webhooks, service.RolePolicy{}, logger).Routes())
+		}
+	}
+	viper.SetDefault("graphql.enabled", true)
+	if viper.GetBool("graphql.enabled") {
+		r.Mount("/graphql", gql.NewHandler(handler.Service(), logger).Routes())
+	}
+	if viper.GetBool("websocket.enabled") {
+		r.Mount("/ws", ws.NewHandler(handler.Service(), events, logger,
+			ws.WithMinInterval(viper.GetDuration("websocket.min_interval")),
+			ws.WithMaxSubscriptions(viper.GetInt("websocket.max_subscriptions")),
+			ws.WithAllowedOrigins(viper.GetStringSlice("websocket.allowed_origins")...),
+		).Routes())
+	}
+	// Счетчики кэша и рантайма в формате expvar раскрывают внутреннее состояние сервиса: только для admin
+	if viper.GetBool("auth.enabled") {
+		r.With(v1.RequireScope(auth.ScopeAdmin, logger)).Handle("/debug/vars", expvar.Handler())
+	} else {
+		logger.Warn("GET /debug/vars отключен: он требует auth.enabled")
+	}
+	// GET /openapi.json и Swagger UI на GET /docs
+	r.Mount("/", v1.DocsRoutes())
+
+	// gRPC API на отдельном порту работает с тем же QuoteService, что и HTTP API
+	viper.SetDefault("grpc.enabled", true)
+	viper.SetDefault("grpc.port", 9090)
+	var grpcServer *grpc.Server
+	if viper.GetBool("grpc.enabled") {
+		grpcOpts := grpcLimits
+		if viper.GetBool("auth.enabled") {
+			grpcOpts = append(grpcOpts, grpcapi.WithAuth(apiKeys, tokens, viper.GetBool("auth.public_reads")))
+		}
+		grpcServer = grpcapi.NewServer(handler.Service(), logger, grpcOpts...)
+		grpcPort := viper.GetInt("grpc.port")
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", grpcPort))
+		if err != nil {
+			logger.Fatal("Ошибка запуска gRPC-сервера", zap.Error(err))
+		}
+		go func() {
+			logger.Info("gRPC-сервер запущен", zap.Int("port", grpcPort))
+			if err := grpcServer.Serve(lis); err != nil {
+				logger.Fatal("Ошибка запуска gRPC-сервера", zap.Error(err))
+			}
+		}()
+	}
 
 	// Создание HTTP-сервера
 	port := viper.GetInt("server.port")
@@ -83,9 +365,25 @@ func main() {
 
 	// Завершение работы сервера
 	logger.Info("Завершение работы сервера...")
+	stopDispatch()
+	<-dispatchDone
+	if events != nil {
+		// Открытые потоки событий иначе не дадут серверу завершиться
+		events.Close()
+	}
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Error("Ошибка при завершении работы сервера", zap.Error(err))
 	}
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+
+	// Сброс накопленных счетчиков до закрытия БД
+	stopViews()
+	<-viewsDone
+	// Неотправленные доставки остаются в очереди и будут отправлены после запуска
+	stopWebhooks()
+	<-webhooksDone
 
 	// Закрытие соединения с базой данных
 	logger.Info("Закрытие соединения с базой данных...")
@@ -93,3 +391,60 @@ func main() {
 
 	logger.Info("Сервер успешно завершил работу")
 }
+
+// contentRules собирает правила проверки цитат из content_filter
+func contentRules() ([]service.ContentRule, error) {
+	rules := []service.ContentRule{
+		service.StripControlChars(),
+		service.LengthLimits{
+			MinQuote:  viper.GetInt("content_filter.min_length"),
+			MaxQuote:  viper.GetInt("content_filter.max_length"),
+			MaxAuthor: viper.GetInt("content_filter.max_author_length"),
+		},
+	}
+	if files := viper.GetStringSlice("content_filter.banned_words_files"); len(files) > 0 {
+		banned, err := service.LoadBannedWords(files...)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, banned)
+	}
+	if !viper.GetBool("content_filter.allow_urls") {
+		rules = append(rules, service.NoURLs())
+	}
+	if viper.GetBool("content_filter.spam") {
+		rules = append(rules, service.NoSpam())
+	}
+	return rules, nil
+}
+
+// rateLimitConfig читает ограничения класса запросов rate_limit.read, rate_limit.write или rate_limit.ip
+func rateLimitConfig(class string) ratelimit.Config {
+	prefix := "rate_limit." + class + "."
+	return ratelimit.Config{
+		Rate:       viper.GetFloat64(prefix + "rate"),
+		Burst:      viper.GetInt(prefix + "burst"),
+		DailyQuota: viper.GetInt(prefix + "daily_quota"),
+	}
+}
+
+// newJWTVerifier загружает JWKS из файла или по URL согласно auth.jwt
+func newJWTVerifier() (*auth.JWTVerifier, error) {
+	var keys *auth.KeySet
+	var err error
+	if path := viper.GetString("auth.jwt.jwks_file"); path != "" {
+		keys, err = auth.NewFileKeySet(path)
+	} else {
+		keys, err = auth.NewURLKeySet(viper.GetString("auth.jwt.jwks_url"), nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return auth.NewJWTVerifier(keys, auth.JWTConfig{
+		Issuer:     viper.GetString("auth.jwt.issuer"),
+		Audience:   viper.GetString("auth.jwt.audience"),
+		RoleClaim:  viper.GetString("auth.jwt.role_claim"),
+		RoleScopes: viper.GetStringMapStringSlice("auth.jwt.roles"),
+		Leeway:     viper.GetDuration("auth.jwt.leeway"),
+	}), nil
+}
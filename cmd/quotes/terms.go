@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+const termsUsage = `Использование:
+  quote-service terms reindex
+`
+
+// TermIndexer заполняет индекс слов цитат
+type TermIndexer interface {
+	BackfillTerms(ctx context.Context, all bool) (int, error)
+}
+
+// runTerms выполняет административную команду обслуживания индекса слов и возвращает код выхода
+func runTerms(ctx context.Context, index TermIndexer, args []string, out io.Writer) int {
+	if len(args) == 1 && args[0] == "reindex" {
+		n, err := index.BackfillTerms(ctx, true)
+		if err != nil {
+			fmt.Fprintf(out, "Ошибка индексации цитат: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(out, "Проиндексировано цитат: %d\n", n)
+		return 0
+	}
+
+	fmt.Fprint(out, termsUsage)
+	return 2
+}
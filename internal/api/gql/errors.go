@@ -0,0 +1,57 @@
+package gql
+
+import (
+	"errors"
+
+	"quote-service/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+// queryError - ошибка поля GraphQL. Код domain.Error и подробности передаются в extensions.
+type queryError struct {
+	message    string
+	code       domain.Code
+	violations []domain.Violation
+	candidates []int
+}
+
+func (e *queryError) Error() string {
+	return e.message
+}
+
+func (e *queryError) Extensions() map[string]interface{} {
+	ext := map[string]interface{}{"code": e.code}
+	if len(e.violations) > 0 {
+		ext["violations"] = e.violations
+	}
+	if len(e.candidates) > 0 {
+		ext["candidates"] = e.candidates
+	}
+	return ext
+}
+
+// error переводит ошибку сервиса в ошибку GraphQL. Текст внутренних ошибок клиенту не попадает.
+func (r *resolver) error(err error) error {
+	code := domain.CodeOf(err)
+	if code == domain.CodeInternal {
+		r.logger.Error("Внутренняя ошибка GraphQL", zap.Error(err))
+		return &queryError{message: "internal error", code: code}
+	}
+
+	qe := &queryError{message: err.Error(), code: code}
+	var (
+		catalogErr    *domain.Error
+		validationErr *domain.ValidationError
+		dupErr        *domain.DuplicateError
+	)
+	switch {
+	case errors.As(err, &validationErr):
+		qe.violations = validationErr.Violations
+	case errors.As(err, &dupErr):
+		qe.candidates = dupErr.Candidates
+	case errors.As(err, &catalogErr):
+		qe.message = catalogErr.Message
+	}
+	return qe
+}
@@ -0,0 +1,107 @@
+package gql
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	"quote-service/internal/domain"
+	"quote-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/graph-gophers/graphql-go"
+	"go.uber.org/zap"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+const (
+	// maxDepth ограничивает вложенность запроса: quotes { author { quotes { author ... } } }
+	maxDepth     = 8
+	maxBodyBytes = 1 << 20
+)
+
+// Handler обслуживает /graphql. GET принимает только запросы (query) и проходит аутентификацию
+// как чтение, POST принимает и мутации и, как любой изменяющий запрос, требует области write.
+type Handler struct {
+	schema *graphql.Schema
+	logger *zap.Logger
+}
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type readOnlyKey struct{}
+
+func NewHandler(quotes *service.QuoteService, logger *zap.Logger) *Handler {
+	root := &resolver{quotes: quotes, logger: logger}
+	return &Handler{
+		schema: graphql.MustParseSchema(schemaSDL, root, graphql.MaxDepth(maxDepth)),
+		logger: logger,
+	}
+}
+
+func (h *Handler) Routes() *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/", h.serveGET)   // GET /graphql?query=...&variables=...
+	r.Post("/", h.servePOST) // POST /graphql
+	return r
+}
+
+func (h *Handler) serveGET(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	req := request{Query: params.Get("query"), OperationName: params.Get("operationName")}
+	if raw := params.Get("variables"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &req.Variables); err != nil {
+			h.sendRequestError(w, "variables must be a JSON object")
+			return
+		}
+	}
+	h.exec(w, r.WithContext(context.WithValue(r.Context(), readOnlyKey{}, true)), req)
+}
+
+func (h *Handler) servePOST(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(&req); err != nil {
+		h.sendRequestError(w, "request body must be a JSON object with a query")
+		return
+	}
+	h.exec(w, r, req)
+}
+
+func (h *Handler) exec(w http.ResponseWriter, r *http.Request, req request) {
+	if req.Query == "" {
+		h.sendRequestError(w, "query is required")
+		return
+	}
+	resp := h.schema.Exec(r.Context(), req.Query, req.OperationName, req.Variables)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.logger.Error("Ошибка кодирования ответа", zap.Error(err))
+	}
+}
+
+// sendRequestError отвечает 400, если запрос нельзя выполнить вовсе
+func (h *Handler) sendRequestError(w http.ResponseWriter, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]interface{}{{
+			"message":    message,
+			"extensions": map[string]interface{}{"code": domain.CodeValidation},
+		}},
+	})
+}
+
+// requireWrite запрещает мутации в GET: такой запрос прошел аутентификацию как чтение
+func requireWrite(ctx context.Context) error {
+	if readOnly, _ := ctx.Value(readOnlyKey{}).(bool); readOnly {
+		return domain.NewError(domain.CodeValidation, "mutations must be sent with POST")
+	}
+	return nil
+}
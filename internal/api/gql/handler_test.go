@@ -0,0 +1,241 @@
+package gql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// fakeStorage - хранилище в памяти, которое считает пакетные выборки
+type fakeStorage struct {
+	mu     sync.Mutex
+	quotes []models.Quote
+	tags   map[int][]string
+	err    error
+
+	tagQueries    int
+	authorQueries int
+}
+
+func (s *fakeStorage) Create(_ context.Context, quote *models.Quote) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	quote.ID = len(s.quotes) + 1
+	s.quotes = append(s.quotes, *quote)
+	s.tags[quote.ID] = quote.Tags
+	return nil
+}
+
+func (s *fakeStorage) GetAll(context.Context, ...string) ([]models.Quote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.Quote(nil), s.quotes...), s.err
+}
+
+func (s *fakeStorage) GetRandom(context.Context, ...string) (*models.Quote, error) {
+	return nil, domain.ErrNotFound
+}
+
+func (s *fakeStorage) GetByID(_ context.Context, id int) (*models.Quote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, q := range s.quotes {
+		if q.ID == id {
+			return &q, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (s *fakeStorage) GetDaily(context.Context, time.Time) (*models.Quote, error) {
+	return nil, domain.ErrNotFound
+}
+
+func (s *fakeStorage) GetByAuthor(ctx context.Context, author string, _ ...string) ([]models.Quote, error) {
+	return s.GetByAuthors(ctx, []string{author})
+}
+
+func (s *fakeStorage) Update(context.Context, *models.Quote) error { return nil }
+
+func (s *fakeStorage) Delete(context.Context, int) error { return nil }
+
+func (s *fakeStorage) Exists(context.Context, string, string) (bool, error) { return false, nil }
+
+func (s *fakeStorage) TagsByQuoteIDs(_ context.Context, ids []int) (map[int][]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tagQueries++
+	tags := make(map[int][]string)
+	for _, id := range ids {
+		if t := s.tags[id]; len(t) > 0 {
+			tags[id] = t
+		}
+	}
+	return tags, nil
+}
+
+func (s *fakeStorage) GetByAuthors(_ context.Context, authors []string) ([]models.Quote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authorQueries++
+	var quotes []models.Quote
+	for _, q := range s.quotes {
+		for _, a := range authors {
+			if q.Author == a {
+				quotes = append(quotes, q)
+			}
+		}
+	}
+	return quotes, nil
+}
+
+func newTestHandler() (*Handler, *fakeStorage) {
+	storage := &fakeStorage{tags: map[int][]string{}}
+	for i, q := range []models.Quote{
+		{Author: "Seneca", Quote: "Luck is preparation", Tags: []string{"luck"}},
+		{Author: "Confucius", Quote: "Life is simple", Tags: []string{"life", "wisdom"}},
+		{Author: "Seneca", Quote: "While we teach, we learn"},
+	} {
+		q.ID = i + 1
+		storage.quotes = append(storage.quotes, q)
+		storage.tags[q.ID] = q.Tags
+	}
+	quotes := service.NewQuoteService(storage, service.WithLoaders(storage))
+	return NewHandler(quotes, zap.NewNop()), storage
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func post(t *testing.T, h *Handler, query string, variables map[string]interface{}) response {
+	body, _ := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	w := httptest.NewRecorder()
+	h.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+func TestHandler_BatchedLoaders(t *testing.T) {
+	h, storage := newTestHandler()
+
+	resp := post(t, h, `{
+		quotes {
+			id text tags
+			author { name quotes { id tags author { name } } }
+			rating { average count likes }
+		}
+	}`, nil)
+	assert.Empty(t, resp.Errors)
+
+	var data struct {
+		Quotes []struct {
+			ID     string
+			Tags   []string
+			Author struct {
+				Name   string
+				Quotes []struct {
+					ID   string
+					Tags []string
+				}
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal(resp.Data, &data))
+	if assert.Len(t, data.Quotes, 3) {
+		assert.Equal(t, []string{"luck"}, data.Quotes[0].Tags)
+		assert.Equal(t, []string{}, data.Quotes[2].Tags)
+		assert.Equal(t, "Seneca", data.Quotes[0].Author.Name)
+		assert.Len(t, data.Quotes[0].Author.Quotes, 2)
+		assert.Equal(t, []string{"life", "wisdom"}, data.Quotes[1].Author.Quotes[0].Tags)
+	}
+	// по одному запросу на уровень вложенности, а не на цитату
+	assert.Equal(t, 2, storage.tagQueries)
+	assert.Equal(t, 1, storage.authorQueries)
+}
+
+func TestHandler_Queries(t *testing.T) {
+	h, _ := newTestHandler()
+
+	t.Run("paging", func(t *testing.T) {
+		resp := post(t, h, `{ quotes(limit: 1, offset: 1) { id } }`, nil)
+		assert.JSONEq(t, `{"quotes": [{"id": "2"}]}`, string(resp.Data))
+	})
+
+	t.Run("missing quote is null", func(t *testing.T) {
+		resp := post(t, h, `{ quote(id: "404") { id } randomQuote { id } }`, nil)
+		assert.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"quote": null, "randomQuote": null}`, string(resp.Data))
+	})
+
+	t.Run("author", func(t *testing.T) {
+		resp := post(t, h, `query($name: String!) { author(name: $name) { quotes { text } } }`, map[string]interface{}{"name": "Confucius"})
+		assert.JSONEq(t, `{"author": {"quotes": [{"text": "Life is simple"}]}}`, string(resp.Data))
+	})
+
+	t.Run("search without index", func(t *testing.T) {
+		resp := post(t, h, `{ search(query: "life") { id } }`, nil)
+		if assert.Len(t, resp.Errors, 1) {
+			assert.Equal(t, string(domain.CodeNotImplemented), resp.Errors[0].Extensions["code"])
+		}
+	})
+}
+
+func TestHandler_Mutations(t *testing.T) {
+	h, storage := newTestHandler()
+
+	t.Run("create", func(t *testing.T) {
+		resp := post(t, h, `mutation($input: CreateQuoteInput!) { createQuote(input: $input) { id tags author { name } } }`,
+			map[string]interface{}{"input": map[string]interface{}{"author": "Plato", "text": "Know thyself", "tags": []string{"Wisdom"}}})
+		assert.Empty(t, resp.Errors)
+		assert.JSONEq(t, `{"createQuote": {"id": "4", "tags": ["wisdom"], "author": {"name": "Plato"}}}`, string(resp.Data))
+	})
+
+	t.Run("validation error", func(t *testing.T) {
+		resp := post(t, h, `mutation { createQuote(input: {author: "", text: ""}) { id } }`, nil)
+		if assert.Len(t, resp.Errors, 1) {
+			assert.Equal(t, string(domain.CodeValidation), resp.Errors[0].Extensions["code"])
+		}
+	})
+
+	t.Run("not allowed over GET", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		target := "/?query=" + url.QueryEscape(`mutation { deleteQuote(id: "1") }`)
+		h.Routes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		var resp response
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		if assert.Len(t, resp.Errors, 1) {
+			assert.Equal(t, "mutations must be sent with POST", resp.Errors[0].Message)
+		}
+	})
+
+	t.Run("internal error hidden", func(t *testing.T) {
+		storage.err = errors.New("connection refused")
+		defer func() { storage.err = nil }()
+
+		resp := post(t, h, `{ quotes { id } }`, nil)
+		if assert.Len(t, resp.Errors, 1) {
+			assert.Equal(t, "internal error", resp.Errors[0].Message)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package gql
+
+import (
+	"context"
+	"sync"
+
+	"quote-service/internal/models"
+)
+
+// quoteBatch - цитаты одного уровня ответа (например, все элементы списка quotes). Вложенные поля
+// загружаются для всего уровня одним запросом при первом обращении любой цитаты, поэтому
+// число запросов к хранилищу зависит от глубины запроса, а не от числа цитат.
+type quoteBatch struct {
+	root  *resolver
+	items []models.Quote
+
+	tagsOnce sync.Once
+	tags     map[int][]string
+	tagsErr  error
+
+	authorsOnce sync.Once
+	authors     *authorBatch
+}
+
+func newQuoteBatch(root *resolver, items []models.Quote) *quoteBatch {
+	return &quoteBatch{root: root, items: items}
+}
+
+// resolvers возвращает резолверы всех цитат уровня
+func (b *quoteBatch) resolvers() []*quoteResolver {
+	resolvers := make([]*quoteResolver, len(b.items))
+	for i := range b.items {
+		resolvers[i] = &quoteResolver{quote: &b.items[i], batch: b}
+	}
+	return resolvers
+}
+
+// tagsOf возвращает теги цитаты, загружая теги всего уровня одним запросом
+func (b *quoteBatch) tagsOf(ctx context.Context, id int) ([]string, error) {
+	b.tagsOnce.Do(func() {
+		ids := make([]int, len(b.items))
+		for i := range b.items {
+			ids[i] = b.items[i].ID
+		}
+		b.tags, b.tagsErr = b.root.quotes.TagsByQuoteIDs(ctx, ids)
+	})
+	if b.tagsErr != nil {
+		return nil, b.root.error(b.tagsErr)
+	}
+	if tags := b.tags[id]; tags != nil {
+		return tags, nil
+	}
+	return []string{}, nil
+}
+
+// authorBatch возвращает общий для уровня набор авторов
+func (b *quoteBatch) authorBatch() *authorBatch {
+	b.authorsOnce.Do(func() {
+		seen := make(map[string]bool, len(b.items))
+		var names []string
+		for _, q := range b.items {
+			if !seen[q.Author] {
+				seen[q.Author] = true
+				names = append(names, q.Author)
+			}
+		}
+		b.authors = &authorBatch{root: b.root, names: names}
+	})
+	return b.authors
+}
+
+// authorBatch - авторы одного уровня ответа. Цитаты всех авторов загружаются одним запросом
+// и образуют общий уровень quoteBatch.
+type authorBatch struct {
+	root  *resolver
+	names []string
+
+	once     sync.Once
+	byAuthor map[string][]*quoteResolver
+	err      error
+}
+
+func (b *authorBatch) quotesOf(ctx context.Context, name string) ([]*quoteResolver, error) {
+	b.once.Do(func() {
+		byAuthor, err := b.root.quotes.QuotesByAuthors(ctx, b.names)
+		if err != nil {
+			b.err = err
+			return
+		}
+		var items []models.Quote
+		for _, name := range b.names {
+			items = append(items, byAuthor[name]...)
+		}
+		next := newQuoteBatch(b.root, items)
+		b.byAuthor = make(map[string][]*quoteResolver, len(b.names))
+		for _, r := range next.resolvers() {
+			b.byAuthor[r.quote.Author] = append(b.byAuthor[r.quote.Author], r)
+		}
+	})
+	if b.err != nil {
+		return nil, b.root.error(b.err)
+	}
+	if quotes := b.byAuthor[name]; quotes != nil {
+		return quotes, nil
+	}
+	return []*quoteResolver{}, nil
+}
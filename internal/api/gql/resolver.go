@@ -0,0 +1,228 @@
+package gql
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"quote-service/internal/auth"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/internal/service"
+
+	"github.com/graph-gophers/graphql-go"
+	"go.uber.org/zap"
+)
+
+// resolver - корневой резолвер запросов и мутаций
+type resolver struct {
+	quotes *service.QuoteService
+	logger *zap.Logger
+}
+
+func (r *resolver) Quotes(ctx context.Context, args struct {
+	Author *string
+	Limit  int32
+	Offset int32
+}) ([]*quoteResolver, error) {
+	var (
+		quotes []models.Quote
+		err    error
+	)
+	if args.Author != nil && *args.Author != "" {
+		quotes, err = r.quotes.GetByAuthor(ctx, *args.Author)
+	} else {
+		quotes, err = r.quotes.GetAll(ctx)
+	}
+	if err != nil {
+		return nil, r.error(err)
+	}
+	if args.Limit < 0 || args.Offset < 0 {
+		return nil, r.error(domain.NewError(domain.CodeValidation, "limit and offset must not be negative"))
+	}
+	if int(args.Offset) >= len(quotes) {
+		quotes = nil
+	} else {
+		quotes = quotes[args.Offset:]
+	}
+	if int(args.Limit) < len(quotes) {
+		quotes = quotes[:args.Limit]
+	}
+	return newQuoteBatch(r, quotes).resolvers(), nil
+}
+
+func (r *resolver) Quote(ctx context.Context, args struct{ ID graphql.ID }) (*quoteResolver, error) {
+	id, err := strconv.Atoi(string(args.ID))
+	if err != nil {
+		return nil, r.error(domain.NewError(domain.CodeValidation, "id must be an integer"))
+	}
+	quote, err := r.quotes.GetByID(ctx, id)
+	if err == domain.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, r.error(err)
+	}
+	return r.single(quote), nil
+}
+
+func (r *resolver) RandomQuote(ctx context.Context) (*quoteResolver, error) {
+	quote, err := r.quotes.GetRandom(ctx)
+	if err == domain.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, r.error(err)
+	}
+	return r.single(quote), nil
+}
+
+func (r *resolver) Search(ctx context.Context, args struct {
+	Query string
+	Limit int32
+}) ([]*quoteResolver, error) {
+	quotes, err := r.quotes.Search(ctx, args.Query, int(args.Limit))
+	if err != nil {
+		if err == domain.ErrInvalidInput {
+			err = domain.NewError(domain.CodeValidation, "query must contain at least one word")
+		}
+		return nil, r.error(err)
+	}
+	return newQuoteBatch(r, quotes).resolvers(), nil
+}
+
+func (r *resolver) Author(ctx context.Context, args struct{ Name string }) (*authorResolver, error) {
+	author := &authorResolver{name: args.Name, batch: &authorBatch{root: r, names: []string{args.Name}}}
+	quotes, err := author.batch.quotesOf(ctx, args.Name)
+	if err != nil {
+		return nil, err
+	}
+	if len(quotes) == 0 {
+		return nil, nil
+	}
+	return author, nil
+}
+
+type createQuoteInput struct {
+	Author string
+	Text   string
+	Tags   *[]string
+	Force  *bool
+}
+
+func (r *resolver) CreateQuote(ctx context.Context, args struct{ Input createQuoteInput }) (*quoteResolver, error) {
+	if err := requireWrite(ctx); err != nil {
+		return nil, r.error(err)
+	}
+	quote := models.Quote{Author: args.Input.Author, Quote: args.Input.Text}
+	if args.Input.Tags != nil {
+		quote.Tags = *args.Input.Tags
+	}
+	var createOpts []service.CreateOption
+	if args.Input.Force != nil && *args.Input.Force {
+		createOpts = append(createOpts, service.AllowDuplicates())
+	}
+	if err := r.quotes.Create(ctx, &quote, createOpts...); err != nil {
+		if err == domain.ErrInvalidInput {
+			err = domain.NewError(domain.CodeValidation, "author and text are required")
+		}
+		return nil, r.error(err)
+	}
+	r.audit(ctx, "quote.created", quote.ID)
+	return r.single(&quote), nil
+}
+
+func (r *resolver) DeleteQuote(ctx context.Context, args struct{ ID graphql.ID }) (bool, error) {
+	if err := requireWrite(ctx); err != nil {
+		return false, r.error(err)
+	}
+	id, err := strconv.Atoi(string(args.ID))
+	if err != nil {
+		return false, r.error(domain.NewError(domain.CodeValidation, "id must be an integer"))
+	}
+	if err := r.quotes.Delete(ctx, id); err != nil {
+		return false, r.error(err)
+	}
+	r.audit(ctx, "quote.deleted", id)
+	return true, nil
+}
+
+func (r *resolver) single(quote *models.Quote) *quoteResolver {
+	return newQuoteBatch(r, []models.Quote{*quote}).resolvers()[0]
+}
+
+func (r *resolver) audit(ctx context.Context, action string, quoteID int) {
+	actor := "anonymous"
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		actor = principal.ID
+	}
+	r.logger.Info("Аудит",
+		zap.String("action", action),
+		zap.Int("quote_id", quoteID),
+		zap.String("actor", actor),
+		zap.String("transport", "graphql"),
+	)
+}
+
+type quoteResolver struct {
+	quote *models.Quote
+	batch *quoteBatch
+}
+
+func (q *quoteResolver) ID() graphql.ID {
+	return graphql.ID(strconv.Itoa(q.quote.ID))
+}
+
+func (q *quoteResolver) Text() string {
+	return q.quote.Quote
+}
+
+func (q *quoteResolver) Author() *authorResolver {
+	return &authorResolver{name: q.quote.Author, batch: q.batch.authorBatch()}
+}
+
+func (q *quoteResolver) Tags(ctx context.Context) ([]string, error) {
+	return q.batch.tagsOf(ctx, q.quote.ID)
+}
+
+// Rating - агрегаты оценок уже загружены QuoteService для всей выборки
+func (q *quoteResolver) Rating() *ratingResolver {
+	return &ratingResolver{quote: q.quote}
+}
+
+func (q *quoteResolver) Status() string {
+	return q.quote.Status
+}
+
+func (q *quoteResolver) CreatedAt() string {
+	return q.quote.CreatedAt.UTC().Format(time.RFC3339)
+}
+
+type authorResolver struct {
+	name  string
+	batch *authorBatch
+}
+
+func (a *authorResolver) Name() string {
+	return a.name
+}
+
+func (a *authorResolver) Quotes(ctx context.Context) ([]*quoteResolver, error) {
+	return a.batch.quotesOf(ctx, a.name)
+}
+
+type ratingResolver struct {
+	quote *models.Quote
+}
+
+func (r *ratingResolver) Average() float64 {
+	return r.quote.AvgRating
+}
+
+func (r *ratingResolver) Count() int32 {
+	return int32(r.quote.RatingsCount)
+}
+
+func (r *ratingResolver) Likes() int32 {
+	return int32(r.quote.Likes)
+}
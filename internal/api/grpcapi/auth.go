@@ -0,0 +1,125 @@
+package grpcapi
+
+import (
+	"context"
+	"strings"
+
+	"quote-service/internal/api/grpcapi/quotev1"
+	"quote-service/internal/auth"
+	"quote-service/internal/domain"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Метаданные с учетными данными клиента
+const (
+	apiKeyMetadata        = "x-api-key"
+	authorizationMetadata = "authorization"
+)
+
+type KeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (*auth.Principal, error)
+}
+
+type TokenVerifier interface {
+	Verify(token string) (*auth.Principal, error)
+}
+
+// methodScopes - область доступа для методов QuoteService. Методы health и reflection доступны без аутентификации.
+var methodScopes = map[string]string{
+	quotev1.QuoteService_CreateQuote_FullMethodName:    auth.ScopeWrite,
+	quotev1.QuoteService_DeleteQuote_FullMethodName:    auth.ScopeWrite,
+	quotev1.QuoteService_GetQuote_FullMethodName:       auth.ScopeRead,
+	quotev1.QuoteService_ListQuotes_FullMethodName:     auth.ScopeRead,
+	quotev1.QuoteService_GetRandomQuote_FullMethodName: auth.ScopeRead,
+	quotev1.QuoteService_SearchQuotes_FullMethodName:   auth.ScopeRead,
+}
+
+// authInterceptor - аналог v1.AuthMiddleware: изменяющие методы требуют write, чтение - read, если оно не открыто публично
+type authInterceptor struct {
+	logger      *zap.Logger
+	keys        KeyAuthenticator
+	tokens      TokenVerifier
+	publicReads bool
+}
+
+func (a *authInterceptor) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, statusError(a.logger, err)
+	}
+	return handler(ctx, req)
+}
+
+func (a *authInterceptor) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return statusError(a.logger, err)
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticate определяет клиента по метаданным и проверяет область доступа метода
+func (a *authInterceptor) authenticate(ctx context.Context, method string) (context.Context, error) {
+	scope, ok := methodScopes[method]
+	if !ok {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	if token, ok := bearerToken(md); ok && a.tokens != nil {
+		principal, err := a.tokens.Verify(token)
+		if err != nil {
+			a.logger.Info("Недействительный токен", zap.Error(err))
+			return ctx, domain.NewError(domain.CodeUnauthorized, "invalid token")
+		}
+		ctx = auth.WithPrincipal(ctx, principal)
+	} else if keys := md.Get(apiKeyMetadata); len(keys) > 0 && keys[0] != "" && a.keys != nil {
+		principal, err := a.keys.Authenticate(ctx, keys[0])
+		if err != nil {
+			if err == domain.ErrUnauthorized {
+				err = domain.NewError(domain.CodeUnauthorized, "invalid api key")
+			}
+			return ctx, err
+		}
+		ctx = auth.WithPrincipal(ctx, principal)
+	}
+
+	if scope == auth.ScopeRead && a.publicReads {
+		return ctx, nil
+	}
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return ctx, domain.NewError(domain.CodeUnauthorized, "authentication required")
+	}
+	if !principal.HasScope(scope) {
+		a.logger.Info("Недостаточно прав", zap.String("principal", principal.ID), zap.String("scope", scope))
+		return ctx, domain.NewError(domain.CodeForbidden, "insufficient scope")
+	}
+	return ctx, nil
+}
+
+func bearerToken(md metadata.MD) (string, bool) {
+	values := md.Get(authorizationMetadata)
+	if len(values) == 0 {
+		return "", false
+	}
+	header := values[0]
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len("Bearer "):])
+	return token, token != ""
+}
+
+// authenticatedStream подменяет контекст потока контекстом с клиентом
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
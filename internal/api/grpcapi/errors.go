@@ -0,0 +1,116 @@
+package grpcapi
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"quote-service/internal/domain"
+
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// errorDomain - домен ErrorInfo в деталях ошибок
+const errorDomain = "quote-service"
+
+// statusCodes переводит коды domain.Error в коды gRPC, как problemStatus в HTTP API
+var statusCodes = map[domain.Code]codes.Code{
+	domain.CodeValidation:   codes.InvalidArgument,
+	domain.CodeNotFound:     codes.NotFound,
+	domain.CodeDuplicate:    codes.AlreadyExists,
+	domain.CodeConflict:     codes.Aborted,
+	domain.CodeUnauthorized: codes.Unauthenticated,
+	domain.CodeForbidden:    codes.PermissionDenied,
+	domain.CodeRateLimited:  codes.ResourceExhausted,
+	domain.CodeInternal:     codes.Internal,
+
+	domain.CodePayloadTooLarge:      codes.InvalidArgument,
+	domain.CodeUnsupportedMediaType: codes.InvalidArgument,
+	domain.CodeNotAcceptable:        codes.InvalidArgument,
+	domain.CodeIdempotencyMismatch:  codes.FailedPrecondition,
+	domain.CodeRolledBack:           codes.Aborted,
+	domain.CodeNotImplemented:       codes.Unimplemented,
+}
+
+// status переводит ошибку в статус gRPC. Код domain.Error передается в ErrorInfo.Reason,
+// нарушения - в BadRequest. Текст внутренних ошибок клиенту не попадает.
+func (s *Server) status(err error) error {
+	return statusError(s.logger, err)
+}
+
+func statusError(logger *zap.Logger, err error) error {
+	code := domain.CodeOf(err)
+	if code == domain.CodeInternal {
+		logger.Error("Внутренняя ошибка", zap.Error(err))
+		return status.Error(codes.Internal, "internal error")
+	}
+
+	info := &errdetails.ErrorInfo{Reason: string(code), Domain: errorDomain}
+	var (
+		catalogErr    *domain.Error
+		validationErr *domain.ValidationError
+		dupErr        *domain.DuplicateError
+		forbiddenErr  *domain.ForbiddenError
+		message       = err.Error()
+		badRequest    *errdetails.BadRequest
+	)
+	switch {
+	case errors.As(err, &validationErr):
+		badRequest = &errdetails.BadRequest{}
+		for _, v := range validationErr.Violations {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Message,
+				Reason:      v.Code,
+			})
+		}
+	case errors.As(err, &dupErr):
+		if len(dupErr.Candidates) > 0 {
+			ids := make([]string, len(dupErr.Candidates))
+			for i, id := range dupErr.Candidates {
+				ids[i] = strconv.Itoa(id)
+			}
+			info.Metadata = map[string]string{"candidates": strings.Join(ids, ",")}
+		}
+	case errors.As(err, &forbiddenErr):
+		info.Metadata = map[string]string{"action": forbiddenErr.Action, "reason": forbiddenErr.Reason}
+	case errors.As(err, &catalogErr):
+		message = catalogErr.Message
+	}
+
+	st := status.New(statusCodes[code], message)
+	details := []protoadapt.MessageV1{info}
+	if badRequest != nil {
+		details = append(details, badRequest)
+	}
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// Коды нарушений совпадают с кодами HTTP API (v1.ViolationRequired и другие)
+const (
+	violationRequired   = "required"
+	violationOutOfRange = "out_of_range"
+)
+
+func invalid(violations ...domain.Violation) error {
+	return &domain.ValidationError{Message: "request validation failed", Violations: violations}
+}
+
+func violation(field, code, message string) domain.Violation {
+	return domain.Violation{Field: field, Code: code, Message: message}
+}
+
+// required добавляет нарушение, если строковое поле пустое
+func required(violations []domain.Violation, field, value string) []domain.Violation {
+	if strings.TrimSpace(value) == "" {
+		return append(violations, violation(field, violationRequired, "is required"))
+	}
+	return violations
+}
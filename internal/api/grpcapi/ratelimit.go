@@ -0,0 +1,115 @@
+package grpcapi
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"quote-service/internal/auth"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// retryAfterMetadata - через сколько секунд можно повторить запрос, отклоненный лимитом
+const retryAfterMetadata = "retry-after"
+
+// IPLimiter ограничивает запросы с одного IP до аутентификации (v1.IPRateLimiter)
+type IPLimiter interface {
+	Limit(client string) (time.Duration, error)
+}
+
+// ClientLimiter ограничивает частоту запросов и квоту клиента (v1.RateLimiter)
+type ClientLimiter interface {
+	Limit(client string, write bool) (time.Duration, error)
+}
+
+// rateLimitInterceptor применяет те же лимиты, что и HTTP API. Клиенты и IP определяются так же,
+// поэтому при общих лимитерах у клиента одни корзины и квоты для обоих API.
+type rateLimitInterceptor struct {
+	logger  *zap.Logger
+	ip      IPLimiter
+	clients ClientLimiter
+}
+
+// beforeAuth - лимит по IP, стоит перед аутентификацией
+func (l *rateLimitInterceptor) beforeAuth(ctx context.Context, method string) error {
+	if _, ok := methodScopes[method]; !ok || l.ip == nil {
+		return nil
+	}
+	return l.check(ctx, func() (time.Duration, error) {
+		return l.ip.Limit(peerKey(ctx))
+	})
+}
+
+// afterAuth - лимит и квота клиента, стоит после аутентификации
+func (l *rateLimitInterceptor) afterAuth(ctx context.Context, method string) error {
+	scope, ok := methodScopes[method]
+	if !ok || l.clients == nil {
+		return nil
+	}
+	client := peerKey(ctx)
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		client = principal.ID
+	}
+	return l.check(ctx, func() (time.Duration, error) {
+		return l.clients.Limit(client, scope == auth.ScopeWrite)
+	})
+}
+
+func (l *rateLimitInterceptor) check(ctx context.Context, limit func() (time.Duration, error)) error {
+	retryAfter, err := limit()
+	if err != nil {
+		seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+		if err := grpc.SetHeader(ctx, metadata.Pairs(retryAfterMetadata, seconds)); err != nil {
+			l.logger.Debug("Не удалось передать retry-after", zap.Error(err))
+		}
+		return statusError(l.logger, err)
+	}
+	return nil
+}
+
+func (l *rateLimitInterceptor) unaryBeforeAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := l.beforeAuth(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (l *rateLimitInterceptor) unaryAfterAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := l.afterAuth(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (l *rateLimitInterceptor) streamBeforeAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := l.beforeAuth(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+func (l *rateLimitInterceptor) streamAfterAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := l.afterAuth(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// peerKey возвращает "ip:адрес" клиента в том же виде, что и HTTP API
+func peerKey(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "ip:unknown"
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	return "ip:" + host
+}
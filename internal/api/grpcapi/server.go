@@ -0,0 +1,228 @@
+package grpcapi
+
+import (
+	"context"
+
+	"quote-service/internal/api/grpcapi/quotev1"
+	"quote-service/internal/auth"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/internal/service"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Server реализует quote.v1.QuoteService поверх того же service.QuoteService, что и HTTP API
+type Server struct {
+	quotev1.UnimplementedQuoteServiceServer
+
+	quotes *service.QuoteService
+	logger *zap.Logger
+}
+
+type config struct {
+	auth  *authInterceptor
+	limit *rateLimitInterceptor
+}
+
+// Option подключает к gRPC-серверу дополнительные возможности
+type Option func(*config)
+
+// WithAuth проверяет API-ключ (метаданные x-api-key) или bearer-токен (authorization) так же, как HTTP API.
+// tokens может быть nil, если JWT не используется.
+func WithAuth(keys KeyAuthenticator, tokens TokenVerifier, publicReads bool) Option {
+	return func(c *config) {
+		c.auth = &authInterceptor{keys: keys, tokens: tokens, publicReads: publicReads}
+	}
+}
+
+// WithIPRateLimit ограничивает запросы с одного IP до аутентификации.
+// Чтобы лимиты были общими с HTTP API, передается тот же лимитер.
+func WithIPRateLimit(ip IPLimiter) Option {
+	return func(c *config) {
+		c.rateLimit().ip = ip
+	}
+}
+
+// WithRateLimit ограничивает частоту запросов и квоту клиента после аутентификации
+func WithRateLimit(clients ClientLimiter) Option {
+	return func(c *config) {
+		c.rateLimit().clients = clients
+	}
+}
+
+func (c *config) rateLimit() *rateLimitInterceptor {
+	if c.limit == nil {
+		c.limit = &rateLimitInterceptor{}
+	}
+	return c.limit
+}
+
+// NewServer создает gRPC-сервер с QuoteService, сервисом health и reflection
+func NewServer(quotes *service.QuoteService, logger *zap.Logger, opts ...Option) *grpc.Server {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	// порядок как в HTTP API: лимит по IP, аутентификация, лимит клиента
+	var unary []grpc.UnaryServerInterceptor
+	var stream []grpc.StreamServerInterceptor
+	if c.limit != nil {
+		c.limit.logger = logger
+		unary = append(unary, c.limit.unaryBeforeAuth)
+		stream = append(stream, c.limit.streamBeforeAuth)
+	}
+	if c.auth != nil {
+		c.auth.logger = logger
+		unary = append(unary, c.auth.unary)
+		stream = append(stream, c.auth.stream)
+	}
+	if c.limit != nil {
+		unary = append(unary, c.limit.unaryAfterAuth)
+		stream = append(stream, c.limit.streamAfterAuth)
+	}
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...))
+	quotev1.RegisterQuoteServiceServer(srv, &Server{quotes: quotes, logger: logger})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(quotev1.QuoteService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthServer)
+	reflection.Register(srv)
+	return srv
+}
+
+func (s *Server) CreateQuote(ctx context.Context, req *quotev1.CreateQuoteRequest) (*quotev1.CreateQuoteResponse, error) {
+	var violations []domain.Violation
+	violations = required(violations, "author", req.GetAuthor())
+	violations = required(violations, "quote", req.GetQuote())
+	if len(violations) > 0 {
+		return nil, s.status(invalid(violations...))
+	}
+	quote := models.Quote{Author: req.GetAuthor(), Quote: req.GetQuote()}
+
+	var createOpts []service.CreateOption
+	if req.GetForce() {
+		createOpts = append(createOpts, service.AllowDuplicates())
+	}
+	if err := s.quotes.Create(ctx, &quote, createOpts...); err != nil {
+		return nil, s.status(err)
+	}
+	s.audit(ctx, "quote.created", quote.ID)
+	return &quotev1.CreateQuoteResponse{Quote: toProto(ctx, &quote)}, nil
+}
+
+func (s *Server) GetQuote(ctx context.Context, req *quotev1.GetQuoteRequest) (*quotev1.GetQuoteResponse, error) {
+	quote, err := s.quotes.GetByID(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, s.status(err)
+	}
+	return &quotev1.GetQuoteResponse{Quote: toProto(ctx, quote)}, nil
+}
+
+// ListQuotes передает цитаты потоком. В отличие от HTTP API, автор без цитат - пустой поток, а не NOT_FOUND.
+func (s *Server) ListQuotes(req *quotev1.ListQuotesRequest, stream grpc.ServerStreamingServer[quotev1.ListQuotesResponse]) error {
+	ctx := stream.Context()
+	var (
+		quotes []models.Quote
+		err    error
+	)
+	if req.GetAuthor() != "" {
+		quotes, err = s.quotes.GetByAuthor(ctx, req.GetAuthor(), req.GetStatuses()...)
+	} else {
+		quotes, err = s.quotes.GetAll(ctx, req.GetStatuses()...)
+	}
+	if err != nil {
+		if err == domain.ErrInvalidInput {
+			err = invalid(violation("statuses", violationOutOfRange, "unknown status"))
+		}
+		return s.status(err)
+	}
+	for i := range quotes {
+		if err := stream.Send(&quotev1.ListQuotesResponse{Quote: toProto(ctx, &quotes[i])}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) GetRandomQuote(ctx context.Context, _ *quotev1.GetRandomQuoteRequest) (*quotev1.GetRandomQuoteResponse, error) {
+	quote, err := s.quotes.GetRandom(ctx)
+	if err != nil {
+		return nil, s.status(err)
+	}
+	return &quotev1.GetRandomQuoteResponse{Quote: toProto(ctx, quote)}, nil
+}
+
+func (s *Server) DeleteQuote(ctx context.Context, req *quotev1.DeleteQuoteRequest) (*quotev1.DeleteQuoteResponse, error) {
+	if err := s.quotes.Delete(ctx, int(req.GetId())); err != nil {
+		return nil, s.status(err)
+	}
+	s.audit(ctx, "quote.deleted", int(req.GetId()))
+	return &quotev1.DeleteQuoteResponse{}, nil
+}
+
+func (s *Server) SearchQuotes(ctx context.Context, req *quotev1.SearchQuotesRequest) (*quotev1.SearchQuotesResponse, error) {
+	if violations := required(nil, "query", req.GetQuery()); len(violations) > 0 {
+		return nil, s.status(invalid(violations...))
+	}
+	quotes, err := s.quotes.Search(ctx, req.GetQuery(), int(req.GetLimit()))
+	if err != nil {
+		return nil, s.status(err)
+	}
+	resp := &quotev1.SearchQuotesResponse{Quotes: make([]*quotev1.Quote, len(quotes))}
+	for i := range quotes {
+		resp.Quotes[i] = toProto(ctx, &quotes[i])
+	}
+	return resp, nil
+}
+
+func (s *Server) audit(ctx context.Context, action string, quoteID int) {
+	actor := "anonymous"
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		actor = principal.ID
+	}
+	s.logger.Info("Аудит",
+		zap.String("action", action),
+		zap.Int("quote_id", quoteID),
+		zap.String("actor", actor),
+		zap.String("transport", "grpc"),
+	)
+}
+
+func toProto(ctx context.Context, q *models.Quote) *quotev1.Quote {
+	return &quotev1.Quote{
+		Id:               int64(q.ID),
+		Author:           q.Author,
+		Quote:            q.Quote,
+		CreatedAt:        timestamppb.New(q.CreatedAt),
+		OwnerId:          ownerOf(ctx, q),
+		Status:           q.Status,
+		ModerationReason: q.ModerationReason,
+		AvgRating:        q.AvgRating,
+		RatingsCount:     int32(q.RatingsCount),
+		Likes:            int32(q.Likes),
+		Views: &quotev1.ViewStats{
+			Get:    q.Views.Get,
+			Random: q.Views.Random,
+			Daily:  q.Views.Daily,
+		},
+	}
+}
+
+// ownerOf раскрывает создателя цитаты только ему самому и модераторам
+func ownerOf(ctx context.Context, q *models.Quote) string {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok || q.OwnerID == "" {
+		return ""
+	}
+	if principal.ID == q.OwnerID || principal.HasRole(auth.RoleModerator) {
+		return q.OwnerID
+	}
+	return ""
+}
@@ -0,0 +1,334 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"quote-service/internal/api/grpcapi/quotev1"
+	"quote-service/internal/auth"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/internal/service"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// memQuerier - хранилище цитат в памяти
+type memQuerier struct {
+	mu     sync.Mutex
+	quotes []models.Quote
+	err    error
+}
+
+func (m *memQuerier) Create(_ context.Context, quote *models.Quote) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	quote.ID = len(m.quotes) + 1
+	quote.CreatedAt = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	m.quotes = append(m.quotes, *quote)
+	return nil
+}
+
+func (m *memQuerier) GetAll(context.Context, ...string) ([]models.Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]models.Quote(nil), m.quotes...), m.err
+}
+
+func (m *memQuerier) GetRandom(context.Context, ...string) (*models.Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.quotes) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	q := m.quotes[0]
+	return &q, nil
+}
+
+func (m *memQuerier) GetByID(_ context.Context, id int) (*models.Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, q := range m.quotes {
+		if q.ID == id {
+			return &q, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (m *memQuerier) GetDaily(ctx context.Context, _ time.Time) (*models.Quote, error) {
+	return m.GetRandom(ctx)
+}
+
+func (m *memQuerier) GetByAuthor(_ context.Context, author string, _ ...string) ([]models.Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var quotes []models.Quote
+	for _, q := range m.quotes {
+		if q.Author == author {
+			quotes = append(quotes, q)
+		}
+	}
+	return quotes, nil
+}
+
+func (m *memQuerier) Update(context.Context, *models.Quote) error { return nil }
+
+func (m *memQuerier) Delete(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, q := range m.quotes {
+		if q.ID == id {
+			m.quotes = append(m.quotes[:i], m.quotes[i+1:]...)
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+// TermCandidates возвращает кандидатами в дубликаты все цитаты
+func (m *memQuerier) TermCandidates(_ context.Context, terms [][]string, _ int, _ ...string) ([][]models.Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	candidates := make([][]models.Quote, len(terms))
+	for i := range terms {
+		candidates[i] = append([]models.Quote(nil), m.quotes...)
+	}
+	return candidates, nil
+}
+
+func (m *memQuerier) Exists(_ context.Context, author, quote string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, q := range m.quotes {
+		if q.Author == author && q.Quote == quote {
+			return true, nil
+		}
+	}
+	return false, m.err
+}
+
+type staticKeys map[string]*auth.Principal
+
+func (k staticKeys) Authenticate(_ context.Context, key string) (*auth.Principal, error) {
+	if p, ok := k[key]; ok {
+		return p, nil
+	}
+	return nil, domain.ErrUnauthorized
+}
+
+// dial запускает сервер на bufconn и возвращает подключенного клиента
+func dial(t *testing.T, srv *grpc.Server) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestServer(t *testing.T) {
+	repo := &memQuerier{}
+	conn := dial(t, NewServer(service.NewQuoteService(repo, service.WithDuplicateDetection(repo, 0.8)), zap.NewNop()))
+	client := quotev1.NewQuoteServiceClient(conn)
+	ctx := context.Background()
+
+	t.Run("create and get", func(t *testing.T) {
+		created, err := client.CreateQuote(ctx, &quotev1.CreateQuoteRequest{Author: " Confucius ", Quote: "Life is simple"})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "Confucius", created.GetQuote().GetAuthor())
+
+		got, err := client.GetQuote(ctx, &quotev1.GetQuoteRequest{Id: created.GetQuote().GetId()})
+		assert.NoError(t, err)
+		assert.Equal(t, "Life is simple", got.GetQuote().GetQuote())
+		assert.Equal(t, int64(1717243200), got.GetQuote().GetCreatedAt().GetSeconds())
+	})
+
+	t.Run("list streams quotes", func(t *testing.T) {
+		_, err := client.CreateQuote(ctx, &quotev1.CreateQuoteRequest{Author: "Seneca", Quote: "Luck is preparation"})
+		assert.NoError(t, err)
+
+		stream, err := client.ListQuotes(ctx, &quotev1.ListQuotesRequest{})
+		assert.NoError(t, err)
+		var authors []string
+		for {
+			resp, err := stream.Recv()
+			if err == io.EOF {
+				break
+			}
+			if !assert.NoError(t, err) {
+				return
+			}
+			authors = append(authors, resp.GetQuote().GetAuthor())
+		}
+		assert.Equal(t, []string{"Confucius", "Seneca"}, authors)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := client.GetQuote(ctx, &quotev1.GetQuoteRequest{Id: 404})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		_, err := client.CreateQuote(ctx, &quotev1.CreateQuoteRequest{Author: "Seneca", Quote: "Luck is preparation"})
+		assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	})
+
+	t.Run("field violations", func(t *testing.T) {
+		_, err := client.CreateQuote(ctx, &quotev1.CreateQuoteRequest{Quote: "No author"})
+		st := status.Convert(err)
+		assert.Equal(t, codes.InvalidArgument, st.Code())
+		var fields []string
+		for _, d := range st.Details() {
+			switch d := d.(type) {
+			case *errdetails.ErrorInfo:
+				assert.Equal(t, string(domain.CodeValidation), d.GetReason())
+			case *errdetails.BadRequest:
+				for _, v := range d.GetFieldViolations() {
+					fields = append(fields, v.GetField())
+				}
+			}
+		}
+		assert.Equal(t, []string{"author"}, fields)
+	})
+
+	t.Run("internal error hidden", func(t *testing.T) {
+		repo.err = errors.New("connection refused")
+		defer func() { repo.err = nil }()
+
+		_, err := client.CreateQuote(ctx, &quotev1.CreateQuoteRequest{Author: "Plato", Quote: "Know thyself"})
+		st := status.Convert(err)
+		assert.Equal(t, codes.Internal, st.Code())
+		assert.Equal(t, "internal error", st.Message())
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		_, err := client.DeleteQuote(ctx, &quotev1.DeleteQuoteRequest{Id: 2})
+		assert.NoError(t, err)
+		_, err = client.DeleteQuote(ctx, &quotev1.DeleteQuoteRequest{Id: 2})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("search without index", func(t *testing.T) {
+		_, err := client.SearchQuotes(ctx, &quotev1.SearchQuotesRequest{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = client.SearchQuotes(ctx, &quotev1.SearchQuotesRequest{Query: "luck"})
+		assert.Equal(t, codes.Unimplemented, status.Code(err))
+	})
+
+	t.Run("health", func(t *testing.T) {
+		resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: "quote.v1.QuoteService"})
+		assert.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+	})
+}
+
+func TestServer_Auth(t *testing.T) {
+	keys := staticKeys{
+		"reader": {ID: "key:1", Scopes: []string{auth.ScopeRead}},
+		"writer": {ID: "key:2", Scopes: []string{auth.ScopeRead, auth.ScopeWrite}},
+	}
+	srv := NewServer(service.NewQuoteService(&memQuerier{}), zap.NewNop(), WithAuth(keys, nil, true))
+	client := quotev1.NewQuoteServiceClient(dial(t, srv))
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), apiKeyMetadata, key)
+	}
+	req := &quotev1.CreateQuoteRequest{Author: "Confucius", Quote: "Life is simple"}
+
+	_, err := client.CreateQuote(context.Background(), req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.CreateQuote(withKey("unknown"), req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.CreateQuote(withKey("reader"), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	created, err := client.CreateQuote(withKey("writer"), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "key:2", created.GetQuote().GetOwnerId())
+
+	// создатель виден только ему самому
+	got, err := client.GetQuote(withKey("writer"), &quotev1.GetQuoteRequest{Id: created.GetQuote().GetId()})
+	assert.NoError(t, err)
+	assert.Equal(t, "key:2", got.GetQuote().GetOwnerId())
+	got, err = client.GetQuote(withKey("reader"), &quotev1.GetQuoteRequest{Id: created.GetQuote().GetId()})
+	assert.NoError(t, err)
+	assert.Empty(t, got.GetQuote().GetOwnerId())
+
+	// чтение открыто публично
+	_, err = client.GetRandomQuote(context.Background(), &quotev1.GetRandomQuoteRequest{})
+	assert.NoError(t, err)
+}
+
+// countingLimiter пропускает limit запросов каждого клиента
+type countingLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	clients map[string]int
+	writes  int
+}
+
+func (l *countingLimiter) Limit(client string, write bool) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if write {
+		l.writes++
+	}
+	l.clients[client]++
+	if l.clients[client] > l.limit {
+		return 3 * time.Second, domain.NewError(domain.CodeRateLimited, "rate limit exceeded")
+	}
+	return 0, nil
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	keys := staticKeys{"writer": {ID: "key:2", Scopes: []string{auth.ScopeRead, auth.ScopeWrite}}}
+	limiter := &countingLimiter{limit: 1, clients: make(map[string]int)}
+	srv := NewServer(service.NewQuoteService(&memQuerier{}), zap.NewNop(), WithAuth(keys, nil, true), WithRateLimit(limiter))
+	conn := dial(t, srv)
+	client := quotev1.NewQuoteServiceClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), apiKeyMetadata, "writer")
+
+	_, err := client.CreateQuote(ctx, &quotev1.CreateQuoteRequest{Author: "Confucius", Quote: "Life is simple"})
+	assert.NoError(t, err)
+
+	var header metadata.MD
+	_, err = client.GetRandomQuote(ctx, &quotev1.GetRandomQuoteRequest{}, grpc.Header(&header))
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Equal(t, []string{"3"}, header.Get(retryAfterMetadata))
+	// лимит считается по клиенту после аутентификации
+	assert.Equal(t, 2, limiter.clients["key:2"])
+	assert.Equal(t, 1, limiter.writes)
+
+	// health не ограничивается
+	_, err = healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+}
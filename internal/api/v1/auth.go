@@ -0,0 +1,133 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"quote-service/internal/auth"
+	"quote-service/internal/domain"
+
+	"go.uber.org/zap"
+)
+
+// apiKeyHeader - заголовок, в котором клиент передает API-ключ
+const apiKeyHeader = "X-API-Key"
+
+type KeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (*auth.Principal, error)
+}
+
+type TokenVerifier interface {
+	Verify(token string) (*auth.Principal, error)
+}
+
+// AuthMiddleware определяет клиента по API-ключу или bearer-токену и проверяет область доступа:
+// изменяющие запросы требуют write, чтение требует read, если чтение не открыто публично
+type AuthMiddleware struct {
+	logger      *zap.Logger
+	keys        KeyAuthenticator
+	tokens      TokenVerifier
+	publicReads bool
+}
+
+type AuthOption func(*AuthMiddleware)
+
+// WithBearerTokens принимает JWT в заголовке Authorization: Bearer
+func WithBearerTokens(tokens TokenVerifier) AuthOption {
+	return func(m *AuthMiddleware) {
+		m.tokens = tokens
+	}
+}
+
+func NewAuthMiddleware(keys KeyAuthenticator, publicReads bool, logger *zap.Logger, opts ...AuthOption) *AuthMiddleware {
+	m := &AuthMiddleware{
+		logger:      logger,
+		keys:        keys,
+		publicReads: publicReads,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func (m *AuthMiddleware) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := bearerToken(r); ok && m.tokens != nil {
+			principal, err := m.tokens.Verify(token)
+			if err != nil {
+				m.logger.Info("Недействительный токен", zap.Error(err))
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				sendError(w, r, m.logger, domain.NewError(domain.CodeUnauthorized, "invalid token"))
+				return
+			}
+			r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+		} else if key := r.Header.Get(apiKeyHeader); key != "" && m.keys != nil {
+			principal, err := m.keys.Authenticate(r.Context(), key)
+			if err != nil {
+				if err == domain.ErrUnauthorized {
+					err = domain.NewError(domain.CodeUnauthorized, "invalid api key")
+				}
+				sendError(w, r, m.logger, err)
+				return
+			}
+			r = r.WithContext(auth.WithPrincipal(r.Context(), principal))
+		}
+
+		scope := requiredScope(r.Method)
+		if scope == auth.ScopeRead && m.publicReads {
+			next.ServeHTTP(w, r)
+			return
+		}
+		principal, ok := auth.PrincipalFrom(r.Context())
+		if !ok {
+			sendError(w, r, m.logger, domain.NewError(domain.CodeUnauthorized, "authentication required"))
+			return
+		}
+		if !principal.HasScope(scope) {
+			m.logger.Info("Недостаточно прав", zap.String("principal", principal.ID), zap.String("scope", scope))
+			sendError(w, r, m.logger, domain.NewError(domain.CodeForbidden, "insufficient scope"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireScope пропускает только клиентов с областью scope, например служебные маршруты для admin.
+// Ставится после AuthMiddleware, которая определяет клиента.
+func RequireScope(scope string, logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.PrincipalFrom(r.Context())
+			if !ok {
+				sendError(w, r, logger, domain.NewError(domain.CodeUnauthorized, "authentication required"))
+				return
+			}
+			if !principal.HasScope(scope) {
+				logger.Info("Недостаточно прав", zap.String("principal", principal.ID), zap.String("scope", scope))
+				sendError(w, r, logger, domain.NewError(domain.CodeForbidden, "insufficient scope"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func requiredScope(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return auth.ScopeRead
+	default:
+		return auth.ScopeWrite
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if len(header) < len("Bearer ") || !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(header[len("Bearer "):])
+	return token, token != ""
+}
@@ -0,0 +1,133 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/internal/service"
+
+	"go.uber.org/zap"
+)
+
+// maxBatchOps - наибольшее число операций в одном пакете
+const maxBatchOps = 500
+
+type batchRequest struct {
+	// Atomic = true: первая ошибка откатывает весь пакет, иначе каждая операция выполняется независимо
+	Atomic     bool             `json:"atomic"`
+	Operations []batchOperation `json:"operations"`
+}
+
+type batchOperation struct {
+	Op     string `json:"op"`
+	ID     int    `json:"id"`
+	Author string `json:"author"`
+	Quote  string `json:"quote"`
+}
+
+func (req batchRequest) Validate() []domain.Violation {
+	var violations []domain.Violation
+	switch {
+	case len(req.Operations) == 0:
+		return []domain.Violation{violation("operations", ViolationRequired, "is required")}
+	case len(req.Operations) > maxBatchOps:
+		return []domain.Violation{violation("operations", ViolationOutOfRange, fmt.Sprintf("must contain at most %d operations", maxBatchOps))}
+	}
+	for i, op := range req.Operations {
+		field := func(name string) string {
+			return fmt.Sprintf("operations[%d].%s", i, name)
+		}
+		switch op.Op {
+		case service.BatchCreate:
+			violations = required(violations, field("author"), op.Author)
+			violations = required(violations, field("quote"), op.Quote)
+		case service.BatchUpdate:
+			if op.ID <= 0 {
+				violations = append(violations, violation(field("id"), ViolationRequired, "is required"))
+			}
+			violations = required(violations, field("author"), op.Author)
+			violations = required(violations, field("quote"), op.Quote)
+		case service.BatchDelete:
+			if op.ID <= 0 {
+				violations = append(violations, violation(field("id"), ViolationRequired, "is required"))
+			}
+		case "":
+			violations = append(violations, violation(field("op"), ViolationRequired, "is required"))
+		default:
+			violations = append(violations, violation(field("op"), ViolationOutOfRange, "must be one of create, update, delete"))
+		}
+	}
+	return violations
+}
+
+// batchResult - итог одной операции: HTTP-статус, который вернул бы одиночный запрос, и цитата или ошибка
+type batchResult struct {
+	Index  int           `json:"index"`
+	Op     string        `json:"op"`
+	Status int           `json:"status"`
+	Data   *models.Quote `json:"data,omitempty"`
+	Error  *Problem      `json:"error,omitempty"`
+}
+
+// batchQuotes выполняет пакет операций в одной транзакции. Ответ 200, если все операции выполнены, иначе 207.
+func (h *Handler) batchQuotes(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	ops := make([]service.BatchOp, len(req.Operations))
+	for i, op := range req.Operations {
+		ops[i] = service.BatchOp{Op: op.Op, Quote: models.Quote{ID: op.ID, Author: op.Author, Quote: op.Quote}}
+	}
+	results, err := h.service.Batch(r.Context(), ops, req.Atomic)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	status, committed := http.StatusOK, true
+	resp := make([]batchResult, len(results))
+	for i, res := range results {
+		resp[i] = batchResult{Index: i, Op: ops[i].Op}
+		if res.Err != nil {
+			p := problemFor(r, res.Err)
+			if p.Code == domain.CodeInternal {
+				h.logger.Error("Ошибка операции пакета", zap.Int("index", i), zap.Error(res.Err))
+			}
+			resp[i].Status, resp[i].Error = p.Status, &p
+			status = http.StatusMultiStatus
+			committed = committed && !req.Atomic
+			continue
+		}
+		resp[i].Status, resp[i].Data = batchStatus(ops[i].Op, res.Quote), res.Quote
+		id := ops[i].Quote.ID
+		if res.Quote != nil {
+			id = res.Quote.ID
+		}
+		h.audit(r, "quote."+ops[i].Op+"d", id) // quote.created, quote.updated, quote.deleted
+	}
+	if !committed {
+		h.logger.Info("Пакет операций отменен", zap.Int("operations", len(ops)))
+	}
+	h.sendJSON(w, status, map[string]interface{}{
+		"atomic":    req.Atomic,
+		"committed": committed,
+		"results":   resp,
+	})
+}
+
+func batchStatus(op string, quote *models.Quote) int {
+	switch {
+	case op == service.BatchCreate && quote.Status == models.StatusPending:
+		return http.StatusAccepted
+	case op == service.BatchCreate:
+		return http.StatusCreated
+	case op == service.BatchUpdate && quote.Status == models.StatusPending:
+		return http.StatusAccepted
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,121 @@
+package v1
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"quote-service/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// WithListCaching разрешает хранить списки цитат maxAge без повторного запроса. public означает,
+// что списки читаются без аутентификации и их можно хранить в общих кэшах (CDN).
+// Без опции списки хранит только браузер и проверяет каждый раз, неизменившийся список отвечает 304.
+func WithListCaching(maxAge time.Duration, public bool) Option {
+	return func(h *Handler) {
+		h.listMaxAge = maxAge
+		h.publicLists = public
+	}
+}
+
+// noStore запрещает кэшировать ответ: каждый запрос случайной цитаты должен доходить до сервиса
+func noStore(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+}
+
+// sendList отвечает списком цитат с валидаторами ETag и Last-Modified и на условный запрос
+// с неизменившимся списком отвечает 304 без тела. Списки со статусами модерации видны
+// не всем, поэтому (shared == false) их хранит только браузер и проверяет каждый раз.
+func (h *Handler) sendList(w http.ResponseWriter, r *http.Request, quotes []models.Quote, shared bool) {
+	header := w.Header()
+	varyAccept(w)
+	body, contentType, err := h.formats.render(r, func(renderer Renderer, w io.Writer) error {
+		return renderer.RenderQuotes(w, quotes)
+	})
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	cacheControl := "private"
+	if shared && h.publicLists {
+		cacheControl = "public"
+	}
+	if shared && h.listMaxAge > 0 {
+		cacheControl += ", max-age=" + strconv.Itoa(int(h.listMaxAge.Seconds()))
+	} else {
+		cacheControl += ", no-cache"
+	}
+	header.Set("Cache-Control", cacheControl)
+	// Тело включает рейтинги и просмотры и зависит от формата, поэтому ETag считается по нему
+	sum := sha256.Sum256(body.Bytes())
+	etag := `W/"` + base64.RawURLEncoding.EncodeToString(sum[:18]) + `"`
+	header.Set("ETag", etag)
+	// Оценки, лайки и просмотры меняются без изменения цитат, поэтому у списка с ними нет даты изменения
+	var modified time.Time
+	if h.ratings == nil && !h.views {
+		modified = lastModified(quotes)
+	}
+	if !modified.IsZero() {
+		header.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if notModified(r, etag, modified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	header.Set("Content-Type", contentType)
+	if _, err := w.Write(body.Bytes()); err != nil {
+		h.logger.Error("Ошибка отправки ответа", zap.Error(err))
+	}
+}
+
+// lastModified - самое позднее время создания или изменения цитат списка
+func lastModified(quotes []models.Quote) time.Time {
+	var latest time.Time
+	for _, q := range quotes {
+		if q.CreatedAt.After(latest) {
+			latest = q.CreatedAt
+		}
+		if q.UpdatedAt != nil && q.UpdatedAt.After(latest) {
+			latest = *q.UpdatedAt
+		}
+	}
+	return latest
+}
+
+// notModified проверяет условия запроса. If-None-Match важнее If-Modified-Since: удаление цитаты
+// не сдвигает Last-Modified, но меняет ETag.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || modified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !modified.Truncate(time.Second).After(since)
+}
+
+// etagMatches сравнивает ETag из If-None-Match со списком значений слабым сравнением
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,262 @@
+package v1
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+type CollectionHandler struct {
+	logger  *zap.Logger
+	service *service.CollectionService
+	// formats - форматы случайной цитаты, те же, что у встроенных форматов /quotes
+	formats formats
+}
+
+// NewCollectionHandler создает обработчик подборок. policy проверяет права на изменение подборок;
+// nil отключает проверку (аутентификация выключена).
+func NewCollectionHandler(repo service.CollectionRepository, policy service.CollectionPolicy, logger *zap.Logger) *CollectionHandler {
+	var opts []service.CollectionOption
+	if policy != nil {
+		opts = append(opts, service.WithCollectionPolicy(policy))
+	}
+	return &CollectionHandler{
+		logger:  logger,
+		service: service.NewCollectionService(repo, opts...),
+		formats: defaultFormats(),
+	}
+}
+
+func (h *CollectionHandler) Routes() *chi.Mux {
+	r := chi.NewRouter()
+	r.Post("/", h.createCollection)                   // POST /collections
+	r.Get("/", h.getCollections)                      // GET /collections
+	r.Get("/{id}", h.getCollection)                   // GET /collections/{id}
+	r.Put("/{id}", h.updateCollection)                // PUT /collections/{id}
+	r.Delete("/{id}", h.deleteCollection)             // DELETE /collections/{id}
+	r.Get("/{id}/random", h.getRandomFromCollection)  // GET /collections/{id}/random
+	r.Post("/{id}/quotes", h.addQuote)                // POST /collections/{id}/quotes
+	r.Delete("/{id}/quotes/{quoteID}", h.removeQuote) // DELETE /collections/{id}/quotes/{quoteID}
+	r.Put("/{id}/order", h.reorderCollection)         // PUT /collections/{id}/order
+	return r
+}
+
+type collectionRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	QuoteIDs    []int  `json:"quote_ids"`
+}
+
+func (req collectionRequest) Validate() []domain.Violation {
+	return required(nil, "name", req.Name)
+}
+
+type collectionQuoteRequest struct {
+	QuoteID int `json:"quote_id"`
+}
+
+func (req collectionQuoteRequest) Validate() []domain.Violation {
+	if req.QuoteID <= 0 {
+		return []domain.Violation{violation("quote_id", ViolationRequired, "is required")}
+	}
+	return nil
+}
+
+type collectionOrderRequest struct {
+	QuoteIDs []int `json:"quote_ids"`
+}
+
+func (req collectionOrderRequest) Validate() []domain.Violation {
+	if req.QuoteIDs == nil {
+		return []domain.Violation{violation("quote_ids", ViolationRequired, "is required")}
+	}
+	return nil
+}
+
+func (h *CollectionHandler) createCollection(w http.ResponseWriter, r *http.Request) {
+	var req collectionRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	c := models.Collection{Name: req.Name, Description: req.Description, QuoteIDs: req.QuoteIDs}
+	if err := h.service.Create(r.Context(), &c); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.send(w, http.StatusCreated, c)
+}
+
+func (h *CollectionHandler) getCollections(w http.ResponseWriter, r *http.Request) {
+	collections, err := h.service.GetAll(r.Context())
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	if collections == nil {
+		collections = []models.Collection{}
+	}
+	h.send(w, http.StatusOK, collections)
+}
+
+func (h *CollectionHandler) getCollection(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.urlID(w, r, "id")
+	if !ok {
+		return
+	}
+	c, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.send(w, http.StatusOK, c)
+}
+
+func (h *CollectionHandler) updateCollection(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.urlID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req collectionRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	c := models.Collection{ID: id, Name: req.Name, Description: req.Description}
+	if err := h.service.Update(r.Context(), &c); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	updated, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.send(w, http.StatusOK, updated)
+}
+
+func (h *CollectionHandler) deleteCollection(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.urlID(w, r, "id")
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(r.Context(), id); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Collection deleted successfully",
+	})
+}
+
+func (h *CollectionHandler) getRandomFromCollection(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.urlID(w, r, "id")
+	if !ok {
+		return
+	}
+	quote, err := h.service.GetRandom(r.Context(), id)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	noStore(w)
+	sendRendered(w, r, h.logger, h.formats, http.StatusOK, func(renderer Renderer, w io.Writer) error {
+		return renderer.RenderQuote(w, quote)
+	})
+}
+
+func (h *CollectionHandler) addQuote(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.urlID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req collectionQuoteRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	if err := h.service.AddQuote(r.Context(), id, req.QuoteID); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.sendCollection(w, r, id)
+}
+
+func (h *CollectionHandler) removeQuote(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.urlID(w, r, "id")
+	if !ok {
+		return
+	}
+	quoteID, ok := h.urlID(w, r, "quoteID")
+	if !ok {
+		return
+	}
+	if err := h.service.RemoveQuote(r.Context(), id, quoteID); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.sendCollection(w, r, id)
+}
+
+func (h *CollectionHandler) reorderCollection(w http.ResponseWriter, r *http.Request) {
+	id, ok := h.urlID(w, r, "id")
+	if !ok {
+		return
+	}
+	var req collectionOrderRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	if err := h.service.Reorder(r.Context(), id, req.QuoteIDs); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.sendCollection(w, r, id)
+}
+
+func (h *CollectionHandler) sendCollection(w http.ResponseWriter, r *http.Request, id int) {
+	c, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.send(w, http.StatusOK, c)
+}
+
+func (h *CollectionHandler) urlID(w http.ResponseWriter, r *http.Request, param string) (int, bool) {
+	id, err := urlInt(r, param)
+	if err != nil {
+		h.sendError(w, r, err)
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *CollectionHandler) send(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	response := map[string]interface{}{
+		"data": data,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Ошибка кодирования ответа", zap.Error(err))
+	}
+}
+
+func (h *CollectionHandler) sendError(w http.ResponseWriter, r *http.Request, err error) {
+	sendError(w, r, h.logger, err)
+}
@@ -2,11 +2,11 @@ package v1
 
 import (
 	"encoding/json"
-	"fmt"
-	"io"
 	"net/http"
 	"strconv"
+	"time"
 
+	"quote-service/internal/auth"
 	"quote-service/internal/domain"
 	"quote-service/internal/models"
 	"quote-service/internal/service"
@@ -16,176 +16,362 @@ import (
 )
 
 type Handler struct {
-	logger  *zap.Logger
-	service *service.QuoteService
+	logger     *zap.Logger
+	service    *service.QuoteService
+	ratings    *service.RatingService
+	ratingRepo service.RatingRepository
+	views      bool
+	similar    bool
+	moderation bool
+	batch      bool
+
+	idempotency *Idempotency
+	events      *service.EventBroker
+	heartbeat   time.Duration
+	listMaxAge  time.Duration
+	publicLists bool
+	formats     formats
+	serviceOpts []service.Option
+}
+
+// Option подключает к обработчику дополнительные возможности
+type Option func(*Handler)
+
+// WithRatings включает оценки, лайки и GET /quotes/top
+func WithRatings(repo service.RatingRepository) Option {
+	return func(h *Handler) {
+		h.ratingRepo = repo
+		h.serviceOpts = append(h.serviceOpts, service.WithRatingStats(repo))
+	}
+}
+
+// WithViews включает подсчет выдач цитат и GET /quotes/most-viewed
+func WithViews(counter *service.ViewCounter) Option {
+	return func(h *Handler) {
+		h.views = true
+		h.serviceOpts = append(h.serviceOpts, service.WithViewCounter(counter))
+	}
+}
+
+// WithSimilarity включает GET /quotes/{id}/similar
+func WithSimilarity(index service.TermIndex) Option {
+	return func(h *Handler) {
+		h.similar = true
+		h.serviceOpts = append(h.serviceOpts, service.WithSimilarity(index))
+	}
+}
+
+// WithModeration включает очередь модерации, GET /quotes/{id}/status и ModerationRoutes
+func WithModeration(repo service.ModerationRepository) Option {
+	return func(h *Handler) {
+		h.moderation = true
+		h.serviceOpts = append(h.serviceOpts, service.WithModeration(repo))
+	}
+}
+
+// WithBatch включает POST /quotes/batch: пакет операций выполняется в одной транзакции
+func WithBatch(tx service.Transactor) Option {
+	return func(h *Handler) {
+		h.batch = true
+		h.serviceOpts = append(h.serviceOpts, service.WithTransactions(tx))
+	}
 }
 
-func NewHandler(db service.Querier, logger *zap.Logger) *Handler {
-	return &Handler{
-		logger:  logger,
-		service: service.NewQuoteService(db),
+// WithIdempotency включает Idempotency-Key для POST /quotes: первый ответ хранится ttl,
+// а ключ выполняющегося запроса занят не дольше lease
+func WithIdempotency(store IdempotencyStore, ttl, lease time.Duration) Option {
+	return func(h *Handler) {
+		h.idempotency = NewIdempotency(store, ttl, lease, h.logger)
 	}
 }
 
+// WithServiceOptions передает настройки в QuoteService
+func WithServiceOptions(opts ...service.Option) Option {
+	return func(h *Handler) {
+		h.serviceOpts = append(h.serviceOpts, opts...)
+	}
+}
+
+func NewHandler(db service.Querier, logger *zap.Logger, opts ...Option) *Handler {
+	h := &Handler{logger: logger, formats: defaultFormats()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.service = service.NewQuoteService(db, h.serviceOpts...)
+	if h.ratingRepo != nil {
+		h.ratings = service.NewRatingService(h.ratingRepo, db)
+	}
+	return h
+}
+
+// Service возвращает QuoteService обработчика, чтобы другие API работали с теми же настройками
+func (h *Handler) Service() *service.QuoteService {
+	return h.service
+}
+
 func (h *Handler) Routes() *chi.Mux {
 	r := chi.NewRouter()
-	r.Post("/", h.createQuote)         // POST /quotes
-	r.Get("/", h.getAllQuotes)         // GET /quotes или GET /quotes?author={author}
-	r.Get("/random", h.getRandomQuote) // GET /quotes/random
-	r.Delete("/{id}", h.deleteQuote)   // DELETE /quotes/{id}
+	r.With(h.acceptable, h.idempotent).Post("/", h.createQuote) // POST /quotes
+	r.Get("/", h.getAllQuotes)                                  // GET /quotes или GET /quotes?author={author}
+	r.Get("/random", h.getRandomQuote)                          // GET /quotes/random
+	r.Get("/daily", h.getDailyQuote)                            // GET /quotes/daily
+	r.Get("/{id}", h.getQuote)                                  // GET /quotes/{id}
+	r.With(h.acceptable).Put("/{id}", h.updateQuote)            // PUT /quotes/{id}
+	r.Delete("/{id}", h.deleteQuote)                            // DELETE /quotes/{id}
+	if h.batch {
+		r.With(h.jsonOnly, h.idempotent).Post("/batch", h.batchQuotes) // POST /quotes/batch
+	}
+	if h.events != nil {
+		r.Get("/stream", h.streamQuotes) // GET /quotes/stream
+	}
+	if h.similar {
+		r.Get("/{id}/similar", h.getSimilarQuotes) // GET /quotes/{id}/similar?limit=5
+	}
+	if h.views {
+		r.Get("/most-viewed", h.getMostViewed) // GET /quotes/most-viewed?source=random
+	}
+	if h.moderation {
+		r.Get("/{id}/status", h.getModerationStatus) // GET /quotes/{id}/status
+	}
+	if h.ratings != nil {
+		r.Get("/top", h.getTopQuotes)                          // GET /quotes/top?period=week
+		r.With(h.jsonOnly).Post("/{id}/rating", h.rateQuote)   // POST /quotes/{id}/rating
+		r.With(h.jsonOnly).Put("/{id}/like", h.likeQuote)      // PUT /quotes/{id}/like
+		r.With(h.jsonOnly).Delete("/{id}/like", h.unlikeQuote) // DELETE /quotes/{id}/like
+	}
 	return r
 }
 
-func (h *Handler) createQuote(w http.ResponseWriter, r *http.Request) {
-	var quote models.Quote
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		h.logger.Error("Ошибка чтения тела запроса", zap.Error(err))
-		sendErrorResponse(w, domain.ErrInvalidInput.Error(), http.StatusBadRequest)
-		return
+// idempotent подключает Idempotency-Key, если он включен
+func (h *Handler) idempotent(next http.Handler) http.Handler {
+	if h.idempotency == nil {
+		return next
 	}
-	defer r.Body.Close()
-	h.logger.Info("Request body", zap.String("body", string(body)))
+	return h.idempotency.Handler(next)
+}
 
-	if err := json.Unmarshal(body, &quote); err != nil {
-		h.logger.Error("Ошибка декодирования запроса", zap.Error(err))
-		sendErrorResponse(w, domain.ErrInvalidInput.Error(), http.StatusBadRequest)
+func (h *Handler) createQuote(w http.ResponseWriter, r *http.Request) {
+	var req createQuoteRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		h.sendError(w, r, err)
 		return
 	}
+	quote := models.Quote{Author: req.Author, Quote: req.Quote, Tags: req.Tags}
 
-	exists, err := h.service.Exists(r.Context(), quote.Author, quote.Quote)
-	if err != nil {
-		if err == domain.ErrInvalidInput {
-			h.logger.Error("Неверный ввод для проверки существования цитаты", zap.Error(err))
-			sendErrorResponse(w, err.Error(), http.StatusBadRequest)
-		} else {
-			h.logger.Error("Ошибка проверки существования цитаты", zap.Error(err))
-			sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
-		}
-		return
-	}
-	if exists {
-		h.logger.Info("Цитата уже существует")
-		sendErrorResponse(w, "Quote already exists", http.StatusBadRequest)
-		return
+	var createOpts []service.CreateOption
+	if force, _ := strconv.ParseBool(r.URL.Query().Get("force")); force {
+		createOpts = append(createOpts, service.AllowDuplicates())
 	}
 
-	if err := h.service.Create(r.Context(), &quote); err != nil {
-		h.logger.Error("Ошибка создания цитаты", zap.Error(err))
-		sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+	if err := h.service.Create(r.Context(), &quote, createOpts...); err != nil {
+		h.sendError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	response := map[string]interface{}{
-		"data": quote,
-	}
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.logger.Error("Ошибка кодирования ответа", zap.Error(err))
+	h.audit(r, "quote.created", quote.ID)
+
+	status := http.StatusCreated
+	if quote.Status == models.StatusPending {
+		status = http.StatusAccepted
 	}
+	h.sendQuoteStatus(w, r, status, &quote)
 }
 
 func (h *Handler) getAllQuotes(w http.ResponseWriter, r *http.Request) {
 	author := r.URL.Query().Get("author")
+	statuses := requestStatuses(r)
 	if author == "" {
-		quotes, err := h.service.GetAll(r.Context())
+		quotes, err := h.service.GetAll(r.Context(), statuses...)
 		if err != nil {
-			h.logger.Error("Ошибка получения цитат", zap.Error(err))
-			sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+			if err == domain.ErrInvalidInput {
+				err = domain.NewError(domain.CodeValidation, "unknown status")
+			}
+			h.sendError(w, r, err)
 			return
 		}
-		w.Header().Set("Content-Type", "application/json")
-		response := map[string]interface{}{
-			"data": quotes,
-		}
-		if err := json.NewEncoder(w).Encode(response); err != nil {
-			h.logger.Error("Ошибка кодирования ответа", zap.Error(err))
+		if quotes == nil {
+			// пустой список - [], а не null, как описано в openapi.json
+			quotes = []models.Quote{}
 		}
+		h.sendList(w, r, quotes, len(statuses) == 0)
 		return
 	}
 
-	quotes, err := h.service.GetByAuthor(r.Context(), author)
+	quotes, err := h.service.GetByAuthor(r.Context(), author, statuses...)
 	if err != nil {
-		if err == domain.ErrInvalidInput {
-			h.logger.Error("Неверный ввод для получения цитат по автору", zap.Error(err))
-			sendErrorResponse(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		h.logger.Error("Ошибка получения цитат по автору", zap.Error(err))
-		sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		h.sendError(w, r, err)
 		return
 	}
 	if len(quotes) == 0 {
-		sendErrorResponse(w, "No quotes found for the specified author", http.StatusNotFound)
+		h.sendError(w, r, domain.NewError(domain.CodeNotFound, "no quotes found for the specified author"))
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	response := map[string]interface{}{
-		"data": quotes,
-	}
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.logger.Error("Ошибка кодирования ответа", zap.Error(err))
-	}
+	h.sendList(w, r, quotes, len(statuses) == 0)
 }
 
 func (h *Handler) getRandomQuote(w http.ResponseWriter, r *http.Request) {
 	quote, err := h.service.GetRandom(r.Context())
 	if err != nil {
-		if err == domain.ErrNotFound {
-			h.logger.Error("Цитаты не найдены", zap.Error(err))
-			sendErrorResponse(w, "No quotes found", http.StatusNotFound)
-			return
-		}
-		h.logger.Error("Ошибка получения случайной цитаты", zap.Error(err))
-		sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		h.sendError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	response := map[string]interface{}{
-		"data": quote,
+	noStore(w)
+	h.sendQuote(w, r, quote)
+}
+
+func (h *Handler) getQuote(w http.ResponseWriter, r *http.Request) {
+	id, err := urlInt(r, "id")
+	if err != nil {
+		h.sendError(w, r, err)
+		return
 	}
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.logger.Error("Ошибка кодирования ответа", zap.Error(err))
+
+	quote, err := h.service.GetByID(r.Context(), id)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
 	}
+	h.sendQuote(w, r, quote)
 }
 
-func (h *Handler) deleteQuote(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := strconv.Atoi(idStr)
+// quoteRequest - тело создания и изменения цитаты
+type quoteRequest struct {
+	Author string `json:"author"`
+	Quote  string `json:"quote"`
+}
+
+func (req quoteRequest) Validate() []domain.Violation {
+	var violations []domain.Violation
+	violations = required(violations, "author", req.Author)
+	return required(violations, "quote", req.Quote)
+}
+
+// createQuoteRequest - тело POST /quotes: теги задаются только при создании
+type createQuoteRequest struct {
+	Author string   `json:"author"`
+	Quote  string   `json:"quote"`
+	Tags   []string `json:"tags"`
+}
+
+func (req createQuoteRequest) Validate() []domain.Violation {
+	return quoteRequest{Author: req.Author, Quote: req.Quote}.Validate()
+}
+
+func (h *Handler) updateQuote(w http.ResponseWriter, r *http.Request) {
+	id, err := urlInt(r, "id")
 	if err != nil {
-		h.logger.Error("Неверный формат ID", zap.Error(err))
-		sendErrorResponse(w, domain.ErrInvalidInput.Error(), http.StatusBadRequest)
+		h.sendError(w, r, err)
 		return
 	}
 
-	if err := h.service.Delete(r.Context(), id); err != nil {
-		if err == domain.ErrNotFound {
-			h.logger.Error("Цитата не найдена", zap.Error(err))
-			sendErrorResponse(w, "Quote not found", http.StatusNotFound)
-			return
+	var req quoteRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	quote := models.Quote{ID: id, Author: req.Author, Quote: req.Quote}
+	if err := h.service.Update(r.Context(), &quote); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.audit(r, "quote.updated", id)
+
+	status := http.StatusOK
+	if quote.Status == models.StatusPending {
+		status = http.StatusAccepted
+	}
+	h.sendQuoteStatus(w, r, status, &quote)
+}
+
+func (h *Handler) getSimilarQuotes(w http.ResponseWriter, r *http.Request) {
+	id, err := urlInt(r, "id")
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	limit, err := queryInt(r, "limit", 0)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	quotes, err := h.service.Similar(r.Context(), id, limit)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.sendQuotes(w, r, quotes)
+}
+
+func (h *Handler) getDailyQuote(w http.ResponseWriter, r *http.Request) {
+	quote, err := h.service.GetDaily(r.Context())
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.sendQuote(w, r, quote)
+}
+
+func (h *Handler) getMostViewed(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", 0)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	quotes, err := h.service.MostViewed(r.Context(), r.URL.Query().Get("source"), limit)
+	if err != nil {
+		if err == domain.ErrInvalidInput {
+			err = domain.NewError(domain.CodeValidation, "unknown source")
 		}
-		h.logger.Error("Ошибка удаления цитаты", zap.Error(err))
-		sendErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		h.sendError(w, r, err)
+		return
+	}
+	if quotes == nil {
+		quotes = []models.Quote{}
+	}
+	h.sendQuotes(w, r, quotes)
+}
+
+func (h *Handler) deleteQuote(w http.ResponseWriter, r *http.Request) {
+	id, err := urlInt(r, "id")
+	if err != nil {
+		h.sendError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	response := map[string]string{
-		"message": fmt.Sprintf("Quote with ID %d deleted successfully", id),
+	if err := h.service.Delete(r.Context(), id); err != nil {
+		h.sendError(w, r, err)
+		return
 	}
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.logger.Error("Ошибка кодирования ответа", zap.Error(err))
+	h.audit(r, "quote.deleted", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// audit записывает в журнал, кто и что сделал с цитатой
+func (h *Handler) audit(r *http.Request, action string, quoteID int) {
+	actor := "anonymous"
+	if principal, ok := auth.PrincipalFrom(r.Context()); ok {
+		actor = principal.ID
 	}
+	h.logger.Info("Аудит",
+		zap.String("action", action),
+		zap.Int("quote_id", quoteID),
+		zap.String("actor", actor),
+	)
 }
 
-func sendErrorResponse(w http.ResponseWriter, message string, statusCode int) {
+func (h *Handler) sendJSON(w http.ResponseWriter, statusCode int, response interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
-	response := map[string]string{
-		"error": message,
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Ошибка кодирования ответа", zap.Error(err))
 	}
-	json.NewEncoder(w).Encode(response)
+}
+
+// sendError отвечает problem+json, см. problem.go
+func (h *Handler) sendError(w http.ResponseWriter, r *http.Request, err error) {
+	sendError(w, r, h.logger, err)
 }
@@ -1,16 +1,25 @@
 package v1
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"quote-service/internal/auth"
 	"quote-service/internal/domain"
 	"quote-service/internal/models"
+	"quote-service/internal/service"
+	"quote-service/pkg/ratelimit"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.uber.org/zap"
@@ -25,21 +34,36 @@ func (m *MockQuerier) Create(ctx context.Context, quote *models.Quote) error {
 	return args.Error(0)
 }
 
-func (m *MockQuerier) GetAll(ctx context.Context) ([]models.Quote, error) {
-	args := m.Called(ctx)
+func (m *MockQuerier) GetAll(ctx context.Context, statuses ...string) ([]models.Quote, error) {
+	args := m.Called(ctx, statuses)
 	return args.Get(0).([]models.Quote), args.Error(1)
 }
 
-func (m *MockQuerier) GetRandom(ctx context.Context) (*models.Quote, error) {
-	args := m.Called(ctx)
+func (m *MockQuerier) GetRandom(ctx context.Context, statuses ...string) (*models.Quote, error) {
+	args := m.Called(ctx, statuses)
+	return args.Get(0).(*models.Quote), args.Error(1)
+}
+
+func (m *MockQuerier) GetByID(ctx context.Context, id int) (*models.Quote, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Quote), args.Error(1)
+}
+
+func (m *MockQuerier) GetDaily(ctx context.Context, day time.Time) (*models.Quote, error) {
+	args := m.Called(ctx, day)
 	return args.Get(0).(*models.Quote), args.Error(1)
 }
 
-func (m *MockQuerier) GetByAuthor(ctx context.Context, author string) ([]models.Quote, error) {
-	args := m.Called(ctx, author)
+func (m *MockQuerier) GetByAuthor(ctx context.Context, author string, statuses ...string) ([]models.Quote, error) {
+	args := m.Called(ctx, author, statuses)
 	return args.Get(0).([]models.Quote), args.Error(1)
 }
 
+func (m *MockQuerier) Update(ctx context.Context, quote *models.Quote) error {
+	args := m.Called(ctx, quote)
+	return args.Error(0)
+}
+
 func (m *MockQuerier) Delete(ctx context.Context, id int) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
@@ -65,20 +89,19 @@ func TestHandler_CreateQuote(t *testing.T) {
 	}
 
 	t.Run("invalid input", func(t *testing.T) {
-		invalidQuote := models.Quote{Author: "", Quote: ""}
-		invalidJSON, err := json.Marshal(invalidQuote)
-		if err != nil {
-			t.Fatal(err)
-		}
-
-		mockQuerier.On("Exists", mock.Anything, "", "").Return(false, domain.ErrInvalidInput).Once()
-
-		req := httptest.NewRequest(http.MethodPost, "/quotes", bytes.NewReader(invalidJSON))
+		req := httptest.NewRequest(http.MethodPost, "/quotes", bytes.NewBufferString(`{"author": "", "quote": " "}`))
 		w := httptest.NewRecorder()
 
 		handler.createQuote(w, req)
 
 		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var p Problem
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		assert.Equal(t, []domain.Violation{
+			{Field: "author", Code: ViolationRequired, Message: "is required"},
+			{Field: "quote", Code: ViolationRequired, Message: "is required"},
+		}, p.Violations)
+		mockQuerier.AssertNotCalled(t, "Exists", mock.Anything, mock.Anything, mock.Anything)
 	})
 
 	t.Run("invalid json", func(t *testing.T) {
@@ -100,7 +123,7 @@ func TestHandler_GetAllQuotes(t *testing.T) {
 			{Author: "Confucius", Quote: "Life is simple"},
 			{Author: "Socrates", Quote: "Know thyself"},
 		}
-		mockQuerier.On("GetAll", mock.Anything).Return(quotes, nil).Once()
+		mockQuerier.On("GetAll", mock.Anything, mock.Anything).Return(quotes, nil).Once()
 
 		req := httptest.NewRequest(http.MethodGet, "/quotes", nil)
 		w := httptest.NewRecorder()
@@ -124,7 +147,7 @@ func TestHandler_GetAllQuotes(t *testing.T) {
 	})
 
 	t.Run("empty result", func(t *testing.T) {
-		mockQuerier.On("GetAll", mock.Anything).Return([]models.Quote{}, nil).Once()
+		mockQuerier.On("GetAll", mock.Anything, mock.Anything).Return([]models.Quote{}, nil).Once()
 
 		req := httptest.NewRequest(http.MethodGet, "/quotes", nil)
 		w := httptest.NewRecorder()
@@ -145,7 +168,7 @@ func TestHandler_GetAllQuotes(t *testing.T) {
 	t.Run("successful get by author", func(t *testing.T) {
 		author := "Confucius"
 		quotes := []models.Quote{{Author: author, Quote: "Life is simple"}}
-		mockQuerier.On("GetByAuthor", mock.Anything, author).Return(quotes, nil).Once()
+		mockQuerier.On("GetByAuthor", mock.Anything, author, mock.Anything).Return(quotes, nil).Once()
 
 		req := httptest.NewRequest(http.MethodGet, "/quotes?author="+author, nil)
 		w := httptest.NewRecorder()
@@ -169,7 +192,7 @@ func TestHandler_GetAllQuotes(t *testing.T) {
 	})
 
 	t.Run("invalid author", func(t *testing.T) {
-		mockQuerier.On("GetAll", mock.Anything).Return([]models.Quote{}, nil).Once()
+		mockQuerier.On("GetAll", mock.Anything, mock.Anything).Return([]models.Quote{}, nil).Once()
 
 		req := httptest.NewRequest(http.MethodGet, "/quotes?author=", nil)
 		w := httptest.NewRecorder()
@@ -188,7 +211,7 @@ func TestHandler_GetAllQuotes(t *testing.T) {
 	})
 
 	t.Run("error from GetAll", func(t *testing.T) {
-		mockQuerier.On("GetAll", mock.Anything).Return([]models.Quote{}, domain.ErrNotFound).Once()
+		mockQuerier.On("GetAll", mock.Anything, mock.Anything).Return([]models.Quote{}, errors.New("connection refused")).Once()
 
 		req := httptest.NewRequest(http.MethodGet, "/quotes", nil)
 		w := httptest.NewRecorder()
@@ -200,7 +223,7 @@ func TestHandler_GetAllQuotes(t *testing.T) {
 
 	t.Run("error from GetByAuthor", func(t *testing.T) {
 		author := "Confucius"
-		mockQuerier.On("GetByAuthor", mock.Anything, author).Return([]models.Quote{}, domain.ErrNotFound).Once()
+		mockQuerier.On("GetByAuthor", mock.Anything, author, mock.Anything).Return([]models.Quote{}, errors.New("connection refused")).Once()
 
 		req := httptest.NewRequest(http.MethodGet, "/quotes?author="+author, nil)
 		w := httptest.NewRecorder()
@@ -211,13 +234,229 @@ func TestHandler_GetAllQuotes(t *testing.T) {
 	})
 }
 
+func TestHandler_ListCaching(t *testing.T) {
+	created := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	updated := created.Add(48 * time.Hour)
+	quotes := []models.Quote{
+		{ID: 1, Author: "Seneca", Quote: "Luck is preparation", CreatedAt: created, UpdatedAt: &updated},
+		{ID: 2, Author: "Cicero", Quote: "While there is life", CreatedAt: created.Add(time.Hour)},
+	}
+	mockQuerier := new(MockQuerier)
+	mockQuerier.On("GetAll", mock.Anything, []string(nil)).Return(quotes, nil)
+	mockQuerier.On("GetAll", mock.Anything, []string{models.StatusPending}).Return(quotes, nil)
+	mockQuerier.On("GetByAuthor", mock.Anything, "Seneca", []string(nil)).Return(quotes[:1], nil)
+	handler := NewHandler(mockQuerier, zap.NewNop(), WithListCaching(time.Minute, true))
+	get := func(target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		handler.getAllQuotes(w, req)
+		return w
+	}
+
+	w := get("/quotes", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "Wed, 03 Jul 2024 10:00:00 GMT", w.Header().Get("Last-Modified"))
+	etag := w.Header().Get("ETag")
+	assert.True(t, strings.HasPrefix(etag, `W/"`))
+
+	t.Run("if-none-match", func(t *testing.T) {
+		w := get("/quotes", http.Header{"If-None-Match": {`"other", ` + strings.TrimPrefix(etag, "W/")}})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+
+		// ETag важнее даты: несовпадение означает новый ответ
+		w = get("/quotes", http.Header{"If-None-Match": {`W/"other"`}, "If-Modified-Since": {"Wed, 03 Jul 2024 10:00:00 GMT"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("if-modified-since", func(t *testing.T) {
+		w := get("/quotes", http.Header{"If-Modified-Since": {"Wed, 03 Jul 2024 10:00:00 GMT"}})
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		w = get("/quotes", http.Header{"If-Modified-Since": {"Tue, 02 Jul 2024 10:00:00 GMT"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("author listing has its own validators", func(t *testing.T) {
+		w := get("/quotes?author=Seneca", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+		assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+	})
+
+	t.Run("moderation statuses are private", func(t *testing.T) {
+		w := get("/quotes?status=pending", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "private, no-cache", w.Header().Get("Cache-Control"))
+	})
+
+	t.Run("aggregates have no modification date", func(t *testing.T) {
+		mockRatings := new(MockRatingRepository)
+		mockRatings.On("Stats", mock.Anything, []int{1, 2}).Return(map[int]models.RatingStats{1: {Likes: 3}}, nil)
+		handler := NewHandler(mockQuerier, zap.NewNop(), WithListCaching(time.Minute, true), WithRatings(mockRatings))
+
+		req := httptest.NewRequest(http.MethodGet, "/quotes", nil)
+		req.Header.Set("If-Modified-Since", "Wed, 03 Jul 2024 10:00:00 GMT")
+		w := httptest.NewRecorder()
+		handler.getAllQuotes(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Last-Modified"))
+		assert.NotEmpty(t, w.Header().Get("ETag"))
+	})
+}
+
+type upperRenderer struct{}
+
+func (upperRenderer) ContentType() string { return "text/x-upper" }
+
+func (upperRenderer) RenderQuote(w io.Writer, quote *models.Quote) error {
+	_, err := io.WriteString(w, strings.ToUpper(quote.Quote)+"\n")
+	return err
+}
+
+func (u upperRenderer) RenderQuotes(w io.Writer, quotes []models.Quote) error {
+	for i := range quotes {
+		if err := u.RenderQuote(w, &quotes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestHandler_ContentNegotiation(t *testing.T) {
+	created := time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC)
+	quotes := []models.Quote{
+		{ID: 1, Author: "Seneca", Quote: "Luck is <preparation>", CreatedAt: created},
+		{ID: 2, Author: "Cicero", Quote: "While there is life,\nthere is hope", CreatedAt: created},
+	}
+	mockQuerier := new(MockQuerier)
+	mockQuerier.On("GetAll", mock.Anything, []string(nil)).Return(quotes, nil)
+	mockQuerier.On("GetByID", mock.Anything, 1).Return(&quotes[0], nil)
+	handler := NewHandler(mockQuerier, zap.NewNop(), WithFormat("upper", upperRenderer{}, "text/x-upper"))
+	get := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		handler.Routes().ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("json by default", func(t *testing.T) {
+		for _, accept := range []string{"", "*/*", "application/json, text/plain"} {
+			w := get("/", accept)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			assert.Equal(t, "Accept", w.Header().Get("Vary"))
+			assert.True(t, strings.HasPrefix(w.Body.String(), `{"data":[`))
+		}
+	})
+
+	t.Run("plain text", func(t *testing.T) {
+		w := get("/", "text/plain")
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "\"Luck is <preparation>\" — Seneca\n\"While there is life, there is hope\" — Cicero\n", w.Body.String())
+	})
+
+	t.Run("format parameter wins over accept", func(t *testing.T) {
+		w := get("/?format=csv", "application/json")
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "id,author,quote,tags,created_at\n"+
+			"1,Seneca,Luck is <preparation>,,2024-07-01T10:00:00Z\n"+
+			"2,Cicero,\"While there is life,\nthere is hope\",,2024-07-01T10:00:00Z\n", w.Body.String())
+	})
+
+	t.Run("highest quality wins", func(t *testing.T) {
+		w := get("/", "text/html;q=0.5, text/markdown;q=0.9, application/pdf")
+		assert.Equal(t, "text/markdown; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "> Luck is <preparation>\n>\n> — Seneca\n\n> While there is life,\n> there is hope\n>\n> — Cicero\n", w.Body.String())
+
+		w = get("/", "text/*")
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("html is escaped", func(t *testing.T) {
+		w := get("/?format=html", "")
+		assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "<blockquote><p>Luck is &lt;preparation&gt;</p></blockquote>")
+	})
+
+	t.Run("single quote as xml", func(t *testing.T) {
+		w := get("/1", "application/xml")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/xml; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), `<quote id="1">`)
+		assert.Contains(t, w.Body.String(), "<text>Luck is &lt;preparation&gt;</text>")
+		assert.NotContains(t, w.Body.String(), "<quotes>")
+	})
+
+	t.Run("list validators depend on format", func(t *testing.T) {
+		assert.NotEqual(t, get("/", "").Header().Get("ETag"), get("/?format=xml", "").Header().Get("ETag"))
+	})
+
+	t.Run("custom format", func(t *testing.T) {
+		w := get("/1", "text/x-upper")
+		assert.Equal(t, "text/x-upper", w.Header().Get("Content-Type"))
+		assert.Equal(t, "LUCK IS <PREPARATION>\n", w.Body.String())
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		w := get("/", "application/pdf, text/plain;q=0")
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), `"code":"not_acceptable"`)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		w := get("/1?format=pdf", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unknown format")
+	})
+
+	t.Run("csv formulas are escaped", func(t *testing.T) {
+		formula := models.Quote{ID: 3, Author: "@Mallory", Quote: `=HYPERLINK("http://evil","x")`, Tags: []string{"-1+1"}, CreatedAt: created}
+		mockQuerier.On("GetByID", mock.Anything, 3).Return(&formula, nil).Once()
+		w := get("/3?format=csv", "")
+		assert.Equal(t, "id,author,quote,tags,created_at\n"+
+			"3,'@Mallory,\"'=HYPERLINK(\"\"http://evil\"\",\"\"x\"\")\",'-1+1,2024-07-01T10:00:00Z\n", w.Body.String())
+	})
+
+	t.Run("created quote in requested format", func(t *testing.T) {
+		mockQuerier.On("Create", mock.Anything, mock.AnythingOfType("*models.Quote")).Run(func(args mock.Arguments) {
+			q := args.Get(1).(*models.Quote)
+			q.ID, q.CreatedAt = 4, created
+		}).Return(nil).Once()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"author": "Seneca", "quote": "Luck is preparation"}`))
+		req.Header.Set("Accept", "text/plain")
+		w := httptest.NewRecorder()
+		handler.Routes().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "\"Luck is preparation\" — Seneca\n", w.Body.String())
+	})
+
+	t.Run("not acceptable write changes nothing", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/1", strings.NewReader(`{"author": "Seneca", "quote": "Luck"}`))
+		req.Header.Set("Accept", "application/pdf")
+		w := httptest.NewRecorder()
+		handler.Routes().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		mockQuerier.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+}
+
 func TestHandler_GetRandomQuote(t *testing.T) {
 	mockQuerier := new(MockQuerier)
 	handler := NewHandler(mockQuerier, zap.NewNop())
 
 	t.Run("successful get random", func(t *testing.T) {
 		quote := &models.Quote{Author: "Confucius", Quote: "Life is simple"}
-		mockQuerier.On("GetRandom", mock.Anything).Return(quote, nil).Once()
+		mockQuerier.On("GetRandom", mock.Anything, mock.Anything).Return(quote, nil).Once()
 
 		req := httptest.NewRequest(http.MethodGet, "/quotes/random", nil)
 		w := httptest.NewRecorder()
@@ -234,10 +473,11 @@ func TestHandler_GetRandomQuote(t *testing.T) {
 		assert.True(t, ok)
 		assert.Equal(t, "Confucius", data["author"])
 		assert.Equal(t, "Life is simple", data["quote"])
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
 	})
 
 	t.Run("no quotes available", func(t *testing.T) {
-		mockQuerier.On("GetRandom", mock.Anything).Return((*models.Quote)(nil), domain.ErrNotFound).Once()
+		mockQuerier.On("GetRandom", mock.Anything, mock.Anything).Return((*models.Quote)(nil), domain.ErrNotFound).Once()
 
 		req := httptest.NewRequest(http.MethodGet, "/quotes/random", nil)
 		w := httptest.NewRecorder()
@@ -264,7 +504,8 @@ func TestHandler_DeleteQuote(t *testing.T) {
 
 		handler.deleteQuote(w, req)
 
-		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Body.String())
 	})
 
 	t.Run("invalid id", func(t *testing.T) {
@@ -294,3 +535,1052 @@ func TestHandler_DeleteQuote(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, w.Code)
 	})
 }
+
+func TestHandler_UpdateQuotePolicy(t *testing.T) {
+	mockQuerier := new(MockQuerier)
+	handler := NewHandler(mockQuerier, zap.NewNop(), WithServiceOptions(service.WithPolicy(service.RolePolicy{})))
+	router := handler.Routes()
+
+	owned := &models.Quote{ID: 1, Author: "Confucius", Quote: "Life is simple", OwnerID: "key:1"}
+	update := func(principal *auth.Principal) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"author": "Confucius", "quote": "Life is really simple"})
+		req := httptest.NewRequest(http.MethodPut, "/1", bytes.NewReader(body))
+		if principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("owner", func(t *testing.T) {
+		mockQuerier.On("GetByID", mock.Anything, 1).Return(owned, nil).Once()
+		mockQuerier.On("Update", mock.Anything, mock.Anything).Return(nil).Once()
+
+		w := update(&auth.Principal{ID: "key:1", Roles: []string{auth.RoleContributor}})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("foreign quote", func(t *testing.T) {
+		mockQuerier.On("GetByID", mock.Anything, 1).Return(owned, nil).Once()
+
+		w := update(&auth.Principal{ID: "key:2", Roles: []string{auth.RoleContributor}})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var resp Problem
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, domain.CodeForbidden, resp.Code)
+		assert.Equal(t, service.ActionUpdateQuote, resp.Action)
+		assert.NotEmpty(t, resp.Reason)
+	})
+
+	t.Run("anonymous", func(t *testing.T) {
+		mockQuerier.On("GetByID", mock.Anything, 1).Return(owned, nil).Once()
+
+		w := update(nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	mockQuerier.AssertExpectations(t)
+}
+
+type MockModerationRepository struct {
+	mock.Mock
+}
+
+func (m *MockModerationRepository) Moderate(ctx context.Context, id int, status, reason, moderator string) (*models.Quote, error) {
+	args := m.Called(ctx, id, status, reason, moderator)
+	return args.Get(0).(*models.Quote), args.Error(1)
+}
+
+func TestHandler_Moderation(t *testing.T) {
+	mockQuerier := new(MockQuerier)
+	mockModeration := new(MockModerationRepository)
+	handler := NewHandler(mockQuerier, zap.NewNop(),
+		WithModeration(mockModeration),
+		WithServiceOptions(service.WithPolicy(service.RolePolicy{})),
+	)
+	quotes := handler.Routes()
+	moderation := handler.ModerationRoutes()
+
+	contributor := &auth.Principal{ID: "key:1", Roles: []string{auth.RoleContributor}}
+	moderator := &auth.Principal{ID: "key:2", Roles: []string{auth.RoleModerator}}
+	serve := func(router http.Handler, method, target, body string, principal *auth.Principal) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, bytes.NewBufferString(body))
+		if principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("submission is accepted as pending", func(t *testing.T) {
+		mockQuerier.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+
+		w := serve(quotes, http.MethodPost, "/", `{"author": "Confucius", "quote": "Life is simple"}`, contributor)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Contains(t, w.Body.String(), `"status":"pending"`)
+	})
+
+	t.Run("contributor cannot see the queue", func(t *testing.T) {
+		w := serve(moderation, http.MethodGet, "/", "", contributor)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("reject without reason", func(t *testing.T) {
+		w := serve(moderation, http.MethodPost, "/1/reject", `{}`, moderator)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("approve", func(t *testing.T) {
+		approved := &models.Quote{ID: 1, Author: "Confucius", Quote: "Life is simple", Status: models.StatusApproved}
+		mockModeration.On("Moderate", mock.Anything, 1, models.StatusApproved, "", "key:2").Return(approved, nil).Once()
+
+		w := serve(moderation, http.MethodPost, "/1/approve", "", moderator)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("approve twice", func(t *testing.T) {
+		mockModeration.On("Moderate", mock.Anything, 1, models.StatusApproved, "", "key:2").Return((*models.Quote)(nil), domain.ErrNotPending).Once()
+
+		w := serve(moderation, http.MethodPost, "/1/approve", "", moderator)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("submitter polls status", func(t *testing.T) {
+		rejected := &models.Quote{ID: 1, Status: models.StatusRejected, ModerationReason: "not a quote", OwnerID: "key:1"}
+		mockQuerier.On("GetByID", mock.Anything, 1).Return(rejected, nil).Once()
+
+		w := serve(quotes, http.MethodGet, "/1/status", "", contributor)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"data": {"id": 1, "status": "rejected", "reason": "not a quote"}}`, w.Body.String())
+	})
+
+	t.Run("others do not see the status", func(t *testing.T) {
+		rejected := &models.Quote{ID: 1, Status: models.StatusRejected, ModerationReason: "not a quote", OwnerID: "key:1"}
+		mockQuerier.On("GetByID", mock.Anything, 1).Return(rejected, nil).Once()
+
+		w := serve(quotes, http.MethodGet, "/1/status", "", nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	mockQuerier.AssertExpectations(t)
+	mockModeration.AssertExpectations(t)
+}
+
+func TestHandler_CreateQuoteViolations(t *testing.T) {
+	mockQuerier := new(MockQuerier)
+	handler := NewHandler(mockQuerier, zap.NewNop(), WithServiceOptions(
+		service.WithContentFilter(service.LengthLimits{MaxQuote: 10}, service.NoURLs()),
+	))
+
+	body := `{"author": "Bot", "quote": "Read more at https://example.com"}`
+	req := httptest.NewRequest(http.MethodPost, "/quotes", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	handler.createQuote(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var resp struct {
+		Violations []domain.Violation `json:"violations"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []domain.Violation{
+		{Field: "quote", Code: service.ViolationTooLong, Message: "quote must be at most 10 characters"},
+		{Field: "quote", Code: service.ViolationURL, Message: "links are not allowed"},
+	}, resp.Violations)
+	mockQuerier.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		violation   domain.Violation
+	}{
+		{"unknown field", "application/json", `{"author": "A", "quote": "Q", "id": 5}`, http.StatusBadRequest,
+			domain.Violation{Field: "id", Code: ViolationUnknownField, Message: "unknown field"}},
+		{"wrong type", "application/json", `{"author": 42, "quote": "Q"}`, http.StatusBadRequest,
+			domain.Violation{Field: "author", Code: ViolationInvalidType, Message: "must be a string"}},
+		{"malformed", "application/json", `{"author": "A",`, http.StatusBadRequest,
+			domain.Violation{Code: ViolationMalformed, Message: "request body is not valid JSON"}},
+		{"trailing data", "application/json", `{"author": "A", "quote": "Q"} {}`, http.StatusBadRequest,
+			domain.Violation{Code: ViolationMalformed, Message: "request body must contain a single JSON object"}},
+		{"empty body", "application/json", ``, http.StatusBadRequest,
+			domain.Violation{Code: ViolationRequired, Message: "request body is required"}},
+		{"form", "application/x-www-form-urlencoded", `author=A&quote=Q`, http.StatusUnsupportedMediaType, domain.Violation{}},
+		{"too large", "application/json", `{"author": "` + strings.Repeat("a", maxBodyBytes) + `"}`, http.StatusRequestEntityTooLarge, domain.Violation{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/quotes", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+
+			var dst quoteRequest
+			sendError(w, req, zap.NewNop(), decodeJSON(w, req, &dst))
+
+			assert.Equal(t, tt.status, w.Code)
+			var p Problem
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+			if tt.violation != (domain.Violation{}) {
+				assert.Equal(t, []domain.Violation{tt.violation}, p.Violations)
+			}
+		})
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/quotes", bytes.NewBufferString(`{"author": "A", "quote": "Q"}`))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		var dst quoteRequest
+		assert.NoError(t, decodeJSON(httptest.NewRecorder(), req, &dst))
+		assert.Equal(t, quoteRequest{Author: "A", Quote: "Q"}, dst)
+	})
+}
+
+func TestHandler_Problem(t *testing.T) {
+	mockQuerier := new(MockQuerier)
+	handler := NewHandler(mockQuerier, zap.NewNop())
+	router := chi.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Mount("/quotes", handler.Routes())
+
+	t.Run("not found", func(t *testing.T) {
+		mockQuerier.On("GetByID", mock.Anything, 42).Return((*models.Quote)(nil), domain.ErrNotFound).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/quotes/42", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, problemContentType, w.Header().Get("Content-Type"))
+		var p Problem
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		assert.Equal(t, "/problems/not_found", p.Type)
+		assert.Equal(t, http.StatusNotFound, p.Status)
+		assert.Equal(t, domain.CodeNotFound, p.Code)
+		assert.Equal(t, "quote not found", p.Detail)
+		assert.Equal(t, "/quotes/42", p.Instance)
+		assert.NotEmpty(t, p.RequestID)
+	})
+
+	t.Run("internal error is not leaked", func(t *testing.T) {
+		mockQuerier.On("GetByID", mock.Anything, 7).Return((*models.Quote)(nil), errors.New("dial tcp 10.0.0.5:5432: connection refused")).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/quotes/7", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.NotContains(t, w.Body.String(), "10.0.0.5")
+		var p Problem
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		assert.Equal(t, domain.CodeInternal, p.Code)
+		assert.Empty(t, p.Detail)
+	})
+}
+
+type MockRatingRepository struct {
+	mock.Mock
+}
+
+func (m *MockRatingRepository) Rate(ctx context.Context, quoteID int, userID string, rating int) error {
+	args := m.Called(ctx, quoteID, userID, rating)
+	return args.Error(0)
+}
+
+func (m *MockRatingRepository) Like(ctx context.Context, quoteID int, userID string) error {
+	args := m.Called(ctx, quoteID, userID)
+	return args.Error(0)
+}
+
+func (m *MockRatingRepository) Unlike(ctx context.Context, quoteID int, userID string) error {
+	args := m.Called(ctx, quoteID, userID)
+	return args.Error(0)
+}
+
+func (m *MockRatingRepository) Stats(ctx context.Context, ids []int) (map[int]models.RatingStats, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).(map[int]models.RatingStats), args.Error(1)
+}
+
+func (m *MockRatingRepository) Top(ctx context.Context, since time.Time, limit int) ([]models.Quote, error) {
+	args := m.Called(ctx, since, limit)
+	return args.Get(0).([]models.Quote), args.Error(1)
+}
+
+func TestHandler_RateQuote(t *testing.T) {
+	mockQuerier := new(MockQuerier)
+	mockRatings := new(MockRatingRepository)
+	handler := NewHandler(mockQuerier, zap.NewNop(), WithRatings(mockRatings))
+	router := handler.Routes()
+
+	t.Run("successful rate", func(t *testing.T) {
+		mockQuerier.On("GetByID", mock.Anything, 1).Return(&models.Quote{ID: 1, Status: models.StatusApproved}, nil).Once()
+		mockRatings.On("Rate", mock.Anything, 1, "ip:192.0.2.1", 5).Return(nil).Once()
+		mockRatings.On("Stats", mock.Anything, []int{1}).Return(map[int]models.RatingStats{1: {AvgRating: 5, RatingsCount: 1}}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/1/rating", bytes.NewReader([]byte(`{"rating": 5}`)))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var result map[string]map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, float64(1), result["data"]["ratings_count"])
+	})
+
+	t.Run("quote not found", func(t *testing.T) {
+		mockQuerier.On("GetByID", mock.Anything, 999).Return((*models.Quote)(nil), domain.ErrNotFound).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/999/rating", bytes.NewReader([]byte(`{"rating": 3}`)))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("stats only in json", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/1/like", nil)
+		req.Header.Set("Accept", "text/csv")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotAcceptable, w.Code)
+		mockRatings.AssertNotCalled(t, "Like", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("client header does not pick the user", func(t *testing.T) {
+		mockQuerier.On("GetByID", mock.Anything, 1).Return(&models.Quote{ID: 1, Status: models.StatusApproved}, nil).Once()
+		mockRatings.On("Like", mock.Anything, 1, "ip:192.0.2.1").Return(nil).Once()
+		mockRatings.On("Stats", mock.Anything, []int{1}).Return(map[int]models.RatingStats{1: {Likes: 1}}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodPut, "/1/like", nil)
+		req.Header.Set("X-User-ID", "someone-else")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("pending quote is hidden", func(t *testing.T) {
+		mockQuerier.On("GetByID", mock.Anything, 2).Return(&models.Quote{ID: 2, Status: models.StatusPending, OwnerID: "key:1"}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodPut, "/2/like", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("unknown top period", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/top?period=decade", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestHandler_GetQuote(t *testing.T) {
+	mockQuerier := new(MockQuerier)
+	handler := NewHandler(mockQuerier, zap.NewNop())
+	router := handler.Routes()
+
+	t.Run("successful get", func(t *testing.T) {
+		quote := &models.Quote{ID: 1, Author: "Confucius", Quote: "Life is simple", OwnerID: "key:1"}
+		mockQuerier.On("GetByID", mock.Anything, 1).Return(quote, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/1", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "key:1")
+	})
+
+	t.Run("quote not found", func(t *testing.T) {
+		mockQuerier.On("GetByID", mock.Anything, 999).Return((*models.Quote)(nil), domain.ErrNotFound).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/999", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("daily", func(t *testing.T) {
+		quote := &models.Quote{ID: 2, Author: "Socrates", Quote: "Know thyself"}
+		mockQuerier.On("GetDaily", mock.Anything, mock.Anything).Return(quote, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/daily", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestHandler_CreateQuoteDuplicate(t *testing.T) {
+	mockQuerier := new(MockQuerier)
+	mockIndex := new(MockDuplicateIndex)
+	handler := NewHandler(mockQuerier, zap.NewNop(), WithServiceOptions(service.WithDuplicateDetection(mockIndex, 0.8)))
+
+	body := []byte(`{"author": "Oscar Wilde", "quote": "be yourself, everyone else is already taken"}`)
+	existing := []models.Quote{{ID: 3, Author: "Oscar Wilde", Quote: "Be yourself; everyone else is already taken."}}
+
+	t.Run("likely duplicate", func(t *testing.T) {
+		mockIndex.On("TermCandidates", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([][]models.Quote{existing}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/quotes", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+
+		handler.createQuote(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		var result map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []interface{}{float64(3)}, result["candidates"])
+	})
+
+	t.Run("forced", func(t *testing.T) {
+		mockQuerier.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/quotes?force=true", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+
+		handler.createQuote(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+}
+
+type MockDuplicateIndex struct {
+	mock.Mock
+}
+
+func (m *MockDuplicateIndex) TermCandidates(ctx context.Context, terms [][]string, limit int, statuses ...string) ([][]models.Quote, error) {
+	args := m.Called(ctx, terms, limit, statuses)
+	return args.Get(0).([][]models.Quote), args.Error(1)
+}
+
+type MockCollectionRepository struct {
+	mock.Mock
+}
+
+func (m *MockCollectionRepository) CreateCollection(ctx context.Context, c *models.Collection) error {
+	args := m.Called(ctx, c)
+	return args.Error(0)
+}
+
+func (m *MockCollectionRepository) GetCollections(ctx context.Context) ([]models.Collection, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.Collection), args.Error(1)
+}
+
+func (m *MockCollectionRepository) GetCollection(ctx context.Context, id int) (*models.Collection, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Collection), args.Error(1)
+}
+
+func (m *MockCollectionRepository) UpdateCollection(ctx context.Context, c *models.Collection) error {
+	args := m.Called(ctx, c)
+	return args.Error(0)
+}
+
+func (m *MockCollectionRepository) DeleteCollection(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockCollectionRepository) AddToCollection(ctx context.Context, id, quoteID int) error {
+	args := m.Called(ctx, id, quoteID)
+	return args.Error(0)
+}
+
+func (m *MockCollectionRepository) RemoveFromCollection(ctx context.Context, id, quoteID int) error {
+	args := m.Called(ctx, id, quoteID)
+	return args.Error(0)
+}
+
+func (m *MockCollectionRepository) ReorderCollection(ctx context.Context, id int, quoteIDs []int) error {
+	args := m.Called(ctx, id, quoteIDs)
+	return args.Error(0)
+}
+
+func (m *MockCollectionRepository) GetRandomFromCollection(ctx context.Context, id int) (*models.Quote, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Quote), args.Error(1)
+}
+
+func (m *MockCollectionRepository) GetByIDs(ctx context.Context, ids []int) ([]models.Quote, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).([]models.Quote), args.Error(1)
+}
+
+func TestCollectionHandler(t *testing.T) {
+	mockRepo := new(MockCollectionRepository)
+	router := NewCollectionHandler(mockRepo, nil, zap.NewNop()).Routes()
+
+	t.Run("successful create", func(t *testing.T) {
+		mockRepo.On("CreateCollection", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			c := args.Get(1).(*models.Collection)
+			c.ID = 1
+		}).Return(nil).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"name": "Monday Motivation", "quote_ids": [1, 2]}`)))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("name taken", func(t *testing.T) {
+		mockRepo.On("CreateCollection", mock.Anything, mock.Anything).Return(domain.ErrCollectionExists).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"name": "Monday Motivation"}`)))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("random from empty collection", func(t *testing.T) {
+		mockRepo.On("GetCollection", mock.Anything, 1).Return(&models.Collection{ID: 1, QuoteIDs: []int{}}, nil).Once()
+		mockRepo.On("GetRandomFromCollection", mock.Anything, 1).Return((*models.Quote)(nil), domain.ErrNotFound).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/1/random", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("random in requested format", func(t *testing.T) {
+		mockRepo.On("GetCollection", mock.Anything, 1).Return(&models.Collection{ID: 1, QuoteIDs: []int{2}}, nil).Once()
+		mockRepo.On("GetRandomFromCollection", mock.Anything, 1).Return(&models.Quote{ID: 2, Author: "Seneca", Quote: "Luck is preparation"}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/1/random", nil)
+		req.Header.Set("Accept", "text/plain")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "\"Luck is preparation\" — Seneca\n", w.Body.String())
+	})
+
+	t.Run("unknown collection", func(t *testing.T) {
+		mockRepo.On("GetCollection", mock.Anything, 7).Return((*models.Collection)(nil), domain.ErrCollectionNotFound).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/7", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+type MockKeyAuthenticator struct {
+	mock.Mock
+}
+
+func (m *MockKeyAuthenticator) Authenticate(ctx context.Context, key string) (*auth.Principal, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(*auth.Principal), args.Error(1)
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	mockKeys := new(MockKeyAuthenticator)
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	serve := func(m *AuthMiddleware, method, key string) int {
+		req := httptest.NewRequest(method, "/quotes", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		m.Handler(ok).ServeHTTP(w, req)
+		return w.Code
+	}
+
+	public := NewAuthMiddleware(mockKeys, true, zap.NewNop())
+	private := NewAuthMiddleware(mockKeys, false, zap.NewNop())
+
+	t.Run("public read", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(public, http.MethodGet, ""))
+	})
+
+	t.Run("private read without key", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve(private, http.MethodGet, ""))
+	})
+
+	t.Run("write without key", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve(public, http.MethodPost, ""))
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		mockKeys.On("Authenticate", mock.Anything, "bad").Return((*auth.Principal)(nil), domain.ErrUnauthorized).Once()
+		assert.Equal(t, http.StatusUnauthorized, serve(public, http.MethodGet, "bad"))
+	})
+
+	t.Run("read-only key cannot write", func(t *testing.T) {
+		mockKeys.On("Authenticate", mock.Anything, "reader").Return(&auth.Principal{ID: "key:1", Scopes: []string{auth.ScopeRead}}, nil).Once()
+		assert.Equal(t, http.StatusForbidden, serve(public, http.MethodDelete, "reader"))
+	})
+
+	t.Run("write key", func(t *testing.T) {
+		mockKeys.On("Authenticate", mock.Anything, "writer").Return(&auth.Principal{ID: "key:2", Scopes: []string{auth.ScopeWrite}}, nil).Once()
+		assert.Equal(t, http.StatusNoContent, serve(public, http.MethodPost, "writer"))
+	})
+
+	t.Run("admin key", func(t *testing.T) {
+		mockKeys.On("Authenticate", mock.Anything, "admin").Return(&auth.Principal{ID: "key:3", Scopes: []string{auth.ScopeAdmin}}, nil).Once()
+		assert.Equal(t, http.StatusNoContent, serve(private, http.MethodGet, "admin"))
+	})
+}
+
+func TestRequireScope(t *testing.T) {
+	handler := RequireScope(auth.ScopeAdmin, zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(principal *auth.Principal) int {
+		req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+		if principal != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve(nil))
+	assert.Equal(t, http.StatusForbidden, serve(&auth.Principal{ID: "key:1", Scopes: []string{auth.ScopeRead, auth.ScopeWrite}}))
+	assert.Equal(t, http.StatusNoContent, serve(&auth.Principal{ID: "key:2", Scopes: []string{auth.ScopeAdmin}}))
+}
+
+type MockTokenVerifier struct {
+	mock.Mock
+}
+
+func (m *MockTokenVerifier) Verify(token string) (*auth.Principal, error) {
+	args := m.Called(token)
+	return args.Get(0).(*auth.Principal), args.Error(1)
+}
+
+func TestAuthMiddleware_BearerToken(t *testing.T) {
+	mockTokens := new(MockTokenVerifier)
+	middleware := NewAuthMiddleware(nil, true, zap.NewNop(), WithBearerTokens(mockTokens))
+
+	var seen *auth.Principal
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen, _ = auth.PrincipalFrom(r.Context())
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	t.Run("valid token", func(t *testing.T) {
+		mockTokens.On("Verify", "good").Return(&auth.Principal{ID: "user-42", Scopes: []string{auth.ScopeWrite}}, nil).Once()
+
+		req := httptest.NewRequest(http.MethodPost, "/quotes", nil)
+		req.Header.Set("Authorization", "Bearer good")
+		w := httptest.NewRecorder()
+		middleware.Handler(next).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		if assert.NotNil(t, seen) {
+			assert.Equal(t, "user-42", seen.ID)
+		}
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		mockTokens.On("Verify", "forged").Return((*auth.Principal)(nil), auth.ErrInvalidToken).Once()
+
+		req := httptest.NewRequest(http.MethodGet, "/quotes", nil)
+		req.Header.Set("Authorization", "Bearer forged")
+		w := httptest.NewRecorder()
+		middleware.Handler(next).ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "invalid_token")
+	})
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(
+		ratelimit.Config{Rate: 1, Burst: 2},
+		ratelimit.Config{Rate: 1, Burst: 1, DailyQuota: 1},
+		zap.NewNop(),
+	)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	serve := func(method, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/quotes/random", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		limiter.Handler(next).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("read burst", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, "10.0.0.1:1234").Code)
+		w := serve(http.MethodGet, "10.0.0.1:1234")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+		w = serve(http.MethodGet, "10.0.0.1:5678")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	})
+
+	t.Run("other client is not affected", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, "10.0.0.2:1234").Code)
+	})
+
+	t.Run("writes have separate limits and daily quota", func(t *testing.T) {
+		assert.Equal(t, http.StatusNoContent, serve(http.MethodPost, "10.0.0.1:1234").Code)
+
+		now = now.Add(time.Minute)
+		w := serve(http.MethodPost, "10.0.0.1:1234")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "0", w.Header().Get("X-Quota-Remaining"))
+		assert.Equal(t, "43140", w.Header().Get("Retry-After"))
+	})
+}
+
+func TestIPRateLimiter(t *testing.T) {
+	limiter := NewIPRateLimiter(ratelimit.Config{Rate: 1, Burst: 1}, zap.NewNop())
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	handler := limiter.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/quotes/random", nil)
+		req.RemoteAddr = remoteAddr
+		// лимит по IP не зависит от ключа: он проверяется до аутентификации
+		req.Header.Set("X-API-Key", "qs_invalid")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusNoContent, serve("10.0.0.1:1234").Code)
+	w := serve("10.0.0.1:5678")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Equal(t, http.StatusNoContent, serve("10.0.0.2:1234").Code)
+}
+
+// memIdempotencyStore хранит ключи в памяти, TTL в тестах не истекает
+type memIdempotencyStore struct {
+	responses map[string]models.IdempotentResponse
+}
+
+func (s *memIdempotencyStore) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, lease time.Duration) (*models.IdempotentResponse, error) {
+	if resp, ok := s.responses[key]; ok {
+		return &resp, nil
+	}
+	s.responses[key] = models.IdempotentResponse{Fingerprint: fingerprint}
+	return nil, nil
+}
+
+func (s *memIdempotencyStore) SaveIdempotentResponse(ctx context.Context, key string, resp models.IdempotentResponse, ttl time.Duration) error {
+	s.responses[key] = resp
+	return nil
+}
+
+func (s *memIdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	delete(s.responses, key)
+	return nil
+}
+
+func (s *memIdempotencyStore) PurgeIdempotencyKeys(ctx context.Context) error {
+	return nil
+}
+
+func TestHandler_Idempotency(t *testing.T) {
+	mockQuerier := new(MockQuerier)
+	store := &memIdempotencyStore{responses: make(map[string]models.IdempotentResponse)}
+	router := NewHandler(mockQuerier, zap.NewNop(), WithIdempotency(store, time.Hour, time.Minute)).Routes()
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		req.Header.Set(idempotencyKeyHeader, key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	body := `{"author": "Confucius", "quote": "Life is simple"}`
+
+	mockQuerier.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Quote).ID = 7
+	}).Return(nil).Once()
+
+	first := post("retry-1", body)
+	assert.Equal(t, http.StatusCreated, first.Code)
+
+	t.Run("retry replays the first response", func(t *testing.T) {
+		w := post("retry-1", body)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "true", w.Header().Get(idempotentReplayHeader))
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, first.Body.String(), w.Body.String())
+	})
+
+	t.Run("different body", func(t *testing.T) {
+		w := post("retry-1", `{"author": "Confucius", "quote": "Life is hard"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("server errors are not stored", func(t *testing.T) {
+		mockQuerier.On("Create", mock.Anything, mock.Anything).Return(errors.New("connection refused")).Once()
+		other := `{"author": "Seneca", "quote": "Luck is preparation"}`
+
+		assert.Equal(t, http.StatusInternalServerError, post("retry-2", other).Code)
+		assert.NotContains(t, store.responses, "ip:192.0.2.1:retry-2")
+	})
+
+	t.Run("panic releases the key", func(t *testing.T) {
+		mockQuerier.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			panic("boom")
+		}).Return(nil).Once()
+		other := `{"author": "Seneca", "quote": "Luck is preparation"}`
+
+		assert.Panics(t, func() { post("retry-3", other) })
+		assert.NotContains(t, store.responses, "ip:192.0.2.1:retry-3")
+	})
+
+	mockQuerier.AssertNumberOfCalls(t, "Create", 3)
+}
+
+// passTransactor выполняет fn без настоящей транзакции
+type passTransactor struct{}
+
+func (passTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func TestHandler_Batch(t *testing.T) {
+	mockQuerier := new(MockQuerier)
+	router := NewHandler(mockQuerier, zap.NewNop(), WithBatch(passTransactor{})).Routes()
+
+	post := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/batch", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("invalid operations", func(t *testing.T) {
+		w := post(`{"operations": [{"op": "delete"}, {"op": "move", "id": 1}]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var p Problem
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		assert.Equal(t, "operations[0].id", p.Violations[0].Field)
+		assert.Equal(t, "operations[1].op", p.Violations[1].Field)
+	})
+
+	t.Run("best effort", func(t *testing.T) {
+		mockQuerier.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.Quote).ID = 9
+		}).Return(nil).Once()
+		mockQuerier.On("Delete", mock.Anything, 404).Return(domain.ErrNotFound).Once()
+
+		w := post(`{"operations": [
+			{"op": "create", "author": "Seneca", "quote": "Luck is preparation"},
+			{"op": "delete", "id": 404}
+		]}`)
+		assert.Equal(t, http.StatusMultiStatus, w.Code)
+
+		var resp struct {
+			Committed bool          `json:"committed"`
+			Results   []batchResult `json:"results"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, resp.Committed)
+		assert.Equal(t, http.StatusCreated, resp.Results[0].Status)
+		assert.Equal(t, 9, resp.Results[0].Data.ID)
+		assert.Equal(t, http.StatusNotFound, resp.Results[1].Status)
+		assert.Equal(t, domain.CodeNotFound, resp.Results[1].Error.Code)
+	})
+}
+
+func TestOpenAPISpec(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(openAPISpec, &spec))
+
+	// Роутер собирается как в main со всеми включенными возможностями
+	handler := NewHandler(new(MockQuerier), zap.NewNop(),
+		WithRatings(new(MockRatingRepository)), WithViews(nil), WithSimilarity(nil), WithModeration(nil), WithBatch(passTransactor{}),
+		WithEvents(service.NewEventBroker(1, 1), time.Second))
+	r := chi.NewRouter()
+	r.Mount("/quotes", handler.Routes())
+	r.Mount("/moderation", handler.ModerationRoutes())
+	r.Mount("/collections", NewCollectionHandler(new(MockCollectionRepository), nil, zap.NewNop()).Routes())
+	r.Mount("/keys", NewKeyHandler(service.NewAPIKeyService(nil), service.RolePolicy{}, zap.NewNop()).Routes())
+	r.Mount("/webhooks", NewWebhookHandler(service.NewWebhookService(nil), service.RolePolicy{}, zap.NewNop()).Routes())
+
+	routes := make(map[string]bool)
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes[method+" "+strings.TrimSuffix(route, "/")] = true
+		return nil
+	})
+	assert.NoError(t, err)
+
+	documented := make(map[string]bool)
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method != "parameters" {
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+	for route := range routes {
+		assert.True(t, documented[route], "маршрут %s не описан в openapi.json", route)
+	}
+	for route := range documented {
+		assert.True(t, routes[route], "в openapi.json описан несуществующий маршрут %s", route)
+	}
+
+	t.Run("references resolve", func(t *testing.T) {
+		var doc map[string]interface{}
+		assert.NoError(t, json.Unmarshal(openAPISpec, &doc))
+		var walk func(v interface{})
+		walk = func(v interface{}) {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				if ref, ok := v["$ref"].(string); ok {
+					var target interface{} = doc
+					for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+						m, _ := target.(map[string]interface{})
+						target = m[part]
+					}
+					assert.NotNil(t, target, "ссылка %s никуда не ведет", ref)
+				}
+				for _, child := range v {
+					walk(child)
+				}
+			case []interface{}:
+				for _, child := range v {
+					walk(child)
+				}
+			}
+		}
+		walk(doc)
+	})
+
+	t.Run("served", func(t *testing.T) {
+		for path, contentType := range map[string]string{"/openapi.json": "application/json", "/docs": "text/html; charset=utf-8"} {
+			w := httptest.NewRecorder()
+			DocsRoutes().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, contentType, w.Header().Get("Content-Type"))
+		}
+	})
+}
+
+func TestHandler_StreamQuotes(t *testing.T) {
+	mockQuerier := new(MockQuerier)
+	mockQuerier.On("Delete", mock.Anything, 1).Return(nil)
+	mockQuerier.On("Delete", mock.Anything, 2).Return(nil)
+	events := service.NewEventBroker(10, 10)
+	handler := NewHandler(mockQuerier, zap.NewNop(), WithEvents(events, time.Hour))
+	srv := httptest.NewServer(handler.Routes())
+	defer srv.Close()
+
+	del := func(id string) {
+		req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/"+id, nil)
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+	}
+	// readEvent читает одно событие, пропуская retry и комментарии
+	readEvent := func(r *bufio.Reader) []string {
+		var lines []string
+		for {
+			line, err := r.ReadString('\n')
+			if !assert.NoError(t, err) {
+				return lines
+			}
+			line = strings.TrimSuffix(line, "\n")
+			if line == "" {
+				if len(lines) > 0 && !strings.HasPrefix(lines[0], "retry:") {
+					return lines
+				}
+				lines = nil
+				continue
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	del("1")
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/stream", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	body := bufio.NewReader(resp.Body)
+
+	del("2")
+	assert.Equal(t, []string{"id: 2", "event: deleted", `data: {"id":2}`}, readEvent(body))
+
+	t.Run("resume", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/stream?last_event_id=1")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, []string{"id: 2", "event: deleted", `data: {"id":2}`}, readEvent(bufio.NewReader(resp.Body)))
+	})
+
+	t.Run("unknown id", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/stream", nil)
+		req.Header.Set("Last-Event-ID", "100")
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, []string{"event: reset", "data: {}"}, readEvent(bufio.NewReader(resp.Body)))
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		resp, err := http.Get(srv.URL + "/stream?last_event_id=abc")
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	events.Close()
+	_, err = body.ReadString('\n')
+	assert.Error(t, err, "поток закрывается вместе с брокером")
+}
@@ -0,0 +1,187 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayHeader помечает ответ, повторенный из сохраненного
+	idempotentReplayHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLen   = 255
+	idempotencyPurgeEvery  = time.Minute
+)
+
+type IdempotencyStore interface {
+	ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, lease time.Duration) (*models.IdempotentResponse, error)
+	SaveIdempotentResponse(ctx context.Context, key string, resp models.IdempotentResponse, ttl time.Duration) error
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+	PurgeIdempotencyKeys(ctx context.Context) error
+}
+
+// Idempotency запоминает первый ответ на запрос с заголовком Idempotency-Key и повторяет его
+// на повторы с тем же ключом и телом. Ключи разделены по клиентам, поэтому middleware ставится после AuthMiddleware.
+// Ответы 5xx не сохраняются: ключ освобождается, и клиент может повторить запрос.
+// Пока запрос выполняется, ключ занят только на lease: если обработчик упал вместе с процессом,
+// ключ освободится сам, не дожидаясь ttl сохраненного ответа.
+type Idempotency struct {
+	store  IdempotencyStore
+	ttl    time.Duration
+	lease  time.Duration
+	logger *zap.Logger
+
+	mu        sync.Mutex
+	lastPurge time.Time
+}
+
+func NewIdempotency(store IdempotencyStore, ttl, lease time.Duration, logger *zap.Logger) *Idempotency {
+	return &Idempotency{store: store, ttl: ttl, lease: lease, logger: logger}
+}
+
+func (m *Idempotency) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			sendError(w, r, m.logger, invalid(violation(idempotencyKeyHeader, ViolationOutOfRange, "must be at most 255 characters")))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				err = domain.ErrPayloadTooLarge
+			}
+			sendError(w, r, m.logger, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		m.purge(r.Context())
+		key = clientKey(r) + ":" + key
+		fingerprint := requestFingerprint(r, body)
+		saved, err := m.store.ReserveIdempotencyKey(r.Context(), key, fingerprint, m.lease)
+		if err != nil {
+			sendError(w, r, m.logger, err)
+			return
+		}
+		if saved != nil {
+			m.replay(w, r, saved, fingerprint)
+			return
+		}
+
+		// ответ уже отправлен, сохранить его нужно даже если клиент отключился
+		ctx := context.WithoutCancel(r.Context())
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		m.serve(ctx, next, rec, r, key)
+
+		if rec.status >= http.StatusInternalServerError {
+			m.release(ctx, key)
+			return
+		}
+		resp := models.IdempotentResponse{
+			Fingerprint: fingerprint,
+			StatusCode:  rec.status,
+			ContentType: rec.Header().Get("Content-Type"),
+			Body:        rec.body.Bytes(),
+		}
+		if err := m.store.SaveIdempotentResponse(ctx, key, resp, m.ttl); err != nil {
+			m.logger.Error("Ошибка сохранения ответа по ключу идемпотентности", zap.Error(err))
+		}
+	})
+}
+
+// serve выполняет запрос и освобождает ключ, если обработчик запаниковал, чтобы повтор не ждал lease
+func (m *Idempotency) serve(ctx context.Context, next http.Handler, rec *responseRecorder, r *http.Request, key string) {
+	defer func() {
+		if p := recover(); p != nil {
+			m.release(ctx, key)
+			panic(p)
+		}
+	}()
+	next.ServeHTTP(rec, r)
+}
+
+func (m *Idempotency) release(ctx context.Context, key string) {
+	if err := m.store.ReleaseIdempotencyKey(ctx, key); err != nil {
+		m.logger.Error("Ошибка освобождения ключа идемпотентности", zap.Error(err))
+	}
+}
+
+func (m *Idempotency) replay(w http.ResponseWriter, r *http.Request, saved *models.IdempotentResponse, fingerprint string) {
+	switch {
+	case saved.Fingerprint != fingerprint:
+		sendError(w, r, m.logger, domain.ErrIdempotencyMismatch)
+	case saved.StatusCode == 0:
+		sendError(w, r, m.logger, domain.ErrIdempotencyInProgress)
+	default:
+		if saved.ContentType != "" {
+			w.Header().Set("Content-Type", saved.ContentType)
+		}
+		w.Header().Set(idempotentReplayHeader, "true")
+		w.WriteHeader(saved.StatusCode)
+		w.Write(saved.Body)
+	}
+}
+
+// purge не чаще раза в минуту удаляет истекшие ключи
+func (m *Idempotency) purge(ctx context.Context) {
+	m.mu.Lock()
+	if time.Since(m.lastPurge) < idempotencyPurgeEvery {
+		m.mu.Unlock()
+		return
+	}
+	m.lastPurge = time.Now()
+	m.mu.Unlock()
+
+	if err := m.store.PurgeIdempotencyKeys(ctx); err != nil {
+		m.logger.Error("Ошибка удаления истекших ключей идемпотентности", zap.Error(err))
+	}
+}
+
+// requestFingerprint - хеш метода, пути и тела: повтор с тем же ключом должен совпадать с первым запросом
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder пропускает ответ клиенту и копирует его для сохранения
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if !rec.wroteHeader {
+		rec.status = status
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.wroteHeader = true
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
@@ -0,0 +1,108 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// KeyHandler управляет API-ключами по HTTP. Доступ разрешает политика (действие keys.manage).
+type KeyHandler struct {
+	logger *zap.Logger
+	keys   *service.APIKeyService
+	policy service.Policy
+}
+
+func NewKeyHandler(keys *service.APIKeyService, policy service.Policy, logger *zap.Logger) *KeyHandler {
+	return &KeyHandler{logger: logger, keys: keys, policy: policy}
+}
+
+func (h *KeyHandler) Routes() *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(h.authorize)
+	r.Get("/", h.listKeys)         // GET /keys
+	r.Post("/", h.issueKey)        // POST /keys
+	r.Delete("/{id}", h.revokeKey) // DELETE /keys/{id}
+	return r
+}
+
+type issueKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Roles  []string `json:"roles"`
+}
+
+func (req issueKeyRequest) Validate() []domain.Violation {
+	return required(nil, "name", req.Name)
+}
+
+func (h *KeyHandler) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := h.policy.Authorize(r.Context(), service.ActionManageKeys, nil); err != nil {
+			h.sendError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *KeyHandler) listKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.keys.List(r.Context())
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	if keys == nil {
+		keys = []models.APIKey{}
+	}
+	h.send(w, http.StatusOK, map[string]interface{}{"data": keys})
+}
+
+func (h *KeyHandler) issueKey(w http.ResponseWriter, r *http.Request) {
+	var req issueKeyRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	plain, key, err := h.keys.Issue(r.Context(), req.Name, req.Scopes, req.Roles)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.send(w, http.StatusCreated, map[string]interface{}{
+		"data": key,
+		"key":  plain,
+	})
+}
+
+func (h *KeyHandler) revokeKey(w http.ResponseWriter, r *http.Request) {
+	id, err := urlInt(r, "id")
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	if err := h.keys.Revoke(r.Context(), id); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *KeyHandler) send(w http.ResponseWriter, statusCode int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Ошибка кодирования ответа", zap.Error(err))
+	}
+}
+
+func (h *KeyHandler) sendError(w http.ResponseWriter, r *http.Request, err error) {
+	sendError(w, r, h.logger, err)
+}
@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"net/http"
+	"strings"
+
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ModerationRoutes - очередь модерации, монтируется под /moderation
+func (h *Handler) ModerationRoutes() *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/", h.getPendingQuotes)                             // GET /moderation
+	r.With(h.acceptable).Post("/{id}/approve", h.approveQuote) // POST /moderation/{id}/approve
+	r.With(h.acceptable).Post("/{id}/reject", h.rejectQuote)   // POST /moderation/{id}/reject
+	return r
+}
+
+type moderationRequest struct {
+	Reason string `json:"reason"`
+}
+
+// moderationStatus - то, что автор видит, опрашивая статус своей цитаты
+type moderationStatus struct {
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+func (h *Handler) getPendingQuotes(w http.ResponseWriter, r *http.Request) {
+	quotes, err := h.service.Pending(r.Context())
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	if quotes == nil {
+		quotes = []models.Quote{}
+	}
+	h.sendQuotes(w, r, quotes)
+}
+
+func (h *Handler) approveQuote(w http.ResponseWriter, r *http.Request) {
+	h.moderateQuote(w, r, models.StatusApproved)
+}
+
+func (h *Handler) rejectQuote(w http.ResponseWriter, r *http.Request) {
+	h.moderateQuote(w, r, models.StatusRejected)
+}
+
+func (h *Handler) moderateQuote(w http.ResponseWriter, r *http.Request, status string) {
+	id, err := urlInt(r, "id")
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	var req moderationRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(w, r, &req); err != nil {
+			h.sendError(w, r, err)
+			return
+		}
+	}
+
+	var quote *models.Quote
+	if status == models.StatusApproved {
+		quote, err = h.service.Approve(r.Context(), id, req.Reason)
+	} else {
+		quote, err = h.service.Reject(r.Context(), id, req.Reason)
+	}
+	if err != nil {
+		if err == domain.ErrInvalidInput {
+			err = domain.NewError(domain.CodeValidation, "a reason is required to reject a quote")
+		}
+		h.sendError(w, r, err)
+		return
+	}
+	h.audit(r, "quote."+status, id)
+
+	h.sendQuote(w, r, quote)
+}
+
+func (h *Handler) getModerationStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := urlInt(r, "id")
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	quote, err := h.service.ModerationStatus(r.Context(), id)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.sendData(w, r, http.StatusOK, moderationStatus{ID: quote.ID, Status: quote.Status, Reason: quote.ModerationReason})
+}
+
+// requestStatuses разбирает ?status=pending,rejected; all означает все статусы
+func requestStatuses(r *http.Request) []string {
+	raw := r.URL.Query().Get("status")
+	if raw == "" {
+		return nil
+	}
+	if raw == "all" {
+		return []string{models.StatusPending, models.StatusApproved, models.StatusRejected}
+	}
+	var statuses []string
+	for _, status := range strings.Split(raw, ",") {
+		if status = strings.TrimSpace(status); status != "" {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
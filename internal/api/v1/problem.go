@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"quote-service/internal/domain"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"go.uber.org/zap"
+)
+
+// problemContentType - тип ответов об ошибках по RFC 7807
+const problemContentType = "application/problem+json"
+
+// Problem - описание ошибки по RFC 7807. Кроме стандартных полей содержит стабильный code,
+// request_id для поиска в журнале и подробности отдельных ошибок.
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail,omitempty"`
+	Instance  string      `json:"instance,omitempty"`
+	Code      domain.Code `json:"code"`
+	RequestID string      `json:"request_id,omitempty"`
+
+	Violations []domain.Violation `json:"violations,omitempty"`
+	Candidates []int              `json:"candidates,omitempty"`
+	Action     string             `json:"action,omitempty"`
+	Reason     string             `json:"reason,omitempty"`
+}
+
+var problemStatus = map[domain.Code]int{
+	domain.CodeValidation:   http.StatusBadRequest,
+	domain.CodeNotFound:     http.StatusNotFound,
+	domain.CodeDuplicate:    http.StatusConflict,
+	domain.CodeConflict:     http.StatusConflict,
+	domain.CodeUnauthorized: http.StatusUnauthorized,
+	domain.CodeForbidden:    http.StatusForbidden,
+	domain.CodeRateLimited:  http.StatusTooManyRequests,
+	domain.CodeInternal:     http.StatusInternalServerError,
+
+	domain.CodePayloadTooLarge:      http.StatusRequestEntityTooLarge,
+	domain.CodeUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	domain.CodeNotAcceptable:        http.StatusNotAcceptable,
+	domain.CodeIdempotencyMismatch:  http.StatusUnprocessableEntity,
+	domain.CodeRolledBack:           http.StatusFailedDependency,
+	domain.CodeNotImplemented:       http.StatusNotImplemented,
+}
+
+var problemTitle = map[domain.Code]string{
+	domain.CodeValidation:   "Invalid request",
+	domain.CodeNotFound:     "Resource not found",
+	domain.CodeDuplicate:    "Duplicate quote",
+	domain.CodeConflict:     "Conflict",
+	domain.CodeUnauthorized: "Authentication required",
+	domain.CodeForbidden:    "Forbidden",
+	domain.CodeRateLimited:  "Too many requests",
+	domain.CodeInternal:     "Internal server error",
+
+	domain.CodePayloadTooLarge:      "Payload too large",
+	domain.CodeUnsupportedMediaType: "Unsupported media type",
+	domain.CodeNotAcceptable:        "Not acceptable",
+	domain.CodeIdempotencyMismatch:  "Idempotency key reused",
+	domain.CodeRolledBack:           "Operation rolled back",
+	domain.CodeNotImplemented:       "Not implemented",
+}
+
+// problemFor переводит ошибку в Problem. Текст внутренних ошибок клиенту не попадает.
+func problemFor(r *http.Request, err error) Problem {
+	code := domain.CodeOf(err)
+	p := Problem{
+		Type:      "/problems/" + string(code),
+		Title:     problemTitle[code],
+		Status:    problemStatus[code],
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: middleware.GetReqID(r.Context()),
+	}
+
+	var (
+		catalogErr    *domain.Error
+		validationErr *domain.ValidationError
+		dupErr        *domain.DuplicateError
+		forbiddenErr  *domain.ForbiddenError
+	)
+	switch {
+	case errors.As(err, &validationErr):
+		p.Detail = validationErr.Error()
+		p.Violations = validationErr.Violations
+	case errors.As(err, &dupErr):
+		p.Detail = dupErr.Error()
+		p.Candidates = dupErr.Candidates
+	case errors.As(err, &forbiddenErr):
+		p.Detail = forbiddenErr.Error()
+		p.Action = forbiddenErr.Action
+		p.Reason = forbiddenErr.Reason
+	case errors.As(err, &catalogErr):
+		p.Detail = catalogErr.Message
+	}
+	return p
+}
+
+// sendError - единственное место, где ошибки превращаются в HTTP-ответ
+func sendError(w http.ResponseWriter, r *http.Request, logger *zap.Logger, err error) {
+	p := problemFor(r, err)
+	if p.Code == domain.CodeInternal {
+		logger.Error("Внутренняя ошибка",
+			zap.Error(err),
+			zap.String("path", r.URL.Path),
+			zap.String("request_id", p.RequestID),
+		)
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		logger.Error("Ошибка кодирования ответа", zap.Error(err))
+	}
+}
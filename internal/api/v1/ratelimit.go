@@ -0,0 +1,181 @@
+package v1
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"quote-service/internal/auth"
+	"quote-service/internal/domain"
+	"quote-service/pkg/ratelimit"
+
+	"go.uber.org/zap"
+)
+
+// classLimits - ограничения одного класса запросов (чтение или запись)
+type classLimits struct {
+	bucket *ratelimit.Limiter
+	quota  *ratelimit.Quota
+}
+
+func newClassLimits(cfg ratelimit.Config) classLimits {
+	var l classLimits
+	if cfg.Rate > 0 {
+		l.bucket = ratelimit.NewLimiter(cfg.Rate, cfg.Burst)
+	}
+	if cfg.DailyQuota > 0 {
+		l.quota = ratelimit.NewQuota(cfg.DailyQuota)
+	}
+	return l
+}
+
+// RateLimiter ограничивает частоту запросов и суточную квоту каждого клиента.
+// Клиент определяется по API-ключу или токену, анонимный - по IP, поэтому
+// middleware ставится после AuthMiddleware.
+type RateLimiter struct {
+	logger *zap.Logger
+	read   classLimits
+	write  classLimits
+	now    func() time.Time
+}
+
+func NewRateLimiter(read, write ratelimit.Config, logger *zap.Logger) *RateLimiter {
+	return &RateLimiter{
+		logger: logger,
+		read:   newClassLimits(read),
+		write:  newClassLimits(write),
+		now:    time.Now,
+	}
+}
+
+func (rl *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		st := rl.take(clientKey(r), requiredScope(r.Method) != auth.ScopeRead)
+		if st.bucket != nil {
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(st.bucket.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(st.bucket.Remaining))
+			w.Header().Set("RateLimit-Reset", seconds(st.bucket.Reset))
+		}
+		if st.quota {
+			w.Header().Set("X-Quota-Limit", strconv.Itoa(st.quotaLimit))
+			w.Header().Set("X-Quota-Remaining", strconv.Itoa(st.quotaRemaining))
+		}
+		if st.err != nil {
+			w.Header().Set("Retry-After", seconds(st.retryAfter))
+			sendError(w, r, rl.logger, st.err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Limit списывает запрос клиента из лимитов чтения или записи и возвращает ошибку rate_limited
+// и через сколько повторить, если лимит или квота исчерпаны. Через него лимиты применяет gRPC API,
+// так что у клиента общие корзины и квоты для обоих API.
+func (rl *RateLimiter) Limit(client string, write bool) (time.Duration, error) {
+	st := rl.take(client, write)
+	return st.retryAfter, st.err
+}
+
+// limitState - результат проверки лимитов для заголовков ответа
+type limitState struct {
+	bucket         *ratelimit.Result
+	quota          bool
+	quotaLimit     int
+	quotaRemaining int
+	retryAfter     time.Duration
+	err            error
+}
+
+func (rl *RateLimiter) take(client string, write bool) limitState {
+	limits, class := rl.read, "read"
+	if write {
+		limits, class = rl.write, "write"
+	}
+	now := rl.now()
+
+	var st limitState
+	if limits.bucket != nil {
+		res := limits.bucket.Allow(client, now)
+		st.bucket = &res
+		if !res.Allowed {
+			rl.logger.Info("Превышен лимит запросов", zap.String("client", client), zap.String("class", class))
+			st.retryAfter = res.RetryAfter
+			st.err = domain.NewError(domain.CodeRateLimited, "rate limit exceeded")
+			return st
+		}
+	}
+	if limits.quota != nil {
+		remaining, reset, ok := limits.quota.Use(client, now)
+		st.quota, st.quotaLimit, st.quotaRemaining = true, limits.quota.Limit(), remaining
+		if !ok {
+			rl.logger.Info("Исчерпана суточная квота", zap.String("client", client), zap.String("class", class))
+			st.retryAfter = reset
+			st.err = domain.NewError(domain.CodeRateLimited, "daily quota exceeded")
+		}
+	}
+	return st
+}
+
+// IPRateLimiter ограничивает частоту запросов с одного IP до аутентификации, чтобы перебор
+// ключей и токенов и поток запросов с неверными учетными данными не доходили до проверки.
+// Ставится перед AuthMiddleware, квоты клиентов по-прежнему считает RateLimiter.
+type IPRateLimiter struct {
+	logger *zap.Logger
+	bucket *ratelimit.Limiter
+	now    func() time.Time
+}
+
+// NewIPRateLimiter создает ограничение по IP, cfg.Rate должен быть больше нуля
+func NewIPRateLimiter(cfg ratelimit.Config, logger *zap.Logger) *IPRateLimiter {
+	return &IPRateLimiter{
+		logger: logger,
+		bucket: ratelimit.NewLimiter(cfg.Rate, cfg.Burst),
+		now:    time.Now,
+	}
+}
+
+func (rl *IPRateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if retryAfter, err := rl.Limit(ipKey(r)); err != nil {
+			w.Header().Set("Retry-After", seconds(retryAfter))
+			sendError(w, r, rl.logger, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Limit списывает запрос с IP client ("ip:адрес"). Ошибка rate_limited означает, что лимит исчерпан.
+func (rl *IPRateLimiter) Limit(client string) (time.Duration, error) {
+	res := rl.bucket.Allow(client, rl.now())
+	if res.Allowed {
+		return 0, nil
+	}
+	rl.logger.Info("Превышен лимит запросов с IP", zap.String("client", client))
+	return res.RetryAfter, domain.NewError(domain.CodeRateLimited, "rate limit exceeded")
+}
+
+// clientKey возвращает идентификатор клиента для лимитов, оценок и лайков: аутентифицированного
+// клиента или, без аутентификации, его IP. Заголовкам клиента здесь доверять нельзя.
+func clientKey(r *http.Request) string {
+	if principal, ok := auth.PrincipalFrom(r.Context()); ok {
+		return principal.ID
+	}
+	return ipKey(r)
+}
+
+func ipKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// seconds округляет длительность вверх до целых секунд, как требуют RateLimit-Reset и Retry-After
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,85 @@
+package v1
+
+import (
+	"net/http"
+
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/internal/service"
+)
+
+type rateRequest struct {
+	Rating int `json:"rating"`
+}
+
+func (req rateRequest) Validate() []domain.Violation {
+	return inRange(nil, "rating", req.Rating, service.MinRating, service.MaxRating)
+}
+
+func (h *Handler) rateQuote(w http.ResponseWriter, r *http.Request) {
+	id, err := urlInt(r, "id")
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	var req rateRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	stats, err := h.ratings.Rate(r.Context(), id, clientKey(r), req.Rating)
+	h.sendStats(w, r, stats, err)
+}
+
+func (h *Handler) likeQuote(w http.ResponseWriter, r *http.Request) {
+	id, err := urlInt(r, "id")
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	stats, err := h.ratings.Like(r.Context(), id, clientKey(r))
+	h.sendStats(w, r, stats, err)
+}
+
+func (h *Handler) unlikeQuote(w http.ResponseWriter, r *http.Request) {
+	id, err := urlInt(r, "id")
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	stats, err := h.ratings.Unlike(r.Context(), id, clientKey(r))
+	h.sendStats(w, r, stats, err)
+}
+
+func (h *Handler) getTopQuotes(w http.ResponseWriter, r *http.Request) {
+	limit, err := queryInt(r, "limit", 0)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	quotes, err := h.ratings.Top(r.Context(), r.URL.Query().Get("period"), limit)
+	if err != nil {
+		if err == domain.ErrInvalidInput {
+			err = domain.NewError(domain.CodeValidation, "unknown period")
+		}
+		h.sendError(w, r, err)
+		return
+	}
+	if quotes == nil {
+		quotes = []models.Quote{}
+	}
+	h.sendQuotes(w, r, quotes)
+}
+
+func (h *Handler) sendStats(w http.ResponseWriter, r *http.Request, stats models.RatingStats, err error) {
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.sendData(w, r, http.StatusOK, stats)
+}
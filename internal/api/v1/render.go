@@ -0,0 +1,408 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"html/template"
+	"io"
+	"mime"
+	"net/http"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Renderer выводит цитаты в одном из форматов ответа. Ошибки при этом всегда остаются
+// application/problem+json, а запросы - JSON.
+type Renderer interface {
+	// ContentType - значение заголовка Content-Type ответа
+	ContentType() string
+	RenderQuote(w io.Writer, quote *models.Quote) error
+	RenderQuotes(w io.Writer, quotes []models.Quote) error
+}
+
+// defaultFormat - формат ответа без ?format= и Accept, а также для Accept: */*
+const defaultFormat = "json"
+
+// format - формат ответа: имя для ?format= и типы для Accept
+type format struct {
+	name       string
+	mediaTypes []string
+	renderer   Renderer
+}
+
+// formats - форматы, из которых выбирается ответ
+type formats []format
+
+// WithFormat добавляет формат ответа или заменяет встроенный с тем же именем. Формат выбирается
+// параметром ?format=name или заголовком Accept с одним из mediaTypes.
+func WithFormat(name string, renderer Renderer, mediaTypes ...string) Option {
+	return func(h *Handler) {
+		f := format{name: name, mediaTypes: mediaTypes, renderer: renderer}
+		for i := range h.formats {
+			if h.formats[i].name == name {
+				h.formats[i] = f
+				return
+			}
+		}
+		h.formats = append(h.formats, f)
+	}
+}
+
+func defaultFormats() formats {
+	return formats{
+		{name: "json", mediaTypes: []string{"application/json"}, renderer: jsonRenderer{}},
+		{name: "text", mediaTypes: []string{"text/plain"}, renderer: textRenderer{}},
+		{name: "csv", mediaTypes: []string{"text/csv"}, renderer: csvRenderer{}},
+		{name: "xml", mediaTypes: []string{"application/xml", "text/xml"}, renderer: xmlRenderer{}},
+		{name: "markdown", mediaTypes: []string{"text/markdown"}, renderer: markdownRenderer{}},
+		{name: "html", mediaTypes: []string{"text/html"}, renderer: htmlRenderer{}},
+	}
+}
+
+// negotiate выбирает формат ответа: ?format= важнее Accept, из типов Accept берется тип
+// с наибольшим q, при равных q - указанный раньше.
+func (fs formats) negotiate(r *http.Request) (*format, error) {
+	if name := r.URL.Query().Get("format"); name != "" {
+		if f := fs.byName(name); f != nil {
+			return f, nil
+		}
+		return nil, domain.NewError(domain.CodeValidation, "unknown format")
+	}
+
+	accept := r.Header.Get("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return fs.byName(defaultFormat), nil
+	}
+	var best *format
+	bestQ := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q <= bestQ {
+			continue
+		}
+		if f := fs.byMediaType(mediaType); f != nil {
+			best, bestQ = f, q
+		}
+	}
+	if best == nil {
+		return nil, domain.ErrNotAcceptable
+	}
+	return best, nil
+}
+
+// negotiateJSON проверяет, что клиент принимает JSON: ответы, которые не являются цитатами
+// (оценки, итоги пакета, статус модерации), есть только в нем
+func (fs formats) negotiateJSON(r *http.Request) error {
+	f, err := fs.negotiate(r)
+	if err != nil {
+		return err
+	}
+	if f.name != defaultFormat {
+		return domain.ErrNotAcceptable
+	}
+	return nil
+}
+
+func (fs formats) byName(name string) *format {
+	for i := range fs {
+		if fs[i].name == name {
+			return &fs[i]
+		}
+	}
+	return nil
+}
+
+// byMediaType находит формат по типу из Accept, в том числе по шаблонам */* и text/*:
+// шаблону подходит первый зарегистрированный формат
+func (fs formats) byMediaType(mediaType string) *format {
+	if mediaType == "*/*" {
+		return fs.byName(defaultFormat)
+	}
+	prefix, wildcard := strings.CutSuffix(mediaType, "*")
+	for i := range fs {
+		for _, t := range fs[i].mediaTypes {
+			if t == mediaType || wildcard && strings.HasPrefix(t, prefix) {
+				return &fs[i]
+			}
+		}
+	}
+	return nil
+}
+
+// render выводит ответ в буфер, чтобы ошибка формата стала 500, а не оборванным ответом
+func (fs formats) render(r *http.Request, fn func(Renderer, io.Writer) error) (*bytes.Buffer, string, error) {
+	f, err := fs.negotiate(r)
+	if err != nil {
+		return nil, "", err
+	}
+	var body bytes.Buffer
+	if err := fn(f.renderer, &body); err != nil {
+		return nil, "", err
+	}
+	return &body, f.renderer.ContentType(), nil
+}
+
+// varyAccept отмечает, что ответ зависит от Accept, не повторяя значение в Vary
+func varyAccept(w http.ResponseWriter) {
+	for _, v := range w.Header().Values("Vary") {
+		if v == "Accept" {
+			return
+		}
+	}
+	w.Header().Add("Vary", "Accept")
+}
+
+// sendRendered отвечает цитатами в формате, выбранном по запросу
+func sendRendered(w http.ResponseWriter, r *http.Request, logger *zap.Logger, fs formats, statusCode int, fn func(Renderer, io.Writer) error) {
+	varyAccept(w)
+	body, contentType, err := fs.render(r, fn)
+	if err != nil {
+		sendError(w, r, logger, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+	if _, err := w.Write(body.Bytes()); err != nil {
+		logger.Error("Ошибка отправки ответа", zap.Error(err))
+	}
+}
+
+// sendQuote отвечает цитатой в формате, выбранном по запросу
+func (h *Handler) sendQuote(w http.ResponseWriter, r *http.Request, quote *models.Quote) {
+	h.sendQuoteStatus(w, r, http.StatusOK, quote)
+}
+
+// sendQuoteStatus - sendQuote с кодом ответа, например 201 для созданной цитаты
+func (h *Handler) sendQuoteStatus(w http.ResponseWriter, r *http.Request, statusCode int, quote *models.Quote) {
+	sendRendered(w, r, h.logger, h.formats, statusCode, func(renderer Renderer, w io.Writer) error {
+		return renderer.RenderQuote(w, quote)
+	})
+}
+
+// sendQuotes отвечает списком цитат в формате, выбранном по запросу
+func (h *Handler) sendQuotes(w http.ResponseWriter, r *http.Request, quotes []models.Quote) {
+	sendRendered(w, r, h.logger, h.formats, http.StatusOK, func(renderer Renderer, w io.Writer) error {
+		return renderer.RenderQuotes(w, quotes)
+	})
+}
+
+// acceptable проверяет формат ответа до выполнения изменяющего запроса,
+// чтобы 406 не пришел на уже сохраненное изменение
+func (h *Handler) acceptable(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		varyAccept(w)
+		if _, err := h.formats.negotiate(r); err != nil {
+			h.sendError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// jsonOnly - acceptable для запросов, чей ответ есть только в JSON (оценки, итоги пакета)
+func (h *Handler) jsonOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		varyAccept(w)
+		if err := h.formats.negotiateJSON(r); err != nil {
+			h.sendError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// sendData отвечает JSON-конвертом {"data": ...} для ответов, которые есть только в JSON:
+// клиент, не принимающий JSON, получает 406
+func (h *Handler) sendData(w http.ResponseWriter, r *http.Request, statusCode int, data interface{}) {
+	varyAccept(w)
+	if err := h.formats.negotiateJSON(r); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.sendJSON(w, statusCode, map[string]interface{}{
+		"data": data,
+	})
+}
+
+// jsonRenderer - конверт {"data": ...}, как у остальных ответов API
+type jsonRenderer struct{}
+
+func (jsonRenderer) ContentType() string { return "application/json" }
+
+func (jsonRenderer) RenderQuote(w io.Writer, quote *models.Quote) error {
+	return json.NewEncoder(w).Encode(map[string]interface{}{"data": quote})
+}
+
+func (jsonRenderer) RenderQuotes(w io.Writer, quotes []models.Quote) error {
+	return json.NewEncoder(w).Encode(map[string]interface{}{"data": quotes})
+}
+
+// csvRenderer - строка заголовков и по строке на цитату, теги через ";". Значения, которые
+// электронная таблица приняла бы за формулу, начинаются с "'".
+type csvRenderer struct{}
+
+func (csvRenderer) ContentType() string { return "text/csv; charset=utf-8" }
+
+func (c csvRenderer) RenderQuote(w io.Writer, quote *models.Quote) error {
+	return c.RenderQuotes(w, []models.Quote{*quote})
+}
+
+func (csvRenderer) RenderQuotes(w io.Writer, quotes []models.Quote) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"id", "author", "quote", "tags", "created_at"}); err != nil {
+		return err
+	}
+	for _, q := range quotes {
+		record := []string{strconv.Itoa(q.ID), csvSafe(q.Author), csvSafe(q.Quote), csvSafe(strings.Join(q.Tags, ";")), q.CreatedAt.UTC().Format(time.RFC3339)}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvSafe экранирует значение, начинающееся с символа формулы (=, +, -, @): открытый
+// в Excel или LibreOffice файл иначе выполнил бы текст цитаты как формулу. Табуляция и
+// возврат каретки в начале тоже экранируются: некоторые таблицы отбрасывают их перед формулой
+func csvSafe(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// textRenderer - по строке `"цитата" — автор` на цитату, для скриптов и MOTD
+type textRenderer struct{}
+
+func (textRenderer) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (t textRenderer) RenderQuote(w io.Writer, quote *models.Quote) error {
+	return t.RenderQuotes(w, []models.Quote{*quote})
+}
+
+func (textRenderer) RenderQuotes(w io.Writer, quotes []models.Quote) error {
+	for _, q := range quotes {
+		// Переводы строк внутри цитаты сломали бы формат "одна цитата - одна строка"
+		text := strings.Join(strings.Fields(q.Quote), " ")
+		if _, err := io.WriteString(w, `"`+text+`" — `+q.Author+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type xmlQuote struct {
+	XMLName   xml.Name  `xml:"quote"`
+	ID        int       `xml:"id,attr"`
+	Author    string    `xml:"author"`
+	Text      string    `xml:"text"`
+	Tags      []string  `xml:"tags>tag,omitempty"`
+	CreatedAt time.Time `xml:"created_at"`
+}
+
+type xmlQuotes struct {
+	XMLName xml.Name   `xml:"quotes"`
+	Quotes  []xmlQuote `xml:"quote"`
+}
+
+func newXMLQuote(q *models.Quote) xmlQuote {
+	return xmlQuote{ID: q.ID, Author: q.Author, Text: q.Quote, Tags: q.Tags, CreatedAt: q.CreatedAt.UTC()}
+}
+
+// xmlRenderer - <quote> для одной цитаты и <quotes> для списка
+type xmlRenderer struct{}
+
+func (xmlRenderer) ContentType() string { return "application/xml; charset=utf-8" }
+
+func (x xmlRenderer) RenderQuote(w io.Writer, quote *models.Quote) error {
+	return x.encode(w, newXMLQuote(quote))
+}
+
+func (x xmlRenderer) RenderQuotes(w io.Writer, quotes []models.Quote) error {
+	list := xmlQuotes{Quotes: make([]xmlQuote, 0, len(quotes))}
+	for i := range quotes {
+		list.Quotes = append(list.Quotes, newXMLQuote(&quotes[i]))
+	}
+	return x.encode(w, list)
+}
+
+func (xmlRenderer) encode(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// markdownRenderer - цитаты блоками "> " с подписью автора
+type markdownRenderer struct{}
+
+func (markdownRenderer) ContentType() string { return "text/markdown; charset=utf-8" }
+
+func (m markdownRenderer) RenderQuote(w io.Writer, quote *models.Quote) error {
+	return m.RenderQuotes(w, []models.Quote{*quote})
+}
+
+func (markdownRenderer) RenderQuotes(w io.Writer, quotes []models.Quote) error {
+	var b strings.Builder
+	for i, q := range quotes {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		for _, line := range strings.Split(q.Quote, "\n") {
+			b.WriteString("> " + line + "\n")
+		}
+		b.WriteString(">\n> — " + q.Author + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var htmlQuotes = template.Must(template.New("quotes").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Quotes</title></head>
+<body>
+{{- range .}}
+<figure id="quote-{{.ID}}">
+  <blockquote><p>{{.Quote}}</p></blockquote>
+  <figcaption>— {{.Author}}</figcaption>
+</figure>
+{{- end}}
+</body>
+</html>
+`))
+
+// htmlRenderer - страница с цитатами; текст экранируется html/template
+type htmlRenderer struct{}
+
+func (htmlRenderer) ContentType() string { return "text/html; charset=utf-8" }
+
+func (h htmlRenderer) RenderQuote(w io.Writer, quote *models.Quote) error {
+	return h.RenderQuotes(w, []models.Quote{*quote})
+}
+
+func (htmlRenderer) RenderQuotes(w io.Writer, quotes []models.Quote) error {
+	return htmlQuotes.Execute(w, quotes)
+}
@@ -0,0 +1,125 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"quote-service/internal/service"
+
+	"go.uber.org/zap"
+)
+
+// eventReset просит клиента заново загрузить список: пропущенные события уже недоступны
+const eventReset = "reset"
+
+// streamRetry - через сколько миллисекунд EventSource переподключается после обрыва
+const streamRetry = 3000
+
+// WithEvents включает GET /quotes/stream и рассылку событий из QuoteService.
+// heartbeat - период комментариев, которые не дают прокси закрыть простаивающее соединение.
+func WithEvents(broker *service.EventBroker, heartbeat time.Duration) Option {
+	return func(h *Handler) {
+		h.events = broker
+		h.heartbeat = heartbeat
+		h.serviceOpts = append(h.serviceOpts, service.WithEvents(broker))
+	}
+}
+
+type deletedEvent struct {
+	ID int `json:"id"`
+}
+
+// streamQuotes отдает события created и deleted в формате Server-Sent Events. Клиент возобновляет поток
+// с заголовком Last-Event-ID (или ?last_event_id=); если пропущенные события уже вытеснены, приходит reset.
+func (h *Handler) streamQuotes(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+
+	lastID, err := lastEventID(r)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	sub, missed, ok := h.events.Subscribe(lastID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry)
+	if !ok {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", eventReset)
+	}
+	for _, ev := range missed {
+		if err := writeEvent(w, ev); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		h.logger.Error("Ошибка отправки событий", zap.Error(err))
+		return
+	}
+
+	heartbeat := h.heartbeat
+	if heartbeat <= 0 {
+		heartbeat = 15 * time.Second
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, open := <-sub.C:
+			if !open {
+				// Клиент не успевал читать или сервис останавливается: EventSource переподключится
+				// с последним полученным ID и догонит пропущенное из истории
+				return
+			}
+			if err := writeEvent(w, ev); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev service.Event) error {
+	var payload interface{} = ev.Quote
+	if ev.Type == service.EventDeleted {
+		payload = deletedEvent{ID: ev.Quote.ID}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.FeedType(), data)
+	return err
+}
+
+func lastEventID(r *http.Request) (int64, error) {
+	raw := r.Header.Get("Last-Event-ID")
+	if raw == "" {
+		raw = r.URL.Query().Get("last_event_id")
+	}
+	if raw == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, invalid(violation("last_event_id", ViolationInvalidType, "must be a non-negative integer"))
+	}
+	return id, nil
+}
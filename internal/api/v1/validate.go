@@ -0,0 +1,149 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"quote-service/internal/domain"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxBodyBytes - наибольший размер тела запроса
+const maxBodyBytes = 1 << 20
+
+// Коды нарушений при разборе запроса. Нарушения правил содержимого цитаты описаны в service/filter.go.
+const (
+	ViolationRequired     = "required"
+	ViolationUnknownField = "unknown_field"
+	ViolationInvalidType  = "invalid_type"
+	ViolationMalformed    = "malformed_json"
+	ViolationOutOfRange   = "out_of_range"
+)
+
+// validator реализуют тела запросов, у которых кроме типов полей есть свои правила
+type validator interface {
+	Validate() []domain.Violation
+}
+
+// decodeJSON строго разбирает тело запроса в dst: проверяет Content-Type и размер тела,
+// отклоняет неизвестные поля и лишние данные после объекта, затем вызывает Validate.
+// Все ошибки уже переведены в доменные и передаются в sendError как есть.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
+			return domain.ErrUnsupportedMediaType
+		}
+	}
+
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return invalid(violation("", ViolationMalformed, "request body must contain a single JSON object"))
+	}
+
+	if v, ok := dst.(validator); ok {
+		if violations := v.Validate(); len(violations) > 0 {
+			return invalid(violations...)
+		}
+	}
+	return nil
+}
+
+// decodeError переводит ошибку encoding/json в нарушение с именем поля
+func decodeError(err error) error {
+	var (
+		maxErr  *http.MaxBytesError
+		typeErr *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &maxErr):
+		return domain.ErrPayloadTooLarge
+	case errors.Is(err, io.EOF):
+		return invalid(violation("", ViolationRequired, "request body is required"))
+	case errors.As(err, &typeErr):
+		return invalid(violation(typeErr.Field, ViolationInvalidType, "must be "+typeName(typeErr.Type)))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json не экспортирует тип этой ошибки, имя поля есть только в тексте
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		return invalid(violation(field, ViolationUnknownField, "unknown field"))
+	default:
+		return invalid(violation("", ViolationMalformed, "request body is not valid JSON"))
+	}
+}
+
+// typeName описывает ожидаемый тип поля так, как его видит клиент
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array of " + strings.TrimPrefix(strings.TrimPrefix(typeName(t.Elem()), "a "), "an ")
+	default:
+		return "an object"
+	}
+}
+
+// urlInt разбирает целочисленный параметр пути
+func urlInt(r *http.Request, name string) (int, error) {
+	n, err := strconv.Atoi(chi.URLParam(r, name))
+	if err != nil {
+		return 0, invalid(violation(name, ViolationInvalidType, "must be an integer"))
+	}
+	return n, nil
+}
+
+// queryInt разбирает необязательный целочисленный параметр строки запроса
+func queryInt(r *http.Request, name string, def int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, invalid(violation(name, ViolationInvalidType, "must be an integer"))
+	}
+	return n, nil
+}
+
+func invalid(violations ...domain.Violation) error {
+	return &domain.ValidationError{Message: "request validation failed", Violations: violations}
+}
+
+func violation(field, code, message string) domain.Violation {
+	return domain.Violation{Field: field, Code: code, Message: message}
+}
+
+// required добавляет нарушение, если строковое поле пустое
+func required(violations []domain.Violation, field, value string) []domain.Violation {
+	if strings.TrimSpace(value) == "" {
+		return append(violations, violation(field, ViolationRequired, "is required"))
+	}
+	return violations
+}
+
+// inRange добавляет нарушение, если значение вне [lo, hi]
+func inRange(violations []domain.Violation, field string, value, lo, hi int) []domain.Violation {
+	if value < lo || value > hi {
+		return append(violations, violation(field, ViolationOutOfRange, fmt.Sprintf("must be between %d and %d", lo, hi)))
+	}
+	return violations
+}
@@ -0,0 +1,172 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// WebhookHandler управляет вебхуками по HTTP. Доступ разрешает политика (действие webhooks.manage).
+type WebhookHandler struct {
+	logger   *zap.Logger
+	webhooks *service.WebhookService
+	policy   service.Policy
+}
+
+func NewWebhookHandler(webhooks *service.WebhookService, policy service.Policy, logger *zap.Logger) *WebhookHandler {
+	return &WebhookHandler{logger: logger, webhooks: webhooks, policy: policy}
+}
+
+func (h *WebhookHandler) Routes() *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(h.authorize)
+	r.Get("/", h.listWebhooks)                                       // GET /webhooks
+	r.Post("/", h.registerWebhook)                                   // POST /webhooks
+	r.Get("/{id}", h.getWebhook)                                     // GET /webhooks/{id}
+	r.Delete("/{id}", h.deleteWebhook)                               // DELETE /webhooks/{id}
+	r.Get("/{id}/deliveries", h.listDeliveries)                      // GET /webhooks/{id}/deliveries?limit=50
+	r.Post("/{id}/deliveries/{deliveryID}/replay", h.replayDelivery) // POST /webhooks/{id}/deliveries/{deliveryID}/replay
+	return r
+}
+
+type registerWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+func (req registerWebhookRequest) Validate() []domain.Violation {
+	return required(nil, "url", req.URL)
+}
+
+func (h *WebhookHandler) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := h.policy.Authorize(r.Context(), service.ActionManageWebhooks, nil); err != nil {
+			h.sendError(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *WebhookHandler) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.webhooks.List(r.Context())
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	if webhooks == nil {
+		webhooks = []models.Webhook{}
+	}
+	h.send(w, http.StatusOK, map[string]interface{}{"data": webhooks})
+}
+
+func (h *WebhookHandler) registerWebhook(w http.ResponseWriter, r *http.Request) {
+	var req registerWebhookRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+
+	webhook, secret, err := h.webhooks.Register(r.Context(), req.URL, req.Events)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.send(w, http.StatusCreated, map[string]interface{}{
+		"data":   webhook,
+		"secret": secret,
+	})
+}
+
+func (h *WebhookHandler) getWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := urlInt(r, "id")
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	webhook, err := h.webhooks.Get(r.Context(), id)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.send(w, http.StatusOK, map[string]interface{}{"data": webhook})
+}
+
+func (h *WebhookHandler) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := urlInt(r, "id")
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	if err := h.webhooks.Delete(r.Context(), id); err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *WebhookHandler) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := urlInt(r, "id")
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	limit, err := queryInt(r, "limit", 50)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	if v := inRange(nil, "limit", limit, 1, 100); len(v) > 0 {
+		h.sendError(w, r, invalid(v...))
+		return
+	}
+
+	deliveries, err := h.webhooks.Deliveries(r.Context(), id, limit)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+	h.send(w, http.StatusOK, map[string]interface{}{"data": deliveries})
+}
+
+func (h *WebhookHandler) replayDelivery(w http.ResponseWriter, r *http.Request) {
+	id, err := urlInt(r, "id")
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		h.sendError(w, r, invalid(violation("deliveryID", ViolationInvalidType, "must be an integer")))
+		return
+	}
+
+	delivery, err := h.webhooks.Replay(r.Context(), id, deliveryID)
+	if err != nil {
+		h.sendError(w, r, err)
+		return
+	}
+	h.send(w, http.StatusAccepted, map[string]interface{}{"data": delivery})
+}
+
+func (h *WebhookHandler) send(w http.ResponseWriter, statusCode int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		h.logger.Error("Ошибка кодирования ответа", zap.Error(err))
+	}
+}
+
+func (h *WebhookHandler) sendError(w http.ResponseWriter, r *http.Request, err error) {
+	sendError(w, r, h.logger, err)
+}
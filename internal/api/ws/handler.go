@@ -0,0 +1,108 @@
+package ws
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"quote-service/internal/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultMinInterval      = 5 * time.Second
+	defaultMaxSubscriptions = 16
+)
+
+// Handler обслуживает WebSocket API на /ws: подписки на события с фильтрами по автору и тегу,
+// случайные цитаты по запросу и ротацию цитат по таймеру сервера в одном соединении.
+// Рукопожатие - обычный GET, поэтому аутентификация проверяет область read.
+type Handler struct {
+	quotes   *service.QuoteService
+	events   *service.EventBroker
+	logger   *zap.Logger
+	cfg      config
+	upgrader websocket.Upgrader
+}
+
+type config struct {
+	minInterval      time.Duration
+	maxSubscriptions int
+	origins          []string
+}
+
+type Option func(*config)
+
+// WithMinInterval ограничивает снизу период ротации
+func WithMinInterval(d time.Duration) Option {
+	return func(c *config) {
+		c.minInterval = d
+	}
+}
+
+// WithMaxSubscriptions ограничивает число подписок и ротаций в одном соединении
+func WithMaxSubscriptions(n int) Option {
+	return func(c *config) {
+		c.maxSubscriptions = n
+	}
+}
+
+// WithAllowedOrigins разрешает рукопожатие со страниц других источников, "*" - с любых.
+// По умолчанию принимаются только соединения с того же хоста.
+func WithAllowedOrigins(origins ...string) Option {
+	return func(c *config) {
+		c.origins = origins
+	}
+}
+
+// NewHandler создает обработчик. events может быть nil - тогда подписки недоступны,
+// а случайные цитаты и ротация работают.
+func NewHandler(quotes *service.QuoteService, events *service.EventBroker, logger *zap.Logger, opts ...Option) *Handler {
+	cfg := config{minInterval: defaultMinInterval, maxSubscriptions: defaultMaxSubscriptions}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	h := &Handler{quotes: quotes, events: events, logger: logger, cfg: cfg}
+	h.upgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		CheckOrigin:     h.checkOrigin,
+	}
+	return h
+}
+
+func (h *Handler) Routes() *chi.Mux {
+	r := chi.NewRouter()
+	r.Get("/", h.serve) // GET /ws
+	return r
+}
+
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade уже ответил клиенту ошибкой
+		h.logger.Debug("Ошибка установки WebSocket-соединения", zap.Error(err))
+		return
+	}
+	newSession(h, conn).run(r.Context())
+}
+
+func (h *Handler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(h.cfg.origins, "*") || slices.Contains(h.cfg.origins, origin) {
+		return true
+	}
+	return sameHost(r, origin)
+}
+
+func sameHost(r *http.Request, origin string) bool {
+	for _, scheme := range []string{"http://", "https://"} {
+		if origin == scheme+r.Host {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,269 @@
+package ws
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/internal/service"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// memQuerier - хранилище цитат с тегами в памяти
+type memQuerier struct {
+	mu     sync.Mutex
+	quotes []models.Quote
+}
+
+func (m *memQuerier) Create(_ context.Context, quote *models.Quote) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	quote.ID = len(m.quotes) + 1
+	m.quotes = append(m.quotes, *quote)
+	return nil
+}
+
+func (m *memQuerier) GetAll(context.Context, ...string) ([]models.Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var quotes []models.Quote
+	for _, q := range m.quotes {
+		q.Tags = nil
+		quotes = append(quotes, q)
+	}
+	return quotes, nil
+}
+
+func (m *memQuerier) GetRandom(context.Context, ...string) (*models.Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.quotes) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	q := m.quotes[0]
+	return &q, nil
+}
+
+func (m *memQuerier) GetByID(_ context.Context, id int) (*models.Quote, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, q := range m.quotes {
+		if q.ID == id {
+			return &q, nil
+		}
+	}
+	return nil, domain.ErrNotFound
+}
+
+func (m *memQuerier) GetDaily(ctx context.Context, _ time.Time) (*models.Quote, error) {
+	return m.GetRandom(ctx)
+}
+
+func (m *memQuerier) GetByAuthor(ctx context.Context, author string, _ ...string) ([]models.Quote, error) {
+	quotes, _ := m.GetAll(ctx)
+	var byAuthor []models.Quote
+	for _, q := range quotes {
+		if q.Author == author {
+			byAuthor = append(byAuthor, q)
+		}
+	}
+	return byAuthor, nil
+}
+
+func (m *memQuerier) Update(context.Context, *models.Quote) error { return nil }
+
+func (m *memQuerier) Delete(_ context.Context, id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, q := range m.quotes {
+		if q.ID == id {
+			m.quotes = append(m.quotes[:i], m.quotes[i+1:]...)
+			return nil
+		}
+	}
+	return domain.ErrNotFound
+}
+
+func (m *memQuerier) Exists(context.Context, string, string) (bool, error) {
+	return false, nil
+}
+
+func (m *memQuerier) TagsByQuoteIDs(_ context.Context, ids []int) (map[int][]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tags := make(map[int][]string)
+	for _, q := range m.quotes {
+		for _, id := range ids {
+			if q.ID == id && len(q.Tags) > 0 {
+				tags[id] = q.Tags
+			}
+		}
+	}
+	return tags, nil
+}
+
+func (m *memQuerier) GetByAuthors(context.Context, []string) ([]models.Quote, error) {
+	return nil, nil
+}
+
+type testClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+}
+
+func (c *testClient) send(msg map[string]string) {
+	assert.NoError(c.t, c.conn.WriteJSON(msg))
+}
+
+func (c *testClient) receive() serverMessage {
+	var msg struct {
+		serverMessage
+		Quote *models.Quote `json:"quote"`
+	}
+	_ = c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	assert.NoError(c.t, c.conn.ReadJSON(&msg))
+	out := msg.serverMessage
+	if msg.Quote != nil {
+		out.Quote = msg.Quote
+	}
+	return out
+}
+
+func quoteOf(msg serverMessage) models.Quote {
+	if q, ok := msg.Quote.(*models.Quote); ok {
+		return *q
+	}
+	return models.Quote{}
+}
+
+func setup(t *testing.T, opts ...Option) (*testClient, *service.QuoteService, *service.EventBroker) {
+	store := &memQuerier{}
+	events := service.NewEventBroker(10, 10)
+	quotes := service.NewQuoteService(store, service.WithLoaders(store), service.WithEvents(events))
+	srv := httptest.NewServer(NewHandler(quotes, events, zap.NewNop(), opts...).Routes())
+	t.Cleanup(srv.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testClient{t: t, conn: conn}, quotes, events
+}
+
+func TestHandler_Subscriptions(t *testing.T) {
+	client, quotes, _ := setup(t)
+	ctx := context.Background()
+
+	client.send(map[string]string{"type": "subscribe", "id": "seneca", "author": " Seneca "})
+	assert.Equal(t, serverMessage{Type: msgSubscribed, ID: "seneca"}, client.receive())
+	client.send(map[string]string{"type": "subscribe", "id": "life", "tag": "Life"})
+	assert.Equal(t, serverMessage{Type: msgSubscribed, ID: "life"}, client.receive())
+
+	// не подходит ни одной подписке
+	assert.NoError(t, quotes.Create(ctx, &models.Quote{Author: "Epictetus", Quote: "Wealth consists not in having great possessions"}))
+	assert.NoError(t, quotes.Create(ctx, &models.Quote{Author: "Seneca", Quote: "While we live, let us learn", Tags: []string{"life"}}))
+
+	msg := client.receive()
+	assert.Equal(t, msgEvent, msg.Type)
+	assert.Equal(t, service.EventCreated, msg.Event)
+	assert.Equal(t, []string{"life", "seneca"}, msg.Subscriptions)
+	assert.Equal(t, 2, quoteOf(msg).ID)
+
+	client.send(map[string]string{"type": "unsubscribe", "id": "life"})
+	assert.Equal(t, serverMessage{Type: msgUnsubscribed, ID: "life"}, client.receive())
+
+	assert.NoError(t, quotes.Delete(ctx, 1))
+	msg = client.receive()
+	assert.Equal(t, service.EventDeleted, msg.Event)
+	assert.Equal(t, []string{"seneca"}, msg.Subscriptions)
+}
+
+func TestHandler_RandomAndRotation(t *testing.T) {
+	client, quotes, _ := setup(t, WithMinInterval(10*time.Millisecond))
+	ctx := context.Background()
+	assert.NoError(t, quotes.Create(ctx, &models.Quote{Author: "Epictetus", Quote: "Wealth consists not in having great possessions"}))
+	assert.NoError(t, quotes.Create(ctx, &models.Quote{Author: "Seneca", Quote: "While we live, let us learn", Tags: []string{"life"}}))
+
+	client.send(map[string]string{"type": "random", "id": "r1", "tag": "life"})
+	msg := client.receive()
+	assert.Equal(t, msgQuote, msg.Type)
+	assert.Equal(t, "r1", msg.ID)
+	assert.Equal(t, "Seneca", quoteOf(msg).Author)
+
+	client.send(map[string]string{"type": "random", "id": "r2", "author": "Nobody"})
+	msg = client.receive()
+	assert.Equal(t, msgError, msg.Type)
+	assert.Equal(t, domain.CodeNotFound, msg.Error.Code)
+
+	client.send(map[string]string{"type": "rotate", "id": "tv", "author": "Epictetus", "interval": "20ms"})
+	assert.Equal(t, serverMessage{Type: msgRotating, ID: "tv", Interval: "20ms"}, client.receive())
+	// первая цитата приходит сразу, следующие - по таймеру
+	for i := 0; i < 3; i++ {
+		msg = client.receive()
+		assert.Equal(t, msgQuote, msg.Type)
+		assert.Equal(t, "Epictetus", quoteOf(msg).Author)
+	}
+
+	client.send(map[string]string{"type": "stop", "id": "tv"})
+	for msg = client.receive(); msg.Type == msgQuote; msg = client.receive() {
+	}
+	assert.Equal(t, serverMessage{Type: msgStopped, ID: "tv"}, msg)
+}
+
+func TestHandler_Errors(t *testing.T) {
+	client, _, events := setup(t, WithMaxSubscriptions(1))
+
+	tests := []struct {
+		name string
+		msg  map[string]string
+		code domain.Code
+		want string
+	}{
+		{"missing id", map[string]string{"type": "subscribe"}, domain.CodeValidation, "id"},
+		{"unknown type", map[string]string{"type": "publish", "id": "x"}, domain.CodeValidation, "type"},
+		{"bad interval", map[string]string{"type": "rotate", "id": "tv", "interval": "soon"}, domain.CodeValidation, "interval"},
+		{"interval too short", map[string]string{"type": "rotate", "id": "tv", "interval": "1s"}, domain.CodeValidation, "interval"},
+		{"unknown rotation", map[string]string{"type": "stop", "id": "tv"}, domain.CodeNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client.send(tt.msg)
+			msg := client.receive()
+			assert.Equal(t, msgError, msg.Type)
+			assert.Equal(t, tt.code, msg.Error.Code)
+			if tt.want != "" {
+				assert.Equal(t, tt.want, msg.Error.Violations[0].Field)
+			}
+		})
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		client.send(map[string]string{"type": "subscribe", "id": "a"})
+		assert.Equal(t, msgSubscribed, client.receive().Type)
+		client.send(map[string]string{"type": "subscribe", "id": "a"})
+		assert.Equal(t, domain.CodeConflict, client.receive().Error.Code)
+		client.send(map[string]string{"type": "subscribe", "id": "b"})
+		assert.Equal(t, domain.CodeValidation, client.receive().Error.Code)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		assert.NoError(t, client.conn.WriteMessage(websocket.TextMessage, []byte("{")))
+		assert.Equal(t, domain.CodeValidation, client.receive().Error.Code)
+	})
+
+	t.Run("closed on shutdown", func(t *testing.T) {
+		events.Close()
+		_ = client.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, _, err := client.conn.ReadMessage()
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseTryAgainLater), "%v", err)
+	})
+}
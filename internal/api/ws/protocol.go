@@ -0,0 +1,111 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+
+	"go.uber.org/zap"
+)
+
+// Типы сообщений клиента
+const (
+	msgSubscribe   = "subscribe"
+	msgUnsubscribe = "unsubscribe"
+	msgRandom      = "random"
+	msgRotate      = "rotate"
+	msgStop        = "stop"
+)
+
+// Типы сообщений сервера
+const (
+	msgSubscribed   = "subscribed"
+	msgUnsubscribed = "unsubscribed"
+	msgRotating     = "rotating"
+	msgStopped      = "stopped"
+	msgEvent        = "event"
+	msgQuote        = "quote"
+	msgError        = "error"
+)
+
+// Коды нарушений совпадают с REST API
+const (
+	violationRequired   = "required"
+	violationOutOfRange = "out_of_range"
+	violationInvalid    = "invalid_type"
+	violationMalformed  = "malformed_json"
+)
+
+// clientMessage - запрос клиента. ID связывает ответы и события с запросом,
+// для subscribe и rotate он же служит именем подписки.
+type clientMessage struct {
+	Type     string `json:"type"`
+	ID       string `json:"id"`
+	Author   string `json:"author,omitempty"`
+	Tag      string `json:"tag,omitempty"`
+	Interval string `json:"interval,omitempty"`
+}
+
+// serverMessage - ответ, событие подписки или цитата ротации
+type serverMessage struct {
+	Type          string        `json:"type"`
+	ID            string        `json:"id,omitempty"`
+	Subscriptions []string      `json:"subscriptions,omitempty"`
+	Event         string        `json:"event,omitempty"`
+	EventID       int64         `json:"event_id,omitempty"`
+	Interval      string        `json:"interval,omitempty"`
+	Quote         interface{}   `json:"quote,omitempty"`
+	Error         *messageError `json:"error,omitempty"`
+}
+
+type messageError struct {
+	Code       domain.Code        `json:"code"`
+	Message    string             `json:"message"`
+	Violations []domain.Violation `json:"violations,omitempty"`
+}
+
+// deletedQuote - содержимое события deleted: удаленная цитата известна только по ID
+type deletedQuote struct {
+	ID int `json:"id"`
+}
+
+func quoteMessage(id string, quote *models.Quote) serverMessage {
+	return serverMessage{Type: msgQuote, ID: id, Quote: quote}
+}
+
+func invalid(field, code, message string) error {
+	return &domain.ValidationError{
+		Message:    "message validation failed",
+		Violations: []domain.Violation{{Field: field, Code: code, Message: message}},
+	}
+}
+
+func required(field string) error {
+	return invalid(field, violationRequired, "is required")
+}
+
+func outOfRange(field string, format string, args ...interface{}) error {
+	return invalid(field, violationOutOfRange, fmt.Sprintf(format, args...))
+}
+
+// errorMessage переводит ошибку в сообщение клиенту. Текст внутренних ошибок клиенту не попадает.
+func (s *session) errorMessage(id string, err error) serverMessage {
+	code := domain.CodeOf(err)
+	me := &messageError{Code: code, Message: err.Error()}
+	var (
+		catalogErr    *domain.Error
+		validationErr *domain.ValidationError
+	)
+	switch {
+	case code == domain.CodeInternal:
+		s.h.logger.Error("Внутренняя ошибка WebSocket API", zap.Error(err))
+		me.Message = "internal error"
+	case errors.As(err, &validationErr):
+		me.Violations = validationErr.Violations
+	case errors.As(err, &catalogErr):
+		me.Message = catalogErr.Message
+	}
+	return serverMessage{Type: msgError, ID: id, Error: me}
+}
@@ -0,0 +1,330 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"quote-service/internal/domain"
+	"quote-service/internal/service"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+const (
+	maxMessageBytes = 4 << 10
+	writeWait       = 10 * time.Second
+	pongWait        = 60 * time.Second
+	pingPeriod      = pongWait * 9 / 10
+	// outBuffer - сколько сообщений может ждать отправки, прежде чем клиент будет признан медленным
+	outBuffer = 64
+)
+
+// session - одно соединение. Запросы, события и тики ротаций обрабатываются в одной горутине run,
+// поэтому подписки и ротации не требуют блокировок. Отправка идет через writeLoop:
+// клиент, который не успевает принимать сообщения, отключается с кодом 1013.
+type session struct {
+	h    *Handler
+	conn *websocket.Conn
+
+	out       chan serverMessage
+	cancel    context.CancelFunc
+	closeCode int
+	closeText string
+
+	subs      map[string]service.QuoteFilter
+	rotations map[string]*rotation
+	ticks     chan *rotation
+}
+
+type rotation struct {
+	id     string
+	filter service.QuoteFilter
+	stop   context.CancelFunc
+}
+
+// incoming - сообщение клиента или ошибка его разбора
+type incoming struct {
+	msg clientMessage
+	err error
+}
+
+func newSession(h *Handler, conn *websocket.Conn) *session {
+	return &session{
+		h:         h,
+		conn:      conn,
+		out:       make(chan serverMessage, outBuffer),
+		closeCode: websocket.CloseNormalClosure,
+		subs:      make(map[string]service.QuoteFilter),
+		rotations: make(map[string]*rotation),
+		ticks:     make(chan *rotation),
+	}
+}
+
+func (s *session) run(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	defer s.cancel()
+
+	var events <-chan service.Event
+	if s.h.events != nil {
+		sub, _, _ := s.h.events.Subscribe(0)
+		defer sub.Close()
+		events = sub.C
+	}
+
+	requests := make(chan incoming)
+	written := make(chan struct{})
+	go s.readLoop(ctx, requests)
+	go func() {
+		s.writeLoop(ctx)
+		close(written)
+	}()
+
+	var reqs <-chan incoming = requests
+	for {
+		select {
+		case <-ctx.Done():
+			<-written
+			return
+		case req, ok := <-reqs:
+			if !ok {
+				reqs = nil
+				s.cancel()
+				continue
+			}
+			if req.err != nil {
+				s.send(s.errorMessage("", req.err))
+				continue
+			}
+			s.handle(ctx, req.msg)
+		case ev, ok := <-events:
+			if !ok {
+				// Брокер отключил отстающего подписчика или сервис останавливается
+				events = nil
+				s.close(websocket.CloseTryAgainLater, "event stream closed")
+				continue
+			}
+			s.dispatch(ev)
+		case rot := <-s.ticks:
+			if s.rotations[rot.id] == rot {
+				s.sendRandom(ctx, rot.id, rot.filter)
+			}
+		}
+	}
+}
+
+func (s *session) handle(ctx context.Context, msg clientMessage) {
+	if msg.ID == "" {
+		s.send(s.errorMessage("", required("id")))
+		return
+	}
+	filter := service.QuoteFilter{Author: msg.Author, Tag: msg.Tag}.Normalize()
+
+	switch msg.Type {
+	case msgSubscribe:
+		if s.h.events == nil {
+			s.send(s.errorMessage(msg.ID, domain.NewError(domain.CodeNotFound, "subscriptions are disabled")))
+			return
+		}
+		if err := s.reserve(msg.ID); err != nil {
+			s.send(s.errorMessage(msg.ID, err))
+			return
+		}
+		s.subs[msg.ID] = filter
+		s.send(serverMessage{Type: msgSubscribed, ID: msg.ID})
+	case msgUnsubscribe:
+		if _, ok := s.subs[msg.ID]; !ok {
+			s.send(s.errorMessage(msg.ID, domain.NewError(domain.CodeNotFound, "subscription not found")))
+			return
+		}
+		delete(s.subs, msg.ID)
+		s.send(serverMessage{Type: msgUnsubscribed, ID: msg.ID})
+	case msgRandom:
+		s.sendRandom(ctx, msg.ID, filter)
+	case msgRotate:
+		interval, err := s.interval(msg.Interval)
+		if err == nil {
+			err = s.reserve(msg.ID)
+		}
+		if err != nil {
+			s.send(s.errorMessage(msg.ID, err))
+			return
+		}
+		s.startRotation(ctx, msg.ID, filter, interval)
+		s.send(serverMessage{Type: msgRotating, ID: msg.ID, Interval: interval.String()})
+		s.sendRandom(ctx, msg.ID, filter)
+	case msgStop:
+		rot, ok := s.rotations[msg.ID]
+		if !ok {
+			s.send(s.errorMessage(msg.ID, domain.NewError(domain.CodeNotFound, "rotation not found")))
+			return
+		}
+		rot.stop()
+		delete(s.rotations, msg.ID)
+		s.send(serverMessage{Type: msgStopped, ID: msg.ID})
+	default:
+		s.send(s.errorMessage(msg.ID, invalid("type", violationInvalid,
+			"must be one of subscribe, unsubscribe, random, rotate, stop")))
+	}
+}
+
+// reserve проверяет, что ID свободен и лимит подписок не исчерпан
+func (s *session) reserve(id string) error {
+	if _, ok := s.subs[id]; ok {
+		return domain.NewError(domain.CodeConflict, "id is already in use")
+	}
+	if _, ok := s.rotations[id]; ok {
+		return domain.NewError(domain.CodeConflict, "id is already in use")
+	}
+	if len(s.subs)+len(s.rotations) >= s.h.cfg.maxSubscriptions {
+		return outOfRange("id", "at most %d subscriptions and rotations per connection", s.h.cfg.maxSubscriptions)
+	}
+	return nil
+}
+
+func (s *session) interval(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, required("interval")
+	}
+	interval, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, invalid("interval", violationInvalid, `must be a duration such as "30s"`)
+	}
+	if interval < s.h.cfg.minInterval {
+		return 0, outOfRange("interval", "must be at least %s", s.h.cfg.minInterval)
+	}
+	return interval, nil
+}
+
+// startRotation запускает таймер. Тик передается в run, который и выбирает цитату.
+func (s *session) startRotation(ctx context.Context, id string, filter service.QuoteFilter, interval time.Duration) {
+	ctx, stop := context.WithCancel(ctx)
+	rot := &rotation{id: id, filter: filter, stop: stop}
+	s.rotations[id] = rot
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				select {
+				case s.ticks <- rot:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (s *session) sendRandom(ctx context.Context, id string, filter service.QuoteFilter) {
+	quote, err := s.h.quotes.RandomMatching(ctx, filter)
+	if err != nil {
+		s.send(s.errorMessage(id, err))
+		return
+	}
+	s.send(quoteMessage(id, quote))
+}
+
+// dispatch рассылает событие подходящим подпискам одним сообщением. Об удалении сообщается всем
+// подпискам: у удаленной цитаты известен только ID, а клиент просто игнорирует незнакомые ID.
+func (s *session) dispatch(ev service.Event) {
+	var ids []string
+	for id, filter := range s.subs {
+		if ev.Type == service.EventDeleted || filter.Matches(ev.Quote) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	sort.Strings(ids)
+
+	msg := serverMessage{Type: msgEvent, Subscriptions: ids, Event: ev.FeedType(), EventID: ev.ID}
+	if ev.Type == service.EventDeleted {
+		msg.Quote = deletedQuote{ID: ev.Quote.ID}
+	} else {
+		quote := ev.Quote
+		msg.Quote = &quote
+	}
+	s.send(msg)
+}
+
+// send ставит сообщение в очередь, не блокируясь: переполненная очередь означает медленного клиента
+func (s *session) send(msg serverMessage) {
+	select {
+	case s.out <- msg:
+	default:
+		s.close(websocket.CloseTryAgainLater, "client is too slow")
+	}
+}
+
+// close завершает сессию; writeLoop отправит кадр закрытия с этим кодом
+func (s *session) close(code int, text string) {
+	if s.closeCode == websocket.CloseNormalClosure {
+		s.closeCode, s.closeText = code, text
+	}
+	s.cancel()
+}
+
+func (s *session) readLoop(ctx context.Context, requests chan<- incoming) {
+	defer close(requests)
+	s.conn.SetReadLimit(maxMessageBytes)
+	_ = s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	s.conn.SetPongHandler(func(string) error {
+		return s.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.h.logger.Debug("WebSocket-соединение прервано", zap.Error(err))
+			}
+			return
+		}
+		var req incoming
+		if err := json.Unmarshal(data, &req.msg); err != nil {
+			req.err = invalid("", violationMalformed, "message must be a JSON object")
+		}
+		select {
+		case requests <- req:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *session) writeLoop(ctx context.Context) {
+	ping := time.NewTicker(pingPeriod)
+	defer func() {
+		ping.Stop()
+		s.conn.Close()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// closeCode и closeText записаны до отмены контекста
+			msg := websocket.FormatCloseMessage(s.closeCode, s.closeText)
+			_ = s.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+			return
+		case msg := <-s.out:
+			_ = s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := s.conn.WriteJSON(msg); err != nil {
+				s.cancel()
+				return
+			}
+		case <-ping.C:
+			if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeWait)); err != nil {
+				s.cancel()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Области доступа API-ключей
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+// Роли клиентов. Старшая роль включает права младших: admin > moderator > contributor.
+const (
+	RoleContributor = "contributor"
+	RoleModerator   = "moderator"
+	RoleAdmin       = "admin"
+)
+
+var roleRank = map[string]int{
+	RoleContributor: 1,
+	RoleModerator:   2,
+	RoleAdmin:       3,
+}
+
+const keyPrefix = "qs_"
+
+// Principal - аутентифицированный клиент, от имени которого выполняется запрос
+type Principal struct {
+	ID     string
+	Name   string
+	Roles  []string
+	Scopes []string
+}
+
+// HasScope сообщает, разрешена ли клиенту область доступа. admin разрешает все.
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole сообщает, есть ли у клиента роль не ниже указанной
+func (p *Principal) HasRole(role string) bool {
+	want, ok := roleRank[role]
+	if !ok {
+		return false
+	}
+	for _, r := range p.Roles {
+		if roleRank[r] >= want {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// ValidScope проверяет, что область доступа известна
+func ValidScope(scope string) bool {
+	return scope == ScopeRead || scope == ScopeWrite || scope == ScopeAdmin
+}
+
+// ValidRole проверяет, что роль известна
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// RolesFromScopes выводит роль для ключей, выданных до появления ролей:
+// admin становится администратором, write - автором
+func RolesFromScopes(scopes []string) []string {
+	p := Principal{Scopes: scopes}
+	switch {
+	case p.HasScope(ScopeAdmin):
+		return []string{RoleAdmin}
+	case p.HasScope(ScopeWrite):
+		return []string{RoleContributor}
+	}
+	return nil
+}
+
+// GenerateKey создает новый ключ вида qs_<prefix>_<secret>. prefix хранится открыто и помогает найти ключ в списке.
+func GenerateKey() (key, prefix string, err error) {
+	buf := make([]byte, 36)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	prefix = hex.EncodeToString(buf[:4])
+	return keyPrefix + prefix + "_" + hex.EncodeToString(buf[4:]), prefix, nil
+}
+
+// HashKey возвращает хеш ключа для хранения и поиска. Ключ случайный и длинный, поэтому соль не нужна.
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(key)))
+	return hex.EncodeToString(sum[:])
+}
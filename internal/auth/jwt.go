@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type KeySource interface {
+	Key(kid string) (crypto.PublicKey, error)
+}
+
+// JWTConfig описывает, каким токенам доверять и как переводить их роли в области доступа
+type JWTConfig struct {
+	Issuer   string
+	Audience string
+	// RoleClaim - путь к списку ролей в токене через точку, например realm_access.roles
+	RoleClaim string
+	// RoleScopes сопоставляет роль из токена областям доступа
+	RoleScopes map[string][]string
+	Leeway     time.Duration
+}
+
+// JWTVerifier проверяет bearer-токены, подписанные RS256 или ES256 ключами из JWKS
+type JWTVerifier struct {
+	keys   KeySource
+	config JWTConfig
+	parser *jwt.Parser
+}
+
+func NewJWTVerifier(keys KeySource, config JWTConfig) *JWTVerifier {
+	if config.RoleClaim == "" {
+		config.RoleClaim = "roles"
+	}
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodES256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(config.Leeway),
+	}
+	if config.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		opts = append(opts, jwt.WithAudience(config.Audience))
+	}
+	return &JWTVerifier{
+		keys:   keys,
+		config: config,
+		parser: jwt.NewParser(opts...),
+	}
+}
+
+// Verify проверяет подпись и стандартные утверждения токена и возвращает клиента
+func (v *JWTVerifier) Verify(token string) (*Principal, error) {
+	claims := jwt.MapClaims{}
+	_, err := v.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keys.Key(kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	subject, err := claims.GetSubject()
+	if err != nil || subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidToken)
+	}
+
+	roles := stringList(lookupClaim(claims, v.config.RoleClaim))
+	principal := &Principal{
+		ID:    subject,
+		Name:  firstString(claims, "name", "preferred_username", "email"),
+		Roles: roles,
+	}
+	seen := make(map[string]struct{})
+	for _, role := range roles {
+		for _, scope := range v.config.RoleScopes[role] {
+			if _, ok := seen[scope]; !ok {
+				seen[scope] = struct{}{}
+				principal.Scopes = append(principal.Scopes, scope)
+			}
+		}
+	}
+	return principal, nil
+}
+
+func lookupClaim(claims jwt.MapClaims, path string) interface{} {
+	var current interface{} = map[string]interface{}(claims)
+	for _, part := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = obj[part]
+	}
+	return current
+}
+
+func stringList(v interface{}) []string {
+	switch val := v.(type) {
+	case string:
+		return strings.Fields(val)
+	case []interface{}:
+		list := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+func firstString(claims jwt.MapClaims, names ...string) string {
+	for _, name := range names {
+		if s, ok := claims[name].(string); ok && s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	t.Helper()
+	set := map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA", "kid": "rsa-1", "use": "sig", "alg": "RS256",
+				"n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC", "kid": "ec-1", "use": "sig", "alg": "ES256", "crv": "P-256",
+				"x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+		},
+	}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	keys, err := NewFileKeySet(writeJWKS(t, rsaKey, ecKey))
+	require.NoError(t, err)
+	verifier := NewJWTVerifier(keys, JWTConfig{
+		Issuer:    "https://gateway.local",
+		Audience:  "quote-service",
+		RoleClaim: "realm_access.roles",
+		RoleScopes: map[string][]string{
+			"editor": {ScopeRead, ScopeWrite},
+			"admin":  {ScopeAdmin},
+		},
+	})
+
+	claims := func(mutate func(jwt.MapClaims)) jwt.MapClaims {
+		c := jwt.MapClaims{
+			"sub":          "user-42",
+			"name":         "Ada",
+			"iss":          "https://gateway.local",
+			"aud":          "quote-service",
+			"exp":          time.Now().Add(time.Hour).Unix(),
+			"realm_access": map[string]interface{}{"roles": []string{"editor"}},
+		}
+		if mutate != nil {
+			mutate(c)
+		}
+		return c
+	}
+
+	t.Run("RS256", func(t *testing.T) {
+		principal, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(nil)))
+		require.NoError(t, err)
+		assert.Equal(t, "user-42", principal.ID)
+		assert.Equal(t, "Ada", principal.Name)
+		assert.Equal(t, []string{"editor"}, principal.Roles)
+		assert.True(t, principal.HasScope(ScopeWrite))
+		assert.False(t, principal.HasScope(ScopeAdmin))
+	})
+
+	t.Run("ES256", func(t *testing.T) {
+		principal, err := verifier.Verify(sign(t, jwt.SigningMethodES256, "ec-1", ecKey, claims(func(c jwt.MapClaims) {
+			c["realm_access"] = map[string]interface{}{"roles": []string{"admin"}}
+		})))
+		require.NoError(t, err)
+		assert.True(t, principal.HasScope(ScopeAdmin))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		_, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(func(c jwt.MapClaims) {
+			c["exp"] = time.Now().Add(-time.Hour).Unix()
+		})))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("wrong audience", func(t *testing.T) {
+		_, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims(func(c jwt.MapClaims) {
+			c["aud"] = "billing"
+		})))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		other, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, "rsa-2", other, claims(nil)))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("signed by another key with known kid", func(t *testing.T) {
+		other, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, "rsa-1", other, claims(nil)))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("HS256 rejected", func(t *testing.T) {
+		_, err := verifier.Verify(sign(t, jwt.SigningMethodHS256, "rsa-1", []byte("secret"), claims(nil)))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestParseJWKS(t *testing.T) {
+	_, err := ParseJWKS([]byte(`{"keys": []}`))
+	assert.Error(t, err)
+
+	_, err = ParseJWKS([]byte(`{"keys": [{"kty": "EC", "kid": "bad", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`))
+	assert.Error(t, err)
+}
@@ -2,7 +2,126 @@ package domain
 
 import "errors"
 
+// Code - стабильный машиночитаемый код ошибки. Клиенты разбирают код, а не текст сообщения.
+type Code string
+
+const (
+	CodeValidation   Code = "validation"
+	CodeNotFound     Code = "not_found"
+	CodeDuplicate    Code = "duplicate"
+	CodeConflict     Code = "conflict"
+	CodeUnauthorized Code = "unauthorized"
+	CodeForbidden    Code = "forbidden"
+	CodeRateLimited  Code = "rate_limited"
+	CodeInternal     Code = "internal"
+
+	CodePayloadTooLarge      Code = "payload_too_large"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeNotAcceptable        Code = "not_acceptable"
+	CodeIdempotencyMismatch  Code = "idempotency_key_reused"
+	CodeRolledBack           Code = "rolled_back"
+	CodeNotImplemented       Code = "not_implemented"
+)
+
+// Error - ошибка из каталога: код и сообщение, которое безопасно показать клиенту
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NewError создает ошибку каталога с уточненным сообщением
+func NewError(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
 var (
-	ErrInvalidInput = errors.New("invalid input")
-	ErrNotFound     = errors.New("quote not found")
+	ErrInvalidInput = NewError(CodeValidation, "invalid input")
+	ErrNotFound     = NewError(CodeNotFound, "quote not found")
+	ErrDuplicate    = NewError(CodeDuplicate, "quote already exists")
+	ErrUnauthorized = NewError(CodeUnauthorized, "unauthorized")
+	ErrForbidden    = NewError(CodeForbidden, "forbidden")
+	ErrNotPending   = NewError(CodeConflict, "quote is not awaiting moderation")
+
+	ErrCollectionNotFound = NewError(CodeNotFound, "collection not found")
+	ErrCollectionExists   = NewError(CodeConflict, "collection already exists")
+
+	ErrAPIKeyNotFound = NewError(CodeNotFound, "api key not found")
+
+	ErrWebhookNotFound  = NewError(CodeNotFound, "webhook not found")
+	ErrDeliveryNotFound = NewError(CodeNotFound, "webhook delivery not found")
+
+	ErrPayloadTooLarge      = NewError(CodePayloadTooLarge, "request body is too large")
+	ErrUnsupportedMediaType = NewError(CodeUnsupportedMediaType, "content type must be application/json")
+	ErrNotAcceptable        = NewError(CodeNotAcceptable, "none of the accepted media types is supported")
+
+	ErrIdempotencyMismatch   = NewError(CodeIdempotencyMismatch, "idempotency key was already used with a different request body")
+	ErrIdempotencyInProgress = NewError(CodeConflict, "a request with this idempotency key is still in progress")
+
+	ErrRolledBack = NewError(CodeRolledBack, "operation was rolled back because another operation in the batch failed")
+
+	ErrSearchDisabled = NewError(CodeNotImplemented, "search is not enabled")
 )
+
+// CodeOf возвращает код ошибки из каталога. Все, что не входит в каталог, считается внутренней ошибкой.
+func CodeOf(err error) Code {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return CodeInternal
+}
+
+// DuplicateError - новая цитата почти совпадает с уже сохраненными
+type DuplicateError struct {
+	Candidates []int
+}
+
+func (e *DuplicateError) Error() string {
+	return "likely duplicate of existing quotes"
+}
+
+func (e *DuplicateError) Unwrap() error {
+	return ErrDuplicate
+}
+
+// ForbiddenError - политика доступа запретила действие
+type ForbiddenError struct {
+	Action string
+	Reason string
+}
+
+func (e *ForbiddenError) Error() string {
+	return "forbidden: " + e.Reason
+}
+
+func (e *ForbiddenError) Unwrap() error {
+	return ErrForbidden
+}
+
+// Violation - нарушение правила проверки содержимого. Code разбирается клиентом, Message - для человека.
+type Violation struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationError - запрос или цитата не прошли проверку. Без Message считается нарушением правил содержимого.
+type ValidationError struct {
+	Message    string
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return "quote violates content rules"
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrInvalidInput
+}
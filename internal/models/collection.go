@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// Collection - именованная упорядоченная подборка цитат
+type Collection struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	OwnerID     string    `json:"owner_id,omitempty"`
+	QuoteIDs    []int     `json:"quote_ids"`
+	Quotes      []Quote   `json:"quotes,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Типы событий изменения опубликованных цитат
+const (
+	EventCreated  = "created"
+	EventDeleted  = "deleted"
+	EventApproved = "approved"
+	// EventUpdated - изменен текст или автор опубликованной цитаты
+	EventUpdated = "updated"
+)
+
+// OutboxEvent - запись журнала событий, сделанная в транзакции изменения цитаты.
+// Для deleted в Quote заполнен только ID.
+type OutboxEvent struct {
+	ID        int64
+	Type      string
+	Quote     Quote
+	CreatedAt time.Time
+}
@@ -0,0 +1,9 @@
+package models
+
+// IdempotentResponse - первый ответ на запрос с Idempotency-Key. StatusCode = 0, пока запрос выполняется.
+type IdempotentResponse struct {
+	Fingerprint string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
@@ -2,9 +2,66 @@ package models
 
 import "time"
 
+// Статусы модерации цитаты. Читателям видны только одобренные цитаты.
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+)
+
 type Quote struct {
 	ID        int       `json:"id"`
 	Author    string    `json:"author"`
 	Quote     string    `json:"quote"`
 	CreatedAt time.Time `json:"created_at"`
+	// UpdatedAt - время последнего изменения текста, автора или статуса модерации
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// OwnerID - идентификатор клиента-создателя; в ответы API и события не попадает
+	OwnerID          string `json:"-"`
+	Status           string `json:"status,omitempty"`
+	ModerationReason string `json:"moderation_reason,omitempty"`
+	// Tags сохраняются при создании; при чтении заполняются только там, где их запросили (GraphQL)
+	Tags         []string  `json:"tags,omitempty"`
+	AvgRating    float64   `json:"avg_rating"`
+	RatingsCount int       `json:"ratings_count"`
+	Likes        int       `json:"likes"`
+	Views        ViewStats `json:"views"`
+}
+
+// ValidStatus проверяет, что статус модерации известен
+func ValidStatus(status string) bool {
+	return status == StatusPending || status == StatusApproved || status == StatusRejected
+}
+
+// RatingStats - агрегированные оценки и лайки цитаты
+type RatingStats struct {
+	AvgRating    float64 `json:"avg_rating"`
+	RatingsCount int     `json:"ratings_count"`
+	Likes        int     `json:"likes"`
+}
+
+// SetStats переносит агрегаты в цитату
+func (q *Quote) SetStats(stats RatingStats) {
+	q.AvgRating = stats.AvgRating
+	q.RatingsCount = stats.RatingsCount
+	q.Likes = stats.Likes
+}
+
+// ViewStats - сколько раз цитата была выдана через GET /quotes/{id}, random и daily
+type ViewStats struct {
+	Get    int64 `json:"get"`
+	Random int64 `json:"random"`
+	Daily  int64 `json:"daily"`
+}
+
+func (v ViewStats) Total() int64 {
+	return v.Get + v.Random + v.Daily
+}
+
+func (v ViewStats) Add(other ViewStats) ViewStats {
+	return ViewStats{
+		Get:    v.Get + other.Get,
+		Random: v.Random + other.Random,
+		Daily:  v.Daily + other.Daily,
+	}
 }
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/pkg/logger"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (s *Storage) CreateAPIKey(ctx context.Context, key *models.APIKey, hash string) error {
+	query := `INSERT INTO api_keys (name, prefix, key_hash, scopes, roles) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`
+	roles := key.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+	if err := s.conn(ctx).QueryRow(ctx, query, key.Name, key.Prefix, hash, key.Scopes, roles).Scan(&key.ID, &key.CreatedAt); err != nil {
+		logger.Errorf("Ошибка создания API-ключа: %v", err)
+		return err
+	}
+	return nil
+}
+
+// FindAPIKey ищет действующий (не отозванный) ключ по хешу
+func (s *Storage) FindAPIKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	query := `SELECT id, name, prefix, scopes, roles, created_at FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`
+	var key models.APIKey
+	err := s.conn(ctx).QueryRow(ctx, query, hash).Scan(&key.ID, &key.Name, &key.Prefix, &key.Scopes, &key.Roles, &key.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, domain.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		logger.Errorf("Ошибка поиска API-ключа: %v", err)
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *Storage) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	query := `SELECT id, name, prefix, scopes, roles, created_at, revoked_at FROM api_keys ORDER BY id`
+	rows, err := s.conn(ctx).Query(ctx, query)
+	if err != nil {
+		logger.Errorf("Ошибка получения API-ключей: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		var key models.APIKey
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.Scopes, &key.Roles, &key.CreatedAt, &key.RevokedAt); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int) error {
+	query := `UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL`
+	result, err := s.conn(ctx).Exec(ctx, query, id)
+	if err != nil {
+		logger.Errorf("Ошибка отзыва API-ключа: %v", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrAPIKeyNotFound
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/pkg/logger"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (s *Storage) CreateCollection(ctx context.Context, c *models.Collection) error {
+	query := `
+        WITH c AS (
+            INSERT INTO collections (name, description, owner_id) VALUES ($1, $2, NULLIF($4, ''))
+            RETURNING id, created_at, updated_at
+        ), items AS (
+            INSERT INTO collection_quotes (collection_id, quote_id, position)
+            SELECT c.id, u.quote_id, u.position
+            FROM c, unnest($3::int[]) WITH ORDINALITY AS u(quote_id, position)
+        )
+        SELECT id, created_at, updated_at FROM c
+    `
+	ids := c.QuoteIDs
+	if ids == nil {
+		ids = []int{}
+	}
+	err := s.conn(ctx).QueryRow(ctx, query, c.Name, c.Description, ids, c.OwnerID).Scan(&c.ID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		if err := collectionError(err); err != nil {
+			return err
+		}
+		logger.Errorf("Ошибка создания подборки: %v", err)
+		return err
+	}
+	c.QuoteIDs = ids
+	return nil
+}
+
+// GetCollections возвращает подборки. В QuoteIDs, как и в выдаче цитат, попадают только одобренные цитаты.
+func (s *Storage) GetCollections(ctx context.Context) ([]models.Collection, error) {
+	query := `
+        SELECT c.id, c.name, c.description, COALESCE(c.owner_id, ''), c.created_at, c.updated_at,
+            COALESCE(array_agg(q.id ORDER BY cq.position, q.id) FILTER (WHERE q.id IS NOT NULL), '{}')
+        FROM collections c
+        LEFT JOIN collection_quotes cq ON cq.collection_id = c.id
+        LEFT JOIN quotes q ON q.id = cq.quote_id AND q.status = 'approved'
+        GROUP BY c.id
+        ORDER BY c.id
+    `
+	rows, err := s.conn(ctx).Query(ctx, query)
+	if err != nil {
+		logger.Errorf("Ошибка получения подборок: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var collections []models.Collection
+	for rows.Next() {
+		var c models.Collection
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.OwnerID, &c.CreatedAt, &c.UpdatedAt, &c.QuoteIDs); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return collections, nil
+}
+
+// GetCollection возвращает подборку с ID одобренных цитат в заданном порядке
+func (s *Storage) GetCollection(ctx context.Context, id int) (*models.Collection, error) {
+	query := `
+        SELECT c.id, c.name, c.description, COALESCE(c.owner_id, ''), c.created_at, c.updated_at,
+            COALESCE(array_agg(q.id ORDER BY cq.position, q.id) FILTER (WHERE q.id IS NOT NULL), '{}')
+        FROM collections c
+        LEFT JOIN collection_quotes cq ON cq.collection_id = c.id
+        LEFT JOIN quotes q ON q.id = cq.quote_id AND q.status = 'approved'
+        WHERE c.id = $1
+        GROUP BY c.id
+    `
+	var c models.Collection
+	err := s.conn(ctx).QueryRow(ctx, query, id).Scan(&c.ID, &c.Name, &c.Description, &c.OwnerID, &c.CreatedAt, &c.UpdatedAt, &c.QuoteIDs)
+	if err == pgx.ErrNoRows {
+		return nil, domain.ErrCollectionNotFound
+	}
+	if err != nil {
+		logger.Errorf("Ошибка получения подборки: %v", err)
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *Storage) UpdateCollection(ctx context.Context, c *models.Collection) error {
+	query := `
+        UPDATE collections SET name = $2, description = $3, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1
+        RETURNING created_at, updated_at
+    `
+	err := s.conn(ctx).QueryRow(ctx, query, c.ID, c.Name, c.Description).Scan(&c.CreatedAt, &c.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return domain.ErrCollectionNotFound
+	}
+	if err != nil {
+		if err := collectionError(err); err != nil {
+			return err
+		}
+		logger.Errorf("Ошибка обновления подборки: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) DeleteCollection(ctx context.Context, id int) error {
+	result, err := s.conn(ctx).Exec(ctx, `DELETE FROM collections WHERE id = $1`, id)
+	if err != nil {
+		logger.Errorf("Ошибка удаления подборки: %v", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrCollectionNotFound
+	}
+	return nil
+}
+
+// AddToCollection добавляет цитату в конец подборки. Повторное добавление ничего не меняет.
+func (s *Storage) AddToCollection(ctx context.Context, id, quoteID int) error {
+	query := `
+        INSERT INTO collection_quotes (collection_id, quote_id, position)
+        SELECT $1, $2, COALESCE(MAX(position), 0) + 1 FROM collection_quotes WHERE collection_id = $1
+        ON CONFLICT (collection_id, quote_id) DO NOTHING
+    `
+	if _, err := s.conn(ctx).Exec(ctx, query, id, quoteID); err != nil {
+		if err := collectionError(err); err != nil {
+			return err
+		}
+		logger.Errorf("Ошибка добавления цитаты в подборку: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) RemoveFromCollection(ctx context.Context, id, quoteID int) error {
+	query := `DELETE FROM collection_quotes WHERE collection_id = $1 AND quote_id = $2`
+	result, err := s.conn(ctx).Exec(ctx, query, id, quoteID)
+	if err != nil {
+		logger.Errorf("Ошибка удаления цитаты из подборки: %v", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// ReorderCollection задает новый порядок цитат, quoteIDs должен содержать все цитаты подборки
+func (s *Storage) ReorderCollection(ctx context.Context, id int, quoteIDs []int) error {
+	query := `
+        UPDATE collection_quotes cq SET position = u.position
+        FROM unnest($2::int[]) WITH ORDINALITY AS u(quote_id, position)
+        WHERE cq.collection_id = $1 AND cq.quote_id = u.quote_id
+    `
+	if _, err := s.conn(ctx).Exec(ctx, query, id, quoteIDs); err != nil {
+		logger.Errorf("Ошибка изменения порядка подборки: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) GetRandomFromCollection(ctx context.Context, id int) (*models.Quote, error) {
+	query := `
+        SELECT q.id, q.author, q.quote, q.created_at, COALESCE(q.owner_id, ''), q.status, COALESCE(q.moderation_reason, ''), q.updated_at
+        FROM collection_quotes cq
+        JOIN quotes q ON q.id = cq.quote_id
+        WHERE cq.collection_id = $1 AND q.status = 'approved'
+        ORDER BY RANDOM()
+        LIMIT 1
+    `
+	var q models.Quote
+	err := s.conn(ctx).QueryRow(ctx, query, id).Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt, &q.OwnerID, &q.Status, &q.ModerationReason, &q.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		logger.Errorf("Ошибка получения случайной цитаты подборки: %v", err)
+		return nil, err
+	}
+	return &q, nil
+}
+
+// collectionError переводит нарушения ограничений подборок в доменные ошибки
+func collectionError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return nil
+	}
+	switch pgErr.Code {
+	case "23505":
+		return domain.ErrCollectionExists
+	case "23503":
+		if strings.Contains(pgErr.ConstraintName, "collection_id") {
+			return domain.ErrCollectionNotFound
+		}
+		return domain.ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"context"
+	"quote-service/internal/models"
+	"quote-service/pkg/logger"
+	"time"
+)
+
+// ReserveIdempotencyKey занимает ключ на время выполнения запроса lease. Истекший ключ занимается заново.
+// Если ключ уже занят, возвращает сохраненный ответ (или StatusCode = 0, если запрос еще выполняется).
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, key, fingerprint string, lease time.Duration) (*models.IdempotentResponse, error) {
+	query := `
+        INSERT INTO idempotency_keys (key, fingerprint, expires_at)
+        VALUES ($1, $2, CURRENT_TIMESTAMP + $3 * INTERVAL '1 second')
+        ON CONFLICT (key) DO UPDATE SET
+            fingerprint = EXCLUDED.fingerprint,
+            status_code = NULL,
+            content_type = NULL,
+            body = NULL,
+            created_at = CURRENT_TIMESTAMP,
+            expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at <= CURRENT_TIMESTAMP
+    `
+	result, err := s.conn(ctx).Exec(ctx, query, key, fingerprint, lease.Seconds())
+	if err != nil {
+		logger.Errorf("Ошибка сохранения ключа идемпотентности: %v", err)
+		return nil, err
+	}
+	if result.RowsAffected() == 1 {
+		return nil, nil
+	}
+
+	query = `
+        SELECT fingerprint, COALESCE(status_code, 0), COALESCE(content_type, ''), COALESCE(body, ''::bytea)
+        FROM idempotency_keys WHERE key = $1
+    `
+	var resp models.IdempotentResponse
+	if err := s.conn(ctx).QueryRow(ctx, query, key).Scan(&resp.Fingerprint, &resp.StatusCode, &resp.ContentType, &resp.Body); err != nil {
+		logger.Errorf("Ошибка получения ключа идемпотентности: %v", err)
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SaveIdempotentResponse запоминает ответ на запрос, занявший ключ, и продлевает ключ на ttl
+func (s *Storage) SaveIdempotentResponse(ctx context.Context, key string, resp models.IdempotentResponse, ttl time.Duration) error {
+	query := `
+        UPDATE idempotency_keys
+        SET status_code = $2, content_type = $3, body = $4, expires_at = CURRENT_TIMESTAMP + $5 * INTERVAL '1 second'
+        WHERE key = $1
+    `
+	if _, err := s.conn(ctx).Exec(ctx, query, key, resp.StatusCode, resp.ContentType, resp.Body, ttl.Seconds()); err != nil {
+		logger.Errorf("Ошибка сохранения ответа по ключу идемпотентности: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey освобождает ключ, чтобы запрос можно было повторить
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	if _, err := s.conn(ctx).Exec(ctx, `DELETE FROM idempotency_keys WHERE key = $1`, key); err != nil {
+		logger.Errorf("Ошибка освобождения ключа идемпотентности: %v", err)
+		return err
+	}
+	return nil
+}
+
+// PurgeIdempotencyKeys удаляет истекшие ключи
+func (s *Storage) PurgeIdempotencyKeys(ctx context.Context) error {
+	if _, err := s.conn(ctx).Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP`); err != nil {
+		logger.Errorf("Ошибка удаления истекших ключей идемпотентности: %v", err)
+		return err
+	}
+	return nil
+}
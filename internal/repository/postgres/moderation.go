@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"context"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/pkg/logger"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Moderate меняет статус цитаты, ожидающей проверки, и запоминает, кто и почему принял решение.
+// Решение по уже проверенной цитате возвращает domain.ErrNotPending. Одобрение записывается
+// в журнал событий вместе с тегами цитаты.
+func (s *Storage) Moderate(ctx context.Context, id int, status, reason, moderator string) (*models.Quote, error) {
+	var quote *models.Quote
+	err := s.recorded(ctx, func(ctx context.Context) (*models.OutboxEvent, error) {
+		var err error
+		if quote, err = s.moderate(ctx, id, status, reason, moderator); err != nil {
+			return nil, err
+		}
+		if status != models.StatusApproved || !s.outbox {
+			return nil, nil
+		}
+		tags, err := s.TagsByQuoteIDs(ctx, []int{id})
+		if err != nil {
+			return nil, err
+		}
+		announced := *quote
+		announced.Tags = tags[id]
+		return &models.OutboxEvent{Type: models.EventApproved, Quote: announced}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quote, nil
+}
+
+func (s *Storage) moderate(ctx context.Context, id int, status, reason, moderator string) (*models.Quote, error) {
+	query := `
+        UPDATE quotes
+        SET status = $2, moderation_reason = NULLIF($3, ''), moderated_by = NULLIF($4, ''), moderated_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+        WHERE id = $1 AND status = 'pending'
+        RETURNING id, author, quote, created_at, COALESCE(owner_id, ''), status, COALESCE(moderation_reason, ''), updated_at
+    `
+	var q models.Quote
+	err := s.conn(ctx).QueryRow(ctx, query, id, status, reason, moderator).Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt, &q.OwnerID, &q.Status, &q.ModerationReason, &q.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, s.notPending(ctx, id)
+	}
+	if err != nil {
+		logger.Errorf("Ошибка модерации цитаты: %v", err)
+		return nil, err
+	}
+	return &q, nil
+}
+
+// notPending объясняет, почему решение не применилось: цитаты нет или она уже проверена
+func (s *Storage) notPending(ctx context.Context, id int) error {
+	var exists bool
+	err := s.conn(ctx).QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM quotes WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		logger.Errorf("Ошибка проверки существования цитаты: %v", err)
+		return err
+	}
+	if !exists {
+		return domain.ErrNotFound
+	}
+	return domain.ErrNotPending
+}
@@ -0,0 +1,174 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"quote-service/internal/models"
+	"quote-service/pkg/logger"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EventsChannel - канал NOTIFY, в который после фиксации приходит номер нового события журнала
+const EventsChannel = "quote_events"
+
+// WithOutbox включает журнал событий: создание, изменение, одобрение и удаление цитаты записывают событие
+// в quote_events и отправляют NOTIFY в той же транзакции, что и само изменение.
+func WithOutbox() StorageOption {
+	return func(s *Storage) {
+		s.outbox = true
+	}
+}
+
+// recorded выполняет изменение fn в транзакции, чтобы изменение цитаты и связанные записи (теги, индекс слов)
+// сохранялись вместе. Если журнал включен, событие, которое вернула fn, записывается в той же транзакции;
+// nil означает, что изменение не затрагивает опубликованные цитаты.
+func (s *Storage) recorded(ctx context.Context, fn func(ctx context.Context) (*models.OutboxEvent, error)) error {
+	return s.InTx(ctx, func(ctx context.Context) error {
+		ev, err := fn(ctx)
+		if err != nil || ev == nil || !s.outbox {
+			return err
+		}
+		return s.appendEvent(ctx, ev)
+	})
+}
+
+// appendEvent пишет событие в журнал. Уведомление PostgreSQL доставит только после фиксации транзакции.
+func (s *Storage) appendEvent(ctx context.Context, ev *models.OutboxEvent) error {
+	payload, err := json.Marshal(ev.Quote)
+	if err != nil {
+		logger.Errorf("Ошибка кодирования события: %v", err)
+		return err
+	}
+	query := `
+        WITH ev AS (
+            INSERT INTO quote_events (type, quote_id, payload) VALUES ($1, $2, $3) RETURNING id, created_at
+        )
+        SELECT id, created_at, pg_notify($4, id::text) FROM ev
+    `
+	if err := s.conn(ctx).QueryRow(ctx, query, ev.Type, ev.Quote.ID, payload, EventsChannel).Scan(&ev.ID, &ev.CreatedAt, nil); err != nil {
+		logger.Errorf("Ошибка записи события в журнал: %v", err)
+		return err
+	}
+	return nil
+}
+
+// DispatchedEventID возвращает номер последнего разосланного события. Пока диспетчеры ничего
+// не сохраняли, раздача начинается с конца журнала.
+func (s *Storage) DispatchedEventID(ctx context.Context) (int64, error) {
+	query := `SELECT COALESCE((SELECT event_id FROM quote_events_cursor), (SELECT MAX(id) FROM quote_events), 0)`
+	var id int64
+	if err := s.conn(ctx).QueryRow(ctx, query).Scan(&id); err != nil {
+		logger.Errorf("Ошибка чтения журнала событий: %v", err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// SaveDispatchedEventID запоминает номер разосланного события. Номер только растет: экземпляры
+// сервиса раздают один и тот же журнал и сохраняют позицию независимо друг от друга.
+func (s *Storage) SaveDispatchedEventID(ctx context.Context, id int64) error {
+	query := `
+        INSERT INTO quote_events_cursor (event_id) VALUES ($1)
+        ON CONFLICT (id) DO UPDATE SET event_id = GREATEST(quote_events_cursor.event_id, EXCLUDED.event_id)
+    `
+	if _, err := s.conn(ctx).Exec(ctx, query, id); err != nil {
+		logger.Errorf("Ошибка сохранения позиции журнала событий: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) EventsAfter(ctx context.Context, afterID int64, limit int) ([]models.OutboxEvent, error) {
+	query := `SELECT id, type, payload, created_at FROM quote_events WHERE id > $1 ORDER BY id LIMIT $2`
+	rows, err := s.conn(ctx).Query(ctx, query, afterID, limit)
+	if err != nil {
+		logger.Errorf("Ошибка чтения журнала событий: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var ev models.OutboxEvent
+		var payload []byte
+		if err := rows.Scan(&ev.ID, &ev.Type, &payload, &ev.CreatedAt); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		if err := json.Unmarshal(payload, &ev.Quote); err != nil {
+			logger.Errorf("Ошибка декодирования события %d: %v", ev.ID, err)
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return events, nil
+}
+
+func (s *Storage) PurgeEvents(ctx context.Context, olderThan time.Duration) error {
+	query := `DELETE FROM quote_events WHERE created_at < CURRENT_TIMESTAMP - make_interval(secs => $1)`
+	if _, err := s.conn(ctx).Exec(ctx, query, olderThan.Seconds()); err != nil {
+		logger.Errorf("Ошибка очистки журнала событий: %v", err)
+		return err
+	}
+	return nil
+}
+
+// listenConn - соединение, на котором Listener слушает канал (*pgx.Conn)
+type listenConn interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	Close(ctx context.Context) error
+}
+
+// Listener ждет уведомлений канала на отдельном соединении пула. Соединение открывается
+// при первом вызове Wait и после ошибки открывается заново. Wait нельзя вызывать параллельно.
+type Listener struct {
+	connect func(ctx context.Context) (listenConn, error)
+	channel string
+	conn    listenConn
+}
+
+func NewListener(pool *pgxpool.Pool, channel string) *Listener {
+	return &Listener{
+		channel: channel,
+		connect: func(ctx context.Context) (listenConn, error) {
+			conn, err := pool.Acquire(ctx)
+			if err != nil {
+				return nil, err
+			}
+			// Соединение с LISTEN не возвращается в пул: его получил бы другой запрос
+			return conn.Hijack(), nil
+		},
+	}
+}
+
+// Wait возвращает nil по уведомлению, а также сразу после (пере)подключения:
+// уведомления, отправленные до LISTEN, не приходят, и журнал нужно перечитать.
+func (l *Listener) Wait(ctx context.Context) error {
+	if l.conn == nil {
+		conn, err := l.connect(ctx)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{l.channel}.Sanitize()); err != nil {
+			_ = conn.Close(context.WithoutCancel(ctx))
+			return err
+		}
+		l.conn = conn
+		return nil
+	}
+	if _, err := l.conn.WaitForNotification(ctx); err != nil {
+		_ = l.conn.Close(context.WithoutCancel(ctx))
+		l.conn = nil
+		return err
+	}
+	return nil
+}
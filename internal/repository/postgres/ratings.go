@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/pkg/logger"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func (s *Storage) Rate(ctx context.Context, quoteID int, userID string, rating int) error {
+	query := `
+        INSERT INTO quote_ratings (quote_id, user_id, rating) VALUES ($1, $2, $3)
+        ON CONFLICT (quote_id, user_id) DO UPDATE SET rating = EXCLUDED.rating, updated_at = CURRENT_TIMESTAMP
+    `
+	if _, err := s.conn(ctx).Exec(ctx, query, quoteID, userID, rating); err != nil {
+		if isForeignKeyViolation(err) {
+			return domain.ErrNotFound
+		}
+		logger.Errorf("Ошибка сохранения оценки: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) Like(ctx context.Context, quoteID int, userID string) error {
+	query := `INSERT INTO quote_likes (quote_id, user_id) VALUES ($1, $2) ON CONFLICT (quote_id, user_id) DO NOTHING`
+	if _, err := s.conn(ctx).Exec(ctx, query, quoteID, userID); err != nil {
+		if isForeignKeyViolation(err) {
+			return domain.ErrNotFound
+		}
+		logger.Errorf("Ошибка сохранения лайка: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) Unlike(ctx context.Context, quoteID int, userID string) error {
+	query := `DELETE FROM quote_likes WHERE quote_id = $1 AND user_id = $2`
+	if _, err := s.conn(ctx).Exec(ctx, query, quoteID, userID); err != nil {
+		logger.Errorf("Ошибка удаления лайка: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) Stats(ctx context.Context, ids []int) (map[int]models.RatingStats, error) {
+	query := `
+        SELECT q.id,
+            COALESCE((SELECT AVG(r.rating)::float8 FROM quote_ratings r WHERE r.quote_id = q.id), 0),
+            (SELECT COUNT(*) FROM quote_ratings r WHERE r.quote_id = q.id),
+            (SELECT COUNT(*) FROM quote_likes l WHERE l.quote_id = q.id)
+        FROM quotes q
+        WHERE q.id = ANY($1)
+    `
+	rows, err := s.conn(ctx).Query(ctx, query, ids)
+	if err != nil {
+		logger.Errorf("Ошибка получения статистики оценок: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[int]models.RatingStats, len(ids))
+	for rows.Next() {
+		var id int
+		var st models.RatingStats
+		if err := rows.Scan(&id, &st.AvgRating, &st.RatingsCount, &st.Likes); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		stats[id] = st
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (s *Storage) Top(ctx context.Context, since time.Time, limit int) ([]models.Quote, error) {
+	query := `
+        WITH r AS (
+            SELECT quote_id, AVG(rating)::float8 AS avg_rating, COUNT(*) AS ratings_count
+            FROM quote_ratings WHERE updated_at >= $1 GROUP BY quote_id
+        ), l AS (
+            SELECT quote_id, COUNT(*) AS likes
+            FROM quote_likes WHERE created_at >= $1 GROUP BY quote_id
+        )
+        SELECT q.id, q.author, q.quote, q.created_at, COALESCE(q.owner_id, ''), q.status, COALESCE(q.moderation_reason, ''), q.updated_at,
+            COALESCE(r.avg_rating, 0) AS avg_rating,
+            COALESCE(r.ratings_count, 0) AS ratings_count,
+            COALESCE(l.likes, 0) AS likes
+        FROM quotes q
+        LEFT JOIN r ON r.quote_id = q.id
+        LEFT JOIN l ON l.quote_id = q.id
+        WHERE (r.quote_id IS NOT NULL OR l.quote_id IS NOT NULL) AND q.status = 'approved'
+        ORDER BY avg_rating DESC, ratings_count DESC, likes DESC, q.id
+        LIMIT $2
+    `
+	rows, err := s.conn(ctx).Query(ctx, query, since, limit)
+	if err != nil {
+		logger.Errorf("Ошибка получения лучших цитат: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotes []models.Quote
+	for rows.Next() {
+		var q models.Quote
+		if err := rows.Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt, &q.OwnerID, &q.Status, &q.ModerationReason, &q.UpdatedAt, &q.AvgRating, &q.RatingsCount, &q.Likes); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		quotes = append(quotes, q)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return quotes, nil
+}
+
+// isForeignKeyViolation сообщает, что запись ссылается на несуществующую цитату
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
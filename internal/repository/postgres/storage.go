@@ -6,6 +6,7 @@ import (
 	"quote-service/internal/domain"
 	"quote-service/internal/models"
 	"quote-service/pkg/logger"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -18,14 +19,33 @@ type DBConn interface {
 }
 
 type Storage struct {
-	db DBConn
+	db     DBConn
+	outbox bool
 }
 
-func NewStorage(db DBConn) *Storage {
-	return &Storage{db: db}
+type StorageOption func(*Storage)
+
+func NewStorage(db DBConn, opts ...StorageOption) *Storage {
+	s := &Storage{db: db}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Storage) Create(ctx context.Context, quote *models.Quote) error {
+	return s.recorded(ctx, func(ctx context.Context) (*models.OutboxEvent, error) {
+		if err := s.insertQuote(ctx, quote); err != nil {
+			return nil, err
+		}
+		if quote.Status != models.StatusApproved {
+			return nil, nil
+		}
+		return &models.OutboxEvent{Type: models.EventCreated, Quote: *quote}, nil
+	})
+}
+
+func (s *Storage) insertQuote(ctx context.Context, quote *models.Quote) error {
 	var newID int
 	query := `
         WITH RECURSIVE ids AS (
@@ -35,7 +55,7 @@ func (s *Storage) Create(ctx context.Context, quote *models.Quote) error {
         FROM ids
         WHERE NOT EXISTS (SELECT 1 FROM quotes WHERE quotes.id = ids.id)
     `
-	err := s.db.QueryRow(ctx, query).Scan(&newID)
+	err := s.conn(ctx).QueryRow(ctx, query).Scan(&newID)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			newID = 1
@@ -45,8 +65,11 @@ func (s *Storage) Create(ctx context.Context, quote *models.Quote) error {
 		}
 	}
 
-	query = `INSERT INTO quotes (id, author, quote) VALUES ($1, $2, $3) RETURNING created_at`
-	err = s.db.QueryRow(ctx, query, newID, quote.Author, quote.Quote).Scan(&quote.CreatedAt)
+	if quote.Status == "" {
+		quote.Status = models.StatusApproved
+	}
+	query = `INSERT INTO quotes (id, author, quote, owner_id, status) VALUES ($1, $2, $3, NULLIF($4, ''), $5) RETURNING created_at`
+	err = s.conn(ctx).QueryRow(ctx, query, newID, quote.Author, quote.Quote, quote.OwnerID, quote.Status).Scan(&quote.CreatedAt)
 	if err != nil {
 		if pgErr, ok := err.(*pgconn.PgError); ok && pgErr.Code == "23505" {
 			logger.Errorf("Конфликт ID: %d уже занят", newID)
@@ -56,12 +79,16 @@ func (s *Storage) Create(ctx context.Context, quote *models.Quote) error {
 		return err
 	}
 	quote.ID = newID
-	return nil
+	if err := s.saveTags(ctx, newID, quote.Tags); err != nil {
+		return err
+	}
+	return s.indexTerms(ctx, newID, quote.Quote)
 }
 
-func (s *Storage) GetAll(ctx context.Context) ([]models.Quote, error) {
-	query := `SELECT id, author, quote, created_at FROM quotes ORDER BY id`
-	rows, err := s.db.Query(ctx, query)
+// GetAll возвращает цитаты с указанными статусами модерации, по умолчанию только одобренные
+func (s *Storage) GetAll(ctx context.Context, statuses ...string) ([]models.Quote, error) {
+	query := `SELECT id, author, quote, created_at, COALESCE(owner_id, ''), status, COALESCE(moderation_reason, ''), updated_at FROM quotes WHERE status = ANY($1) ORDER BY id`
+	rows, err := s.conn(ctx).Query(ctx, query, visibleStatuses(statuses))
 	if err != nil {
 		logger.Errorf("Ошибка получения всех цитат: %v", err)
 		return nil, err
@@ -71,7 +98,7 @@ func (s *Storage) GetAll(ctx context.Context) ([]models.Quote, error) {
 	var quotes []models.Quote
 	for rows.Next() {
 		var q models.Quote
-		if err := rows.Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt); err != nil {
+		if err := rows.Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt, &q.OwnerID, &q.Status, &q.ModerationReason, &q.UpdatedAt); err != nil {
 			logger.Errorf("Ошибка сканирования строки: %v", err)
 			return nil, err
 		}
@@ -84,10 +111,10 @@ func (s *Storage) GetAll(ctx context.Context) ([]models.Quote, error) {
 	return quotes, nil
 }
 
-func (s *Storage) GetRandom(ctx context.Context) (*models.Quote, error) {
-	query := `SELECT id, author, quote, created_at FROM quotes ORDER BY RANDOM() LIMIT 1`
+func (s *Storage) GetRandom(ctx context.Context, statuses ...string) (*models.Quote, error) {
+	query := `SELECT id, author, quote, created_at, COALESCE(owner_id, ''), status, COALESCE(moderation_reason, ''), updated_at FROM quotes WHERE status = ANY($1) ORDER BY RANDOM() LIMIT 1`
 	var q models.Quote
-	err := s.db.QueryRow(ctx, query).Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt)
+	err := s.conn(ctx).QueryRow(ctx, query, visibleStatuses(statuses)).Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt, &q.OwnerID, &q.Status, &q.ModerationReason, &q.UpdatedAt)
 	if err == pgx.ErrNoRows {
 		return nil, domain.ErrNotFound
 	}
@@ -98,11 +125,40 @@ func (s *Storage) GetRandom(ctx context.Context) (*models.Quote, error) {
 	return &q, nil
 }
 
-func (s *Storage) GetByAuthor(ctx context.Context, author string) ([]models.Quote, error) {
-	query := `SELECT id, author, quote, created_at FROM quotes WHERE author = $1 ORDER BY id`
-	rows, err := s.db.Query(ctx, query, author)
+func (s *Storage) GetByID(ctx context.Context, id int) (*models.Quote, error) {
+	query := `SELECT id, author, quote, created_at, COALESCE(owner_id, ''), status, COALESCE(moderation_reason, ''), updated_at FROM quotes WHERE id = $1`
+	var q models.Quote
+	err := s.conn(ctx).QueryRow(ctx, query, id).Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt, &q.OwnerID, &q.Status, &q.ModerationReason, &q.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
 	if err != nil {
-		logger.Errorf("Ошибка получения цитат по автору: %v", err)
+		logger.Errorf("Ошибка получения цитаты по ID: %v", err)
+		return nil, err
+	}
+	return &q, nil
+}
+
+// GetDaily выбирает цитату дня: порядок зависит только от даты, поэтому в течение дня выдается одна и та же цитата
+func (s *Storage) GetDaily(ctx context.Context, day time.Time) (*models.Quote, error) {
+	query := `SELECT id, author, quote, created_at, COALESCE(owner_id, ''), status, COALESCE(moderation_reason, ''), updated_at FROM quotes WHERE status = 'approved' ORDER BY md5(id::text || $1), id LIMIT 1`
+	var q models.Quote
+	err := s.conn(ctx).QueryRow(ctx, query, day.Format("2006-01-02")).Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt, &q.OwnerID, &q.Status, &q.ModerationReason, &q.UpdatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, domain.ErrNotFound
+	}
+	if err != nil {
+		logger.Errorf("Ошибка получения цитаты дня: %v", err)
+		return nil, err
+	}
+	return &q, nil
+}
+
+func (s *Storage) GetByIDs(ctx context.Context, ids []int) ([]models.Quote, error) {
+	query := `SELECT id, author, quote, created_at, COALESCE(owner_id, ''), status, COALESCE(moderation_reason, ''), updated_at FROM quotes WHERE id = ANY($1) AND status = 'approved' ORDER BY id`
+	rows, err := s.conn(ctx).Query(ctx, query, ids)
+	if err != nil {
+		logger.Errorf("Ошибка получения цитат по ID: %v", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -110,7 +166,7 @@ func (s *Storage) GetByAuthor(ctx context.Context, author string) ([]models.Quot
 	var quotes []models.Quote
 	for rows.Next() {
 		var q models.Quote
-		if err := rows.Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt); err != nil {
+		if err := rows.Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt, &q.OwnerID, &q.Status, &q.ModerationReason, &q.UpdatedAt); err != nil {
 			logger.Errorf("Ошибка сканирования строки: %v", err)
 			return nil, err
 		}
@@ -123,26 +179,98 @@ func (s *Storage) GetByAuthor(ctx context.Context, author string) ([]models.Quot
 	return quotes, nil
 }
 
-func (s *Storage) Delete(ctx context.Context, id int) error {
-	query := `DELETE FROM quotes WHERE id = $1`
-	result, err := s.db.Exec(ctx, query, id)
+func (s *Storage) GetByAuthor(ctx context.Context, author string, statuses ...string) ([]models.Quote, error) {
+	query := `SELECT id, author, quote, created_at, COALESCE(owner_id, ''), status, COALESCE(moderation_reason, ''), updated_at FROM quotes WHERE author = $1 AND status = ANY($2) ORDER BY id`
+	rows, err := s.conn(ctx).Query(ctx, query, author, visibleStatuses(statuses))
 	if err != nil {
-		logger.Errorf("Ошибка удаления цитаты: %v", err)
-		return err
+		logger.Errorf("Ошибка получения цитат по автору: %v", err)
+		return nil, err
 	}
-	if result.RowsAffected() == 0 {
-		return domain.ErrNotFound
+	defer rows.Close()
+
+	var quotes []models.Quote
+	for rows.Next() {
+		var q models.Quote
+		if err := rows.Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt, &q.OwnerID, &q.Status, &q.ModerationReason, &q.UpdatedAt); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		quotes = append(quotes, q)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
 	}
-	return nil
+	return quotes, nil
+}
+
+// Update меняет автора и текст цитаты. Непустой quote.Status задает новый статус модерации
+// (правка автором возвращает цитату на проверку), пустой оставляет прежний. Об изменении
+// опубликованной цитаты в журнал пишется событие updated, по которому кэши других экземпляров
+// сбрасывают ее списки, а о снятии с публикации - deleted.
+func (s *Storage) Update(ctx context.Context, quote *models.Quote) error {
+	return s.recorded(ctx, func(ctx context.Context) (*models.OutboxEvent, error) {
+		query := `
+            UPDATE quotes q
+            SET author = $2, quote = $3, updated_at = CURRENT_TIMESTAMP,
+                status = COALESCE(NULLIF($4, ''), q.status),
+                moderation_reason = CASE WHEN NULLIF($4, '') IS NULL OR $4 = q.status THEN q.moderation_reason END
+            FROM (SELECT id, status FROM quotes WHERE id = $1 FOR UPDATE) old
+            WHERE q.id = old.id
+            RETURNING q.created_at, COALESCE(q.owner_id, ''), q.status, COALESCE(q.moderation_reason, ''), q.updated_at, old.status
+        `
+		var previous string
+		err := s.conn(ctx).QueryRow(ctx, query, quote.ID, quote.Author, quote.Quote, quote.Status).Scan(&quote.CreatedAt, &quote.OwnerID, &quote.Status, &quote.ModerationReason, &quote.UpdatedAt, &previous)
+		if err == pgx.ErrNoRows {
+			return nil, domain.ErrNotFound
+		}
+		if err != nil {
+			logger.Errorf("Ошибка обновления цитаты: %v", err)
+			return nil, err
+		}
+		if err := s.reindexTerms(ctx, quote.ID, quote.Quote); err != nil {
+			return nil, err
+		}
+		switch {
+		case quote.Status == models.StatusApproved:
+			return &models.OutboxEvent{Type: models.EventUpdated, Quote: *quote}, nil
+		case previous == models.StatusApproved:
+			return &models.OutboxEvent{Type: models.EventDeleted, Quote: models.Quote{ID: quote.ID}}, nil
+		}
+		return nil, nil
+	})
+}
+
+func (s *Storage) Delete(ctx context.Context, id int) error {
+	return s.recorded(ctx, func(ctx context.Context) (*models.OutboxEvent, error) {
+		query := `DELETE FROM quotes WHERE id = $1`
+		result, err := s.conn(ctx).Exec(ctx, query, id)
+		if err != nil {
+			logger.Errorf("Ошибка удаления цитаты: %v", err)
+			return nil, err
+		}
+		if result.RowsAffected() == 0 {
+			return nil, domain.ErrNotFound
+		}
+		return &models.OutboxEvent{Type: models.EventDeleted, Quote: models.Quote{ID: id}}, nil
+	})
 }
 
 func (s *Storage) Exists(ctx context.Context, author, quote string) (bool, error) {
 	var exists bool
 	query := `SELECT EXISTS(SELECT 1 FROM quotes WHERE author = $1 AND quote = $2)`
-	err := s.db.QueryRow(ctx, query, author, quote).Scan(&exists)
+	err := s.conn(ctx).QueryRow(ctx, query, author, quote).Scan(&exists)
 	if err != nil {
 		logger.Errorf("Ошибка проверки существования цитаты: %v", err)
 		return false, err
 	}
 	return exists, nil
 }
+
+// visibleStatuses подставляет статус по умолчанию: читателям видны только одобренные цитаты
+func visibleStatuses(statuses []string) []string {
+	if len(statuses) == 0 {
+		return []string{models.StatusApproved}
+	}
+	return statuses
+}
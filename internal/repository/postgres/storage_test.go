@@ -2,7 +2,10 @@ package postgres
 
 import (
 	"context"
+	"errors"
+	"quote-service/internal/domain"
 	"quote-service/internal/models"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -17,6 +20,8 @@ import (
 // MockConn реализует DBConn для мока
 type MockConn struct {
 	mock.Mock
+	// Txs - открытые через Begin транзакции
+	Txs []*MockTx
 }
 
 type MockCommandTag struct {
@@ -56,6 +61,49 @@ func (m *MockConn) Exec(ctx context.Context, sql string, args ...interface{}) (p
 	return argsCalled.Get(0).(pgconn.CommandTag), argsCalled.Error(1)
 }
 
+// Begin открывает MockTx: запросы в транзакции идут в тот же MockConn
+func (m *MockConn) Begin(ctx context.Context) (pgx.Tx, error) {
+	tx := &MockTx{conn: m}
+	m.Txs = append(m.Txs, tx)
+	return tx, nil
+}
+
+// MockTx для мока pgx.Tx. Запоминает, чем закончилась транзакция.
+type MockTx struct {
+	pgx.Tx
+	conn       *MockConn
+	Committed  bool
+	RolledBack bool
+}
+
+func (t *MockTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	return t.conn.Begin(ctx)
+}
+
+func (t *MockTx) Commit(ctx context.Context) error {
+	t.Committed = true
+	return nil
+}
+
+func (t *MockTx) Rollback(ctx context.Context) error {
+	if !t.Committed {
+		t.RolledBack = true
+	}
+	return nil
+}
+
+func (t *MockTx) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return t.conn.QueryRow(ctx, sql, args...)
+}
+
+func (t *MockTx) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return t.conn.Query(ctx, sql, args...)
+}
+
+func (t *MockTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return t.conn.Exec(ctx, sql, args...)
+}
+
 // MockRow для мока pgx.Row
 type MockRow struct {
 	mock.Mock
@@ -138,7 +186,12 @@ func TestStorage_Create(t *testing.T) {
 			createdAt := args.Get(0).(*time.Time)
 			*createdAt = time.Now()
 		}).Return(nil).Once()
-		mockConn.On("QueryRow", mock.Anything, "INSERT INTO quotes (id, author, quote) VALUES ($1, $2, $3) RETURNING created_at", []interface{}{1, quote.Author, quote.Quote}).Return(mockRow).Once()
+		mockConn.On("QueryRow", mock.Anything, "INSERT INTO quotes (id, author, quote, owner_id, status) VALUES ($1, $2, $3, NULLIF($4, ''), $5) RETURNING created_at", []interface{}{1, quote.Author, quote.Quote, quote.OwnerID, models.StatusApproved}).Return(mockRow).Once()
+
+		// Мок для индексации слов цитаты
+		mockConn.On("Exec", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO quote_terms")
+		}), mock.Anything).Return(pgconn.NewCommandTag("INSERT 0 2"), nil).Once()
 
 		err := storage.Create(context.Background(), quote)
 		assert.NoError(t, err)
@@ -155,11 +208,33 @@ func TestStorage_Create(t *testing.T) {
 
 		// Мок для ошибки при вставке
 		mockRow.On("Scan", mock.Anything).Return(pgx.ErrNoRows).Once()
-		mockConn.On("QueryRow", mock.Anything, "INSERT INTO quotes (id, author, quote) VALUES ($1, $2, $3) RETURNING created_at", []interface{}{1, quote.Author, quote.Quote}).Return(mockRow).Once()
+		mockConn.On("QueryRow", mock.Anything, "INSERT INTO quotes (id, author, quote, owner_id, status) VALUES ($1, $2, $3, NULLIF($4, ''), $5) RETURNING created_at", []interface{}{1, quote.Author, quote.Quote, quote.OwnerID, models.StatusApproved}).Return(mockRow).Once()
 
 		err := storage.Create(context.Background(), quote)
 		assert.Error(t, err)
 	})
+
+	t.Run("index error rolls back the insert", func(t *testing.T) {
+		mockRow.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int) = 2
+		}).Return(nil).Once()
+		mockConn.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "WITH RECURSIVE ids")
+		}), []interface{}(nil)).Return(mockRow).Once()
+		mockRow.On("Scan", mock.Anything).Return(nil).Once()
+		mockConn.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.HasPrefix(sql, "INSERT INTO quotes")
+		}), mock.Anything).Return(mockRow).Once()
+		mockConn.On("Exec", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO quote_terms")
+		}), mock.Anything).Return(pgconn.CommandTag{}, errors.New("index failed")).Once()
+
+		err := storage.Create(context.Background(), &models.Quote{Author: "Seneca", Quote: "Luck is preparation"})
+		assert.Error(t, err)
+		tx := mockConn.Txs[len(mockConn.Txs)-1]
+		assert.True(t, tx.RolledBack)
+		assert.False(t, tx.Committed)
+	})
 }
 
 func TestStorage_GetAll(t *testing.T) {
@@ -174,7 +249,7 @@ func TestStorage_GetAll(t *testing.T) {
 	t.Run("successful get all", func(t *testing.T) {
 		t.Log("Настройка мока для GetAll")
 		mockRows.On("Next").Return(true).Once()
-		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
 			t.Log("Scan вызван")
 			id := args.Get(0).(*int)
 			author := args.Get(1).(*string)
@@ -188,7 +263,7 @@ func TestStorage_GetAll(t *testing.T) {
 		mockRows.On("Next").Return(false).Once()
 		mockRows.On("Close").Return().Once()
 		mockRows.On("Err").Return(nil).Once()
-		mockConn.On("Query", mock.Anything, "SELECT id, author, quote, created_at FROM quotes ORDER BY id", []interface{}(nil)).Return(mockRows, nil).Once()
+		mockConn.On("Query", mock.Anything, "SELECT id, author, quote, created_at, COALESCE(owner_id, ''), status, COALESCE(moderation_reason, ''), updated_at FROM quotes WHERE status = ANY($1) ORDER BY id", []interface{}{[]string{models.StatusApproved}}).Return(mockRows, nil).Once()
 
 		t.Log("Вызов GetAll")
 		result, err := storage.GetAll(context.Background())
@@ -205,7 +280,7 @@ func TestStorage_GetAll(t *testing.T) {
 		mockRows.On("Next").Return(false).Once()
 		mockRows.On("Close").Return().Once()
 		mockRows.On("Err").Return(nil).Once()
-		mockConn.On("Query", mock.Anything, "SELECT id, author, quote, created_at FROM quotes ORDER BY id", []interface{}(nil)).Return(mockRows, nil).Once()
+		mockConn.On("Query", mock.Anything, "SELECT id, author, quote, created_at, COALESCE(owner_id, ''), status, COALESCE(moderation_reason, ''), updated_at FROM quotes WHERE status = ANY($1) ORDER BY id", []interface{}{[]string{models.StatusApproved}}).Return(mockRows, nil).Once()
 
 		result, err := storage.GetAll(context.Background())
 		assert.NoError(t, err)
@@ -221,7 +296,7 @@ func TestStorage_GetRandom(t *testing.T) {
 	quote := &models.Quote{ID: 1, Author: "Confucius", Quote: "Life is simple", CreatedAt: time.Now()}
 
 	t.Run("successful get random", func(t *testing.T) {
-		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mockRow.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
 			id := args.Get(0).(*int)
 			author := args.Get(1).(*string)
 			quoteText := args.Get(2).(*string)
@@ -231,7 +306,7 @@ func TestStorage_GetRandom(t *testing.T) {
 			*quoteText = quote.Quote
 			*createdAt = quote.CreatedAt
 		}).Return(nil).Once()
-		mockConn.On("QueryRow", mock.Anything, "SELECT id, author, quote, created_at FROM quotes ORDER BY RANDOM() LIMIT 1", []interface{}(nil)).Return(mockRow).Once()
+		mockConn.On("QueryRow", mock.Anything, "SELECT id, author, quote, created_at, COALESCE(owner_id, ''), status, COALESCE(moderation_reason, ''), updated_at FROM quotes WHERE status = ANY($1) ORDER BY RANDOM() LIMIT 1", []interface{}{[]string{models.StatusApproved}}).Return(mockRow).Once()
 
 		result, err := storage.GetRandom(context.Background())
 		assert.NoError(t, err)
@@ -253,7 +328,7 @@ func TestStorage_GetByAuthor(t *testing.T) {
 	t.Run("successful get by author", func(t *testing.T) {
 		t.Log("Настройка мока для GetByAuthor")
 		mockRows.On("Next").Return(true).Once()
-		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
 			t.Log("Scan вызван")
 			id := args.Get(0).(*int)
 			author := args.Get(1).(*string)
@@ -267,7 +342,7 @@ func TestStorage_GetByAuthor(t *testing.T) {
 		mockRows.On("Next").Return(false).Once()
 		mockRows.On("Close").Return().Once()
 		mockRows.On("Err").Return(nil).Once()
-		mockConn.On("Query", mock.Anything, "SELECT id, author, quote, created_at FROM quotes WHERE author = $1 ORDER BY id", []interface{}{"Confucius"}).Return(mockRows, nil).Once()
+		mockConn.On("Query", mock.Anything, "SELECT id, author, quote, created_at, COALESCE(owner_id, ''), status, COALESCE(moderation_reason, ''), updated_at FROM quotes WHERE author = $1 AND status = ANY($2) ORDER BY id", []interface{}{"Confucius", []string{models.StatusApproved}}).Return(mockRows, nil).Once()
 
 		t.Log("Вызов GetByAuthor")
 		result, err := storage.GetByAuthor(context.Background(), "Confucius")
@@ -298,3 +373,719 @@ func TestStorage_Exists(t *testing.T) {
 		assert.True(t, exists)
 	})
 }
+
+func TestStorage_TermCandidates(t *testing.T) {
+	mockConn := new(MockConn)
+	mockRows := new(MockRows)
+	storage := NewStorage(mockConn)
+
+	t.Run("candidates grouped by term set", func(t *testing.T) {
+		sql := mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "PARTITION BY i.idx") && strings.Contains(sql, "q.status = ANY($3)")
+		})
+		args := []interface{}{[]int{0, 0, 1}, []string{"life", "simple", "luck"}, []string{models.StatusPending, models.StatusApproved}, 50}
+		mockConn.On("Query", mock.Anything, sql, args).Return(mockRows, nil).Once()
+		mockRows.On("Next").Return(true).Twice()
+		for _, row := range []struct {
+			set, id int
+		}{{1, 4}, {0, 3}} {
+			row := row
+			mockRows.On("Scan", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				*args.Get(0).(*int) = row.set
+				*args.Get(1).(*int) = row.id
+			}).Return(nil).Once()
+		}
+		mockRows.On("Next").Return(false).Once()
+		mockRows.On("Close").Return().Once()
+		mockRows.On("Err").Return(nil).Once()
+
+		candidates, err := storage.TermCandidates(context.Background(), [][]string{{"life", "simple"}, {"luck"}}, 50, models.StatusPending, models.StatusApproved)
+		assert.NoError(t, err)
+		if assert.Len(t, candidates, 2) {
+			assert.Equal(t, 3, candidates[0][0].ID)
+			assert.Equal(t, 4, candidates[1][0].ID)
+		}
+	})
+
+	t.Run("no terms", func(t *testing.T) {
+		candidates, err := storage.TermCandidates(context.Background(), [][]string{{}}, 50)
+		assert.NoError(t, err)
+		assert.Equal(t, [][]models.Quote{nil}, candidates)
+		mockConn.AssertNumberOfCalls(t, "Query", 1)
+	})
+}
+
+func TestStorage_BackfillTerms(t *testing.T) {
+	mockConn := new(MockConn)
+	mockRows := new(MockRows)
+	emptyRows := new(MockRows)
+	storage := NewStorage(mockConn)
+
+	selectSQL := mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "SELECT id, quote FROM quotes q")
+	})
+	mockConn.On("Query", mock.Anything, selectSQL, []interface{}{0, false, termBackfillBatch}).Return(mockRows, nil).Once()
+	mockRows.On("Next").Return(true).Once()
+	mockRows.On("Scan", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*int) = 7
+		*args.Get(1).(*string) = "The unexamined life is not worth living"
+	}).Return(nil).Once()
+	mockRows.On("Next").Return(false).Once()
+	mockRows.On("Close").Return()
+	mockRows.On("Err").Return(nil).Once()
+
+	mockConn.On("Exec", mock.Anything, "DELETE FROM quote_terms WHERE quote_id = $1", []interface{}{7}).Return(pgconn.NewCommandTag("DELETE 0"), nil).Once()
+	mockConn.On("Exec", mock.Anything, mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "INSERT INTO quote_terms")
+	}), mock.MatchedBy(func(args []interface{}) bool {
+		// Тот же разбор, что и при создании: стоп-слова "the", "is" не индексируются
+		words := args[1].([]string)
+		return args[0] == 7 && len(words) == 5 && !slices.Contains(words, "the") && !slices.Contains(words, "is")
+	})).Return(pgconn.NewCommandTag("INSERT 0 5"), nil).Once()
+
+	mockConn.On("Query", mock.Anything, selectSQL, []interface{}{7, false, termBackfillBatch}).Return(emptyRows, nil).Once()
+	emptyRows.On("Next").Return(false).Once()
+	emptyRows.On("Close").Return()
+	emptyRows.On("Err").Return(nil).Once()
+
+	n, err := storage.BackfillTerms(context.Background(), false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	mockConn.AssertExpectations(t)
+	assert.True(t, mockConn.Txs[0].Committed)
+}
+
+func TestStorage_Update(t *testing.T) {
+	mockConn := new(MockConn)
+	mockRow := new(MockRow)
+	eventRow := new(MockRow)
+	storage := NewStorage(mockConn, WithOutbox())
+
+	updateSQL := mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "UPDATE quotes q") && strings.Contains(sql, "status = COALESCE(NULLIF($4, ''), q.status)") &&
+			strings.Contains(sql, "FOR UPDATE")
+	})
+	expectUpdate := func(status, previous string) {
+		mockConn.On("QueryRow", mock.Anything, updateSQL, []interface{}{3, "Seneca", "Luck is preparation", status}).Return(mockRow).Once()
+		mockRow.On("Scan", anyArgs(6)...).Run(func(args mock.Arguments) {
+			if status == "" {
+				status = previous
+			}
+			*args.Get(2).(*string) = status
+			*args.Get(5).(*string) = previous
+		}).Return(nil).Once()
+		mockConn.On("Exec", mock.Anything, "DELETE FROM quote_terms WHERE quote_id = $1", []interface{}{3}).Return(pgconn.NewCommandTag("DELETE 2"), nil).Once()
+		mockConn.On("Exec", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO quote_terms")
+		}), mock.Anything).Return(pgconn.NewCommandTag("INSERT 0 2"), nil).Once()
+	}
+	expectEvent := func(eventType string) {
+		mockConn.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO quote_events")
+		}), mock.MatchedBy(func(args []interface{}) bool {
+			return args[0] == eventType && args[1] == 3 && args[3] == EventsChannel
+		})).Return(eventRow).Once()
+		eventRow.On("Scan", anyArgs(3)...).Return(nil).Once()
+	}
+	newQuote := func(status string) *models.Quote {
+		return &models.Quote{ID: 3, Author: "Seneca", Quote: "Luck is preparation", Status: status}
+	}
+
+	t.Run("published quote records an event", func(t *testing.T) {
+		expectUpdate("", models.StatusApproved)
+		expectEvent(models.EventUpdated)
+
+		quote := newQuote("")
+		assert.NoError(t, storage.Update(context.Background(), quote))
+		assert.Equal(t, models.StatusApproved, quote.Status)
+		assert.True(t, mockConn.Txs[len(mockConn.Txs)-1].Committed)
+	})
+
+	t.Run("edit sent back to review unpublishes the quote", func(t *testing.T) {
+		expectUpdate(models.StatusPending, models.StatusApproved)
+		expectEvent(models.EventDeleted)
+
+		quote := newQuote(models.StatusPending)
+		assert.NoError(t, storage.Update(context.Background(), quote))
+		assert.Equal(t, models.StatusPending, quote.Status)
+	})
+
+	t.Run("pending quote records nothing", func(t *testing.T) {
+		expectUpdate(models.StatusPending, models.StatusPending)
+		assert.NoError(t, storage.Update(context.Background(), newQuote(models.StatusPending)))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockConn.On("QueryRow", mock.Anything, updateSQL, mock.Anything).Return(mockRow).Once()
+		mockRow.On("Scan", anyArgs(6)...).Return(pgx.ErrNoRows).Once()
+
+		err := storage.Update(context.Background(), &models.Quote{ID: 404, Author: "Seneca", Quote: "Luck"})
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		assert.True(t, mockConn.Txs[len(mockConn.Txs)-1].RolledBack)
+	})
+
+	mockConn.AssertExpectations(t)
+	eventRow.AssertExpectations(t)
+}
+
+func TestStorage_Moderate(t *testing.T) {
+	mockConn := new(MockConn)
+	mockRow := new(MockRow)
+	existsRow := new(MockRow)
+	storage := NewStorage(mockConn)
+
+	moderateSQL := mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "UPDATE quotes") && strings.Contains(sql, "WHERE id = $1 AND status = 'pending'")
+	})
+	existsSQL := "SELECT EXISTS(SELECT 1 FROM quotes WHERE id = $1)"
+
+	t.Run("pending quote", func(t *testing.T) {
+		mockConn.On("QueryRow", mock.Anything, moderateSQL, []interface{}{3, models.StatusRejected, "not a quote", "key:2"}).Return(mockRow).Once()
+		mockRow.On("Scan", anyArgs(8)...).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int) = 3
+			*args.Get(5).(*string) = models.StatusRejected
+		}).Return(nil).Once()
+
+		quote, err := storage.Moderate(context.Background(), 3, models.StatusRejected, "not a quote", "key:2")
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatusRejected, quote.Status)
+	})
+
+	t.Run("already moderated", func(t *testing.T) {
+		mockConn.On("QueryRow", mock.Anything, moderateSQL, mock.Anything).Return(mockRow).Once()
+		mockRow.On("Scan", anyArgs(8)...).Return(pgx.ErrNoRows).Once()
+		mockConn.On("QueryRow", mock.Anything, existsSQL, []interface{}{3}).Return(existsRow).Once()
+		existsRow.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*bool) = true
+		}).Return(nil).Once()
+
+		_, err := storage.Moderate(context.Background(), 3, models.StatusApproved, "", "key:2")
+		assert.ErrorIs(t, err, domain.ErrNotPending)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockConn.On("QueryRow", mock.Anything, moderateSQL, mock.Anything).Return(mockRow).Once()
+		mockRow.On("Scan", anyArgs(8)...).Return(pgx.ErrNoRows).Once()
+		mockConn.On("QueryRow", mock.Anything, existsSQL, []interface{}{404}).Return(existsRow).Once()
+		existsRow.On("Scan", mock.Anything).Return(nil).Once()
+
+		_, err := storage.Moderate(context.Background(), 404, models.StatusApproved, "", "key:2")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	mockConn.AssertExpectations(t)
+	mockRow.AssertExpectations(t)
+	existsRow.AssertExpectations(t)
+}
+
+// anyArgs - n аргументов mock.Anything для Scan
+func anyArgs(n int) []interface{} {
+	args := make([]interface{}, n)
+	for i := range args {
+		args[i] = mock.Anything
+	}
+	return args
+}
+
+// expectNoRows настраивает пустой результат Query
+func expectNoRows(rows *MockRows) {
+	rows.On("Next").Return(false).Once()
+	rows.On("Close").Return().Once()
+	rows.On("Err").Return(nil).Once()
+}
+
+func TestStorage_Ratings(t *testing.T) {
+	mockConn := new(MockConn)
+	mockRows := new(MockRows)
+	storage := NewStorage(mockConn)
+	ctx := context.Background()
+
+	t.Run("rate replaces previous rating", func(t *testing.T) {
+		sql := mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO quote_ratings") && strings.Contains(sql, "DO UPDATE SET rating = EXCLUDED.rating")
+		})
+		mockConn.On("Exec", mock.Anything, sql, []interface{}{1, "user-1", 5}).Return(pgconn.NewCommandTag("INSERT 0 1"), nil).Once()
+		assert.NoError(t, storage.Rate(ctx, 1, "user-1", 5))
+	})
+
+	t.Run("rate unknown quote", func(t *testing.T) {
+		mockConn.On("Exec", mock.Anything, mock.Anything, []interface{}{404, "user-1", 5}).Return(pgconn.CommandTag{}, &pgconn.PgError{Code: "23503"}).Once()
+		assert.ErrorIs(t, storage.Rate(ctx, 404, "user-1", 5), domain.ErrNotFound)
+	})
+
+	t.Run("like is idempotent", func(t *testing.T) {
+		sql := mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO quote_likes") && strings.Contains(sql, "DO NOTHING")
+		})
+		mockConn.On("Exec", mock.Anything, sql, []interface{}{1, "user-1"}).Return(pgconn.NewCommandTag("INSERT 0 0"), nil).Once()
+		assert.NoError(t, storage.Like(ctx, 1, "user-1"))
+	})
+
+	t.Run("unlike", func(t *testing.T) {
+		mockConn.On("Exec", mock.Anything, "DELETE FROM quote_likes WHERE quote_id = $1 AND user_id = $2", []interface{}{1, "user-1"}).Return(pgconn.NewCommandTag("DELETE 1"), nil).Once()
+		assert.NoError(t, storage.Unlike(ctx, 1, "user-1"))
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		mockConn.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "FROM quote_ratings") && strings.Contains(sql, "q.id = ANY($1)")
+		}), []interface{}{[]int{1, 2}}).Return(mockRows, nil).Once()
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", anyArgs(4)...).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int) = 1
+			*args.Get(1).(*float64) = 4.5
+			*args.Get(2).(*int) = 2
+			*args.Get(3).(*int) = 3
+		}).Return(nil).Once()
+		expectNoRows(mockRows)
+
+		stats, err := storage.Stats(ctx, []int{1, 2})
+		assert.NoError(t, err)
+		assert.Equal(t, map[int]models.RatingStats{1: {AvgRating: 4.5, RatingsCount: 2, Likes: 3}}, stats)
+	})
+
+	t.Run("top counts only approved quotes in period", func(t *testing.T) {
+		since := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+		mockConn.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "updated_at >= $1") && strings.Contains(sql, "q.status = 'approved'")
+		}), []interface{}{since, 10}).Return(mockRows, nil).Once()
+		expectNoRows(mockRows)
+
+		quotes, err := storage.Top(ctx, since, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, quotes)
+	})
+
+	mockConn.AssertExpectations(t)
+	mockRows.AssertExpectations(t)
+}
+
+func TestStorage_Views(t *testing.T) {
+	mockConn := new(MockConn)
+	mockRows := new(MockRows)
+	storage := NewStorage(mockConn)
+	ctx := context.Background()
+
+	t.Run("nothing to flush", func(t *testing.T) {
+		assert.NoError(t, storage.FlushViews(ctx, nil))
+		mockConn.AssertNotCalled(t, "Exec", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("flush adds deltas in one query", func(t *testing.T) {
+		sql := mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "unnest($1::int[], $2::bigint[], $3::bigint[], $4::bigint[])") &&
+				strings.Contains(sql, "get_count = quote_views.get_count + EXCLUDED.get_count")
+		})
+		args := []interface{}{[]int{7}, []int64{2}, []int64{1}, []int64{0}}
+		mockConn.On("Exec", mock.Anything, sql, args).Return(pgconn.NewCommandTag("INSERT 0 1"), nil).Once()
+		assert.NoError(t, storage.FlushViews(ctx, map[int]models.ViewStats{7: {Get: 2, Random: 1}}))
+	})
+
+	t.Run("view stats", func(t *testing.T) {
+		mockConn.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "FROM quote_views WHERE quote_id = ANY($1)")
+		}), []interface{}{[]int{7}}).Return(mockRows, nil).Once()
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", anyArgs(4)...).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int) = 7
+			*args.Get(1).(*int64) = 5
+			*args.Get(3).(*int64) = 1
+		}).Return(nil).Once()
+		expectNoRows(mockRows)
+
+		views, err := storage.ViewStats(ctx, []int{7})
+		assert.NoError(t, err)
+		assert.Equal(t, map[int]models.ViewStats{7: {Get: 5, Daily: 1}}, views)
+	})
+
+	t.Run("most viewed by source", func(t *testing.T) {
+		mockConn.On("Query", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "ORDER BY v.random_count DESC, q.id") && strings.Contains(sql, "q.status = 'approved'")
+		}), []interface{}{5}).Return(mockRows, nil).Once()
+		expectNoRows(mockRows)
+
+		quotes, err := storage.MostViewed(ctx, "random", 5)
+		assert.NoError(t, err)
+		assert.Empty(t, quotes)
+	})
+
+	t.Run("unknown source", func(t *testing.T) {
+		_, err := storage.MostViewed(ctx, "likes", 5)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	mockConn.AssertExpectations(t)
+	mockRows.AssertExpectations(t)
+}
+
+func TestStorage_Collections(t *testing.T) {
+	mockConn := new(MockConn)
+	mockRow := new(MockRow)
+	storage := NewStorage(mockConn)
+	ctx := context.Background()
+
+	t.Run("create with owner and quotes in order", func(t *testing.T) {
+		sql := mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO collections (name, description, owner_id)") &&
+				strings.Contains(sql, "unnest($3::int[]) WITH ORDINALITY")
+		})
+		mockConn.On("QueryRow", mock.Anything, sql, []interface{}{"Stoics", "", []int{}, "key:1"}).Return(mockRow).Once()
+		mockRow.On("Scan", anyArgs(3)...).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int) = 5
+		}).Return(nil).Once()
+
+		c := &models.Collection{Name: "Stoics", OwnerID: "key:1"}
+		assert.NoError(t, storage.CreateCollection(ctx, c))
+		assert.Equal(t, 5, c.ID)
+		assert.Equal(t, []int{}, c.QuoteIDs)
+	})
+
+	t.Run("create with taken name", func(t *testing.T) {
+		mockConn.On("QueryRow", mock.Anything, mock.Anything, []interface{}{"Stoics", "", []int{1}, ""}).Return(mockRow).Once()
+		mockRow.On("Scan", anyArgs(3)...).Return(&pgconn.PgError{Code: "23505"}).Once()
+
+		err := storage.CreateCollection(ctx, &models.Collection{Name: "Stoics", QuoteIDs: []int{1}})
+		assert.ErrorIs(t, err, domain.ErrCollectionExists)
+	})
+
+	t.Run("get lists only approved quotes", func(t *testing.T) {
+		mockConn.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "q.status = 'approved'") && strings.Contains(sql, "ORDER BY cq.position")
+		}), []interface{}{404}).Return(mockRow).Once()
+		mockRow.On("Scan", anyArgs(7)...).Return(pgx.ErrNoRows).Once()
+
+		_, err := storage.GetCollection(ctx, 404)
+		assert.ErrorIs(t, err, domain.ErrCollectionNotFound)
+	})
+
+	t.Run("delete unknown collection", func(t *testing.T) {
+		mockConn.On("Exec", mock.Anything, "DELETE FROM collections WHERE id = $1", []interface{}{404}).Return(pgconn.NewCommandTag("DELETE 0"), nil).Once()
+		assert.ErrorIs(t, storage.DeleteCollection(ctx, 404), domain.ErrCollectionNotFound)
+	})
+
+	t.Run("add to unknown collection or unknown quote", func(t *testing.T) {
+		sql := mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "COALESCE(MAX(position), 0) + 1") && strings.Contains(sql, "DO NOTHING")
+		})
+		mockConn.On("Exec", mock.Anything, sql, []interface{}{404, 1}).Return(pgconn.CommandTag{},
+			&pgconn.PgError{Code: "23503", ConstraintName: "collection_quotes_collection_id_fkey"}).Once()
+		mockConn.On("Exec", mock.Anything, sql, []interface{}{5, 404}).Return(pgconn.CommandTag{},
+			&pgconn.PgError{Code: "23503", ConstraintName: "collection_quotes_quote_id_fkey"}).Once()
+
+		assert.ErrorIs(t, storage.AddToCollection(ctx, 404, 1), domain.ErrCollectionNotFound)
+		assert.ErrorIs(t, storage.AddToCollection(ctx, 5, 404), domain.ErrNotFound)
+	})
+
+	t.Run("remove quote not in collection", func(t *testing.T) {
+		mockConn.On("Exec", mock.Anything, "DELETE FROM collection_quotes WHERE collection_id = $1 AND quote_id = $2", []interface{}{5, 404}).Return(pgconn.NewCommandTag("DELETE 0"), nil).Once()
+		assert.ErrorIs(t, storage.RemoveFromCollection(ctx, 5, 404), domain.ErrNotFound)
+	})
+
+	t.Run("reorder", func(t *testing.T) {
+		mockConn.On("Exec", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "UPDATE collection_quotes cq SET position = u.position")
+		}), []interface{}{5, []int{3, 1, 2}}).Return(pgconn.NewCommandTag("UPDATE 3"), nil).Once()
+		assert.NoError(t, storage.ReorderCollection(ctx, 5, []int{3, 1, 2}))
+	})
+
+	t.Run("random from collection without approved quotes", func(t *testing.T) {
+		mockConn.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "cq.collection_id = $1 AND q.status = 'approved'")
+		}), []interface{}{5}).Return(mockRow).Once()
+		mockRow.On("Scan", anyArgs(8)...).Return(pgx.ErrNoRows).Once()
+
+		_, err := storage.GetRandomFromCollection(ctx, 5)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	mockConn.AssertExpectations(t)
+	mockRow.AssertExpectations(t)
+}
+
+func TestStorage_APIKeys(t *testing.T) {
+	mockConn := new(MockConn)
+	mockRow := new(MockRow)
+	mockRows := new(MockRows)
+	storage := NewStorage(mockConn)
+	ctx := context.Background()
+
+	t.Run("create stores only the hash", func(t *testing.T) {
+		mockConn.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.HasPrefix(sql, "INSERT INTO api_keys (name, prefix, key_hash, scopes, roles)")
+		}), []interface{}{"ci", "qk_ab12", "hash", []string{"read"}, []string{}}).Return(mockRow).Once()
+		mockRow.On("Scan", anyArgs(2)...).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int) = 3
+		}).Return(nil).Once()
+
+		key := &models.APIKey{Name: "ci", Prefix: "qk_ab12", Scopes: []string{"read"}}
+		assert.NoError(t, storage.CreateAPIKey(ctx, key, "hash"))
+		assert.Equal(t, 3, key.ID)
+	})
+
+	t.Run("find skips revoked keys", func(t *testing.T) {
+		mockConn.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "WHERE key_hash = $1 AND revoked_at IS NULL")
+		}), []interface{}{"revoked"}).Return(mockRow).Once()
+		mockRow.On("Scan", anyArgs(6)...).Return(pgx.ErrNoRows).Once()
+
+		_, err := storage.FindAPIKey(ctx, "revoked")
+		assert.ErrorIs(t, err, domain.ErrAPIKeyNotFound)
+	})
+
+	t.Run("list includes revoked keys", func(t *testing.T) {
+		revoked := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+		mockConn.On("Query", mock.Anything, "SELECT id, name, prefix, scopes, roles, created_at, revoked_at FROM api_keys ORDER BY id", []interface{}(nil)).Return(mockRows, nil).Once()
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", anyArgs(7)...).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int) = 3
+			*args.Get(6).(**time.Time) = &revoked
+		}).Return(nil).Once()
+		expectNoRows(mockRows)
+
+		keys, err := storage.ListAPIKeys(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, keys, 1) {
+			assert.Equal(t, &revoked, keys[0].RevokedAt)
+		}
+	})
+
+	t.Run("revoke twice", func(t *testing.T) {
+		sql := "UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL"
+		mockConn.On("Exec", mock.Anything, sql, []interface{}{3}).Return(pgconn.NewCommandTag("UPDATE 1"), nil).Once()
+		mockConn.On("Exec", mock.Anything, sql, []interface{}{3}).Return(pgconn.NewCommandTag("UPDATE 0"), nil).Once()
+
+		assert.NoError(t, storage.RevokeAPIKey(ctx, 3))
+		assert.ErrorIs(t, storage.RevokeAPIKey(ctx, 3), domain.ErrAPIKeyNotFound)
+	})
+
+	mockConn.AssertExpectations(t)
+	mockRow.AssertExpectations(t)
+	mockRows.AssertExpectations(t)
+}
+
+func TestStorage_Idempotency(t *testing.T) {
+	mockConn := new(MockConn)
+	mockRow := new(MockRow)
+	storage := NewStorage(mockConn)
+	ctx := context.Background()
+	reserveSQL := mock.MatchedBy(func(sql string) bool {
+		return strings.Contains(sql, "INSERT INTO idempotency_keys") &&
+			strings.Contains(sql, "WHERE idempotency_keys.expires_at <= CURRENT_TIMESTAMP")
+	})
+
+	t.Run("new key is reserved for the lease", func(t *testing.T) {
+		mockConn.On("Exec", mock.Anything, reserveSQL, []interface{}{"k1", "fp", 60.0}).Return(pgconn.NewCommandTag("INSERT 0 1"), nil).Once()
+
+		resp, err := storage.ReserveIdempotencyKey(ctx, "k1", "fp", time.Minute)
+		assert.NoError(t, err)
+		assert.Nil(t, resp)
+	})
+
+	t.Run("taken key returns the saved response", func(t *testing.T) {
+		mockConn.On("Exec", mock.Anything, reserveSQL, []interface{}{"k1", "fp", 60.0}).Return(pgconn.NewCommandTag("INSERT 0 0"), nil).Once()
+		mockConn.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "FROM idempotency_keys WHERE key = $1")
+		}), []interface{}{"k1"}).Return(mockRow).Once()
+		mockRow.On("Scan", anyArgs(4)...).Run(func(args mock.Arguments) {
+			*args.Get(0).(*string) = "fp"
+			*args.Get(1).(*int) = 201
+			*args.Get(2).(*string) = "application/json"
+			*args.Get(3).(*[]byte) = []byte(`{"data":{}}`)
+		}).Return(nil).Once()
+
+		resp, err := storage.ReserveIdempotencyKey(ctx, "k1", "fp", time.Minute)
+		assert.NoError(t, err)
+		assert.Equal(t, &models.IdempotentResponse{Fingerprint: "fp", StatusCode: 201, ContentType: "application/json", Body: []byte(`{"data":{}}`)}, resp)
+	})
+
+	t.Run("saved response extends the key to ttl", func(t *testing.T) {
+		mockConn.On("Exec", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "expires_at = CURRENT_TIMESTAMP + $5 * INTERVAL '1 second'")
+		}), []interface{}{"k1", 201, "application/json", []byte("{}"), 86400.0}).Return(pgconn.NewCommandTag("UPDATE 1"), nil).Once()
+
+		resp := models.IdempotentResponse{StatusCode: 201, ContentType: "application/json", Body: []byte("{}")}
+		assert.NoError(t, storage.SaveIdempotentResponse(ctx, "k1", resp, 24*time.Hour))
+	})
+
+	t.Run("release and purge", func(t *testing.T) {
+		mockConn.On("Exec", mock.Anything, "DELETE FROM idempotency_keys WHERE key = $1", []interface{}{"k1"}).Return(pgconn.NewCommandTag("DELETE 1"), nil).Once()
+		mockConn.On("Exec", mock.Anything, "DELETE FROM idempotency_keys WHERE expires_at <= CURRENT_TIMESTAMP", []interface{}(nil)).Return(pgconn.NewCommandTag("DELETE 4"), nil).Once()
+
+		assert.NoError(t, storage.ReleaseIdempotencyKey(ctx, "k1"))
+		assert.NoError(t, storage.PurgeIdempotencyKeys(ctx))
+	})
+
+	mockConn.AssertExpectations(t)
+	mockRow.AssertExpectations(t)
+}
+
+func TestStorage_WebhookDeliveries(t *testing.T) {
+	mockConn := new(MockConn)
+	mockRows := new(MockRows)
+	storage := NewStorage(mockConn)
+	ctx := context.Background()
+
+	t.Run("enqueue skips events already queued", func(t *testing.T) {
+		mockConn.On("Exec", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "WHERE $2 = ANY(events)") && strings.Contains(sql, "ON CONFLICT (webhook_id, event_id) DO NOTHING")
+		}), []interface{}{int64(9), "quote.created", []byte("{}")}).Return(pgconn.NewCommandTag("INSERT 0 2"), nil).Once()
+		assert.NoError(t, storage.EnqueueDeliveries(ctx, 9, "quote.created", []byte("{}")))
+	})
+
+	t.Run("claim leases due deliveries", func(t *testing.T) {
+		sql := mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)") &&
+				strings.Contains(sql, "FOR UPDATE SKIP LOCKED") && strings.Contains(sql, "LIMIT $1")
+		})
+		mockConn.On("Query", mock.Anything, sql, []interface{}{10, 90.0}).Return(mockRows, nil).Once()
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", anyArgs(9)...).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int64) = 11
+			*args.Get(1).(*int) = 2
+			*args.Get(7).(*string) = "https://example.com/hook"
+			*args.Get(8).(*string) = "secret"
+		}).Return(nil).Once()
+		expectNoRows(mockRows)
+
+		due, err := storage.ClaimDeliveries(ctx, 10, 90*time.Second)
+		assert.NoError(t, err)
+		if assert.Len(t, due, 1) {
+			assert.Equal(t, int64(11), due[0].ID)
+			assert.Equal(t, "https://example.com/hook", due[0].URL)
+			assert.Equal(t, "secret", due[0].Secret)
+		}
+	})
+
+	t.Run("finish schedules the retry", func(t *testing.T) {
+		mockConn.On("Exec", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "WHEN $2 = 'pending' THEN CURRENT_TIMESTAMP + make_interval(secs => $6)")
+		}), []interface{}{int64(11), models.DeliveryPending, 2, 503, "503 Service Unavailable", 20.0}).Return(pgconn.NewCommandTag("UPDATE 1"), nil).Once()
+
+		d := &models.WebhookDelivery{ID: 11, Status: models.DeliveryPending, Attempts: 2, ResponseStatus: 503, LastError: "503 Service Unavailable"}
+		assert.NoError(t, storage.FinishDelivery(ctx, d, 20*time.Second))
+	})
+
+	mockConn.AssertExpectations(t)
+	mockRows.AssertExpectations(t)
+}
+
+func TestStorage_Outbox(t *testing.T) {
+	mockConn := new(MockConn)
+	mockRow := new(MockRow)
+	mockRows := new(MockRows)
+	storage := NewStorage(mockConn, WithOutbox())
+	ctx := context.Background()
+
+	t.Run("dispatched event id", func(t *testing.T) {
+		mockConn.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "SELECT event_id FROM quote_events_cursor") && strings.Contains(sql, "SELECT MAX(id) FROM quote_events")
+		}), []interface{}(nil)).Return(mockRow).Once()
+		mockRow.On("Scan", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int64) = 42
+		}).Return(nil).Once()
+
+		id, err := storage.DispatchedEventID(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(42), id)
+	})
+
+	t.Run("save dispatched event id only moves forward", func(t *testing.T) {
+		mockConn.On("Exec", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO quote_events_cursor") && strings.Contains(sql, "GREATEST(")
+		}), []interface{}{int64(43)}).Return(pgconn.NewCommandTag("INSERT 0 1"), nil).Once()
+		assert.NoError(t, storage.SaveDispatchedEventID(ctx, 43))
+	})
+
+	t.Run("events after decode the quote", func(t *testing.T) {
+		mockConn.On("Query", mock.Anything, "SELECT id, type, payload, created_at FROM quote_events WHERE id > $1 ORDER BY id LIMIT $2", []interface{}{int64(42), 100}).Return(mockRows, nil).Once()
+		mockRows.On("Next").Return(true).Once()
+		mockRows.On("Scan", anyArgs(4)...).Run(func(args mock.Arguments) {
+			*args.Get(0).(*int64) = 43
+			*args.Get(1).(*string) = models.EventDeleted
+			*args.Get(2).(*[]byte) = []byte(`{"id": 7, "author": "Seneca"}`)
+		}).Return(nil).Once()
+		expectNoRows(mockRows)
+
+		events, err := storage.EventsAfter(ctx, 42, 100)
+		assert.NoError(t, err)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, int64(43), events[0].ID)
+			assert.Equal(t, models.EventDeleted, events[0].Type)
+			assert.Equal(t, 7, events[0].Quote.ID)
+			assert.Equal(t, "Seneca", events[0].Quote.Author)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		mockConn.On("Exec", mock.Anything, "DELETE FROM quote_events WHERE created_at < CURRENT_TIMESTAMP - make_interval(secs => $1)", []interface{}{86400.0}).Return(pgconn.NewCommandTag("DELETE 3"), nil).Once()
+		assert.NoError(t, storage.PurgeEvents(ctx, 24*time.Hour))
+	})
+
+	t.Run("failed event rolls back the change", func(t *testing.T) {
+		mockConn.On("QueryRow", mock.Anything, mock.MatchedBy(func(sql string) bool {
+			return strings.Contains(sql, "INSERT INTO quote_events")
+		}), mock.Anything).Return(mockRow).Once()
+		mockRow.On("Scan", anyArgs(3)...).Return(errors.New("connection reset")).Once()
+
+		err := storage.recorded(ctx, func(ctx context.Context) (*models.OutboxEvent, error) {
+			return &models.OutboxEvent{Type: models.EventDeleted, Quote: models.Quote{ID: 7}}, nil
+		})
+		assert.Error(t, err)
+		assert.True(t, mockConn.Txs[len(mockConn.Txs)-1].RolledBack)
+	})
+
+	mockConn.AssertExpectations(t)
+	mockRow.AssertExpectations(t)
+	mockRows.AssertExpectations(t)
+}
+
+// MockListenConn для мока соединения Listener
+type MockListenConn struct {
+	mock.Mock
+}
+
+func (m *MockListenConn) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	ret := m.Called(ctx, sql)
+	return ret.Get(0).(pgconn.CommandTag), ret.Error(1)
+}
+
+func (m *MockListenConn) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	ret := m.Called(ctx)
+	return ret.Get(0).(*pgconn.Notification), ret.Error(1)
+}
+
+func (m *MockListenConn) Close(ctx context.Context) error {
+	return m.Called(ctx).Error(0)
+}
+
+func TestListener(t *testing.T) {
+	ctx := context.Background()
+	var conns []*MockListenConn
+	listener := &Listener{
+		channel: EventsChannel,
+		connect: func(ctx context.Context) (listenConn, error) {
+			conn := new(MockListenConn)
+			conn.On("Exec", mock.Anything, `LISTEN "quote_events"`).Return(pgconn.NewCommandTag("LISTEN"), nil).Once()
+			conns = append(conns, conn)
+			return conn, nil
+		},
+	}
+
+	// первый вызов только подключается: журнал нужно перечитать
+	assert.NoError(t, listener.Wait(ctx))
+	assert.Len(t, conns, 1)
+
+	conns[0].On("WaitForNotification", mock.Anything).Return(&pgconn.Notification{Channel: EventsChannel, Payload: "43"}, nil).Once()
+	assert.NoError(t, listener.Wait(ctx))
+
+	// обрыв закрывает соединение, следующий вызов подключается заново
+	conns[0].On("WaitForNotification", mock.Anything).Return((*pgconn.Notification)(nil), errors.New("connection reset")).Once()
+	conns[0].On("Close", mock.Anything).Return(nil).Once()
+	assert.Error(t, listener.Wait(ctx))
+	assert.NoError(t, listener.Wait(ctx))
+	assert.Len(t, conns, 2)
+
+	for _, conn := range conns {
+		conn.AssertExpectations(t)
+	}
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"quote-service/internal/models"
+	"quote-service/pkg/logger"
+)
+
+// saveTags сохраняет теги новой цитаты
+func (s *Storage) saveTags(ctx context.Context, quoteID int, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	query := `
+        INSERT INTO quote_tags (quote_id, tag)
+        SELECT $1, unnest($2::text[])
+        ON CONFLICT DO NOTHING
+    `
+	if _, err := s.conn(ctx).Exec(ctx, query, quoteID, tags); err != nil {
+		logger.Errorf("Ошибка сохранения тегов цитаты: %v", err)
+		return err
+	}
+	return nil
+}
+
+// TagsByQuoteIDs возвращает теги цитат одним запросом, теги каждой цитаты по алфавиту
+func (s *Storage) TagsByQuoteIDs(ctx context.Context, ids []int) (map[int][]string, error) {
+	query := `SELECT quote_id, tag FROM quote_tags WHERE quote_id = ANY($1) ORDER BY quote_id, tag`
+	rows, err := s.conn(ctx).Query(ctx, query, ids)
+	if err != nil {
+		logger.Errorf("Ошибка получения тегов цитат: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[int][]string, len(ids))
+	for rows.Next() {
+		var id int
+		var tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		tags[id] = append(tags[id], tag)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return tags, nil
+}
+
+// GetByAuthors возвращает одобренные цитаты нескольких авторов одним запросом
+func (s *Storage) GetByAuthors(ctx context.Context, authors []string) ([]models.Quote, error) {
+	query := `SELECT id, author, quote, created_at, COALESCE(owner_id, ''), status, COALESCE(moderation_reason, ''), updated_at FROM quotes WHERE author = ANY($1) AND status = 'approved' ORDER BY id`
+	rows, err := s.conn(ctx).Query(ctx, query, authors)
+	if err != nil {
+		logger.Errorf("Ошибка получения цитат авторов: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotes []models.Quote
+	for rows.Next() {
+		var q models.Quote
+		if err := rows.Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt, &q.OwnerID, &q.Status, &q.ModerationReason, &q.UpdatedAt); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		quotes = append(quotes, q)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return quotes, nil
+}
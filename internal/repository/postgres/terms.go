@@ -0,0 +1,300 @@
+package postgres
+
+import (
+	"context"
+	"quote-service/internal/models"
+	"quote-service/pkg/logger"
+	"quote-service/pkg/textsim"
+)
+
+// termBackfillBatch - сколько цитат BackfillTerms индексирует в одной транзакции
+const termBackfillBatch = 500
+
+// indexTerms сохраняет вектор слов цитаты для поиска похожих
+func (s *Storage) indexTerms(ctx context.Context, quoteID int, text string) error {
+	terms := textsim.Terms(text)
+	if len(terms) == 0 {
+		return nil
+	}
+	words := make([]string, 0, len(terms))
+	freqs := make([]int, 0, len(terms))
+	for term, freq := range terms {
+		words = append(words, term)
+		freqs = append(freqs, freq)
+	}
+
+	query := `
+        INSERT INTO quote_terms (quote_id, term, freq)
+        SELECT $1, u.term, u.freq FROM unnest($2::text[], $3::int[]) AS u(term, freq)
+        ON CONFLICT (quote_id, term) DO UPDATE SET freq = EXCLUDED.freq
+    `
+	if _, err := s.conn(ctx).Exec(ctx, query, quoteID, words, freqs); err != nil {
+		logger.Errorf("Ошибка индексации слов цитаты: %v", err)
+		return err
+	}
+	return nil
+}
+
+// reindexTerms заменяет вектор слов цитаты после изменения текста
+func (s *Storage) reindexTerms(ctx context.Context, quoteID int, text string) error {
+	if _, err := s.conn(ctx).Exec(ctx, `DELETE FROM quote_terms WHERE quote_id = $1`, quoteID); err != nil {
+		logger.Errorf("Ошибка удаления термов цитаты: %v", err)
+		return err
+	}
+	return s.indexTerms(ctx, quoteID, text)
+}
+
+// BackfillTerms индексирует цитаты, у которых нет вектора слов, тем же разбором текста, что и при создании.
+// С all индекс всех цитат строится заново. Цитаты обрабатываются пачками по termBackfillBatch
+// в отдельных транзакциях. Возвращает число обработанных цитат.
+func (s *Storage) BackfillTerms(ctx context.Context, all bool) (int, error) {
+	query := `
+        SELECT id, quote FROM quotes q
+        WHERE id > $1 AND ($2 OR NOT EXISTS (SELECT 1 FROM quote_terms t WHERE t.quote_id = q.id))
+        ORDER BY id
+        LIMIT $3
+    `
+	total, afterID := 0, 0
+	for {
+		var quotes []models.Quote
+		rows, err := s.conn(ctx).Query(ctx, query, afterID, all, termBackfillBatch)
+		if err != nil {
+			logger.Errorf("Ошибка выбора цитат для индексации: %v", err)
+			return total, err
+		}
+		for rows.Next() {
+			var q models.Quote
+			if err := rows.Scan(&q.ID, &q.Quote); err != nil {
+				rows.Close()
+				logger.Errorf("Ошибка сканирования строки: %v", err)
+				return total, err
+			}
+			quotes = append(quotes, q)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			logger.Errorf("Ошибка при итерации строк: %v", err)
+			return total, err
+		}
+		if len(quotes) == 0 {
+			return total, nil
+		}
+
+		err = s.InTx(ctx, func(ctx context.Context) error {
+			for _, q := range quotes {
+				if err := s.reindexTerms(ctx, q.ID, q.Quote); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		total += len(quotes)
+		afterID = quotes[len(quotes)-1].ID
+	}
+}
+
+func (s *Storage) TermVector(ctx context.Context, quoteID int) (map[string]int, error) {
+	query := `SELECT term, freq FROM quote_terms WHERE quote_id = $1`
+	rows, err := s.conn(ctx).Query(ctx, query, quoteID)
+	if err != nil {
+		logger.Errorf("Ошибка получения слов цитаты: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	vector := make(map[string]int)
+	for rows.Next() {
+		var term string
+		var freq int
+		if err := rows.Scan(&term, &freq); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		vector[term] = freq
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return vector, nil
+}
+
+// TermNeighbors возвращает векторы слов цитат, у которых больше всего общих слов с quoteID
+func (s *Storage) TermNeighbors(ctx context.Context, quoteID int, limit int) (map[int]map[string]int, error) {
+	query := `
+        WITH target AS (
+            SELECT term FROM quote_terms WHERE quote_id = $1
+        ), candidates AS (
+            SELECT t.quote_id
+            FROM quote_terms t
+            JOIN target ON target.term = t.term
+            WHERE t.quote_id <> $1
+            GROUP BY t.quote_id
+            ORDER BY COUNT(*) DESC, t.quote_id
+            LIMIT $2
+        )
+        SELECT t.quote_id, t.term, t.freq
+        FROM quote_terms t
+        JOIN candidates c ON c.quote_id = t.quote_id
+    `
+	rows, err := s.conn(ctx).Query(ctx, query, quoteID, limit)
+	if err != nil {
+		logger.Errorf("Ошибка поиска похожих цитат: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	vectors := make(map[int]map[string]int)
+	for rows.Next() {
+		var id, freq int
+		var term string
+		if err := rows.Scan(&id, &term, &freq); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		if vectors[id] == nil {
+			vectors[id] = make(map[string]int)
+		}
+		vectors[id][term] = freq
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// TermMatches возвращает векторы слов одобренных цитат, содержащих больше всего слов из terms
+func (s *Storage) TermMatches(ctx context.Context, terms []string, limit int) (map[int]map[string]int, error) {
+	query := `
+        WITH candidates AS (
+            SELECT t.quote_id
+            FROM quote_terms t
+            JOIN quotes q ON q.id = t.quote_id AND q.status = 'approved'
+            WHERE t.term = ANY($1)
+            GROUP BY t.quote_id
+            ORDER BY COUNT(*) DESC, t.quote_id
+            LIMIT $2
+        )
+        SELECT t.quote_id, t.term, t.freq
+        FROM quote_terms t
+        JOIN candidates c ON c.quote_id = t.quote_id
+    `
+	rows, err := s.conn(ctx).Query(ctx, query, terms, limit)
+	if err != nil {
+		logger.Errorf("Ошибка поиска цитат по словам: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	vectors := make(map[int]map[string]int)
+	for rows.Next() {
+		var id, freq int
+		var term string
+		if err := rows.Scan(&id, &term, &freq); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		if vectors[id] == nil {
+			vectors[id] = make(map[string]int)
+		}
+		vectors[id][term] = freq
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// TermStats возвращает число цитат, содержащих каждое слово, и общее число цитат
+func (s *Storage) TermStats(ctx context.Context, terms []string) (map[string]int, int, error) {
+	var total int
+	if err := s.conn(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM quotes`).Scan(&total); err != nil {
+		logger.Errorf("Ошибка подсчета цитат: %v", err)
+		return nil, 0, err
+	}
+
+	query := `SELECT term, COUNT(*) FROM quote_terms WHERE term = ANY($1) GROUP BY term`
+	rows, err := s.conn(ctx).Query(ctx, query, terms)
+	if err != nil {
+		logger.Errorf("Ошибка получения частот слов: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	df := make(map[string]int, len(terms))
+	for rows.Next() {
+		var term string
+		var count int
+		if err := rows.Scan(&term, &count); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, 0, err
+		}
+		df[term] = count
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, 0, err
+	}
+	return df, total, nil
+}
+
+// TermCandidates для каждого набора слов из terms возвращает не больше limit цитат с указанными статусами,
+// у которых больше всего общих слов с набором, начиная с наиболее близкой. Все наборы ищутся одним запросом.
+func (s *Storage) TermCandidates(ctx context.Context, terms [][]string, limit int, statuses ...string) ([][]models.Quote, error) {
+	candidates := make([][]models.Quote, len(terms))
+	var sets []int
+	var words []string
+	for i, set := range terms {
+		for _, term := range set {
+			sets = append(sets, i)
+			words = append(words, term)
+		}
+	}
+	if len(words) == 0 {
+		return candidates, nil
+	}
+
+	query := `
+        WITH input AS (
+            SELECT u.idx, u.term FROM unnest($1::int[], $2::text[]) AS u(idx, term)
+        ), ranked AS (
+            SELECT i.idx, t.quote_id,
+                ROW_NUMBER() OVER (PARTITION BY i.idx ORDER BY COUNT(*) DESC, t.quote_id) AS rank
+            FROM input i
+            JOIN quote_terms t ON t.term = i.term
+            JOIN quotes q ON q.id = t.quote_id AND q.status = ANY($3)
+            GROUP BY i.idx, t.quote_id
+        )
+        SELECT r.idx, q.id, q.author, q.quote, q.created_at, COALESCE(q.owner_id, ''), q.status, COALESCE(q.moderation_reason, ''), q.updated_at
+        FROM ranked r
+        JOIN quotes q ON q.id = r.quote_id
+        WHERE r.rank <= $4
+        ORDER BY r.idx, r.rank
+    `
+	rows, err := s.conn(ctx).Query(ctx, query, sets, words, visibleStatuses(statuses), limit)
+	if err != nil {
+		logger.Errorf("Ошибка поиска похожих цитат по словам: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var set int
+		var q models.Quote
+		if err := rows.Scan(&set, &q.ID, &q.Author, &q.Quote, &q.CreatedAt, &q.OwnerID, &q.Status, &q.ModerationReason, &q.UpdatedAt); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		candidates[set] = append(candidates[set], q)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return candidates, nil
+}
@@ -0,0 +1,54 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"quote-service/pkg/logger"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// txKey - ключ контекста, в котором лежит текущая транзакция
+type txKey struct{}
+
+type txBeginner interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// conn возвращает транзакцию из контекста, если она есть, иначе общее соединение
+func (s *Storage) conn(ctx context.Context) DBConn {
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		return tx
+	}
+	return s.db
+}
+
+// InTx выполняет fn в транзакции: все методы Storage, вызванные с переданным в fn контекстом, работают в ней.
+// Ошибка fn откатывает транзакцию. Вложенный вызов создает точку сохранения и откатывает только ее.
+func (s *Storage) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	var beginner txBeginner
+	if tx, ok := ctx.Value(txKey{}).(pgx.Tx); ok {
+		beginner = tx
+	} else if b, ok := s.db.(txBeginner); ok {
+		beginner = b
+	} else {
+		return errors.New("storage: connection does not support transactions")
+	}
+
+	tx, err := beginner.Begin(ctx)
+	if err != nil {
+		logger.Errorf("Ошибка начала транзакции: %v", err)
+		return err
+	}
+	// после Commit откат ничего не делает
+	defer tx.Rollback(context.WithoutCancel(ctx))
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		logger.Errorf("Ошибка фиксации транзакции: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/pkg/logger"
+)
+
+// viewOrder - допустимые сортировки для MostViewed
+var viewOrder = map[string]string{
+	"":       "v.get_count + v.random_count + v.daily_count",
+	"get":    "v.get_count",
+	"random": "v.random_count",
+	"daily":  "v.daily_count",
+}
+
+// FlushViews прибавляет накопленные счетчики одним запросом. Счетчики удаленных цитат отбрасываются.
+func (s *Storage) FlushViews(ctx context.Context, deltas map[int]models.ViewStats) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	ids := make([]int, 0, len(deltas))
+	gets := make([]int64, 0, len(deltas))
+	randoms := make([]int64, 0, len(deltas))
+	dailies := make([]int64, 0, len(deltas))
+	for id, d := range deltas {
+		ids = append(ids, id)
+		gets = append(gets, d.Get)
+		randoms = append(randoms, d.Random)
+		dailies = append(dailies, d.Daily)
+	}
+
+	query := `
+        INSERT INTO quote_views (quote_id, get_count, random_count, daily_count, last_served_at)
+        SELECT u.id, u.get_count, u.random_count, u.daily_count, CURRENT_TIMESTAMP
+        FROM unnest($1::int[], $2::bigint[], $3::bigint[], $4::bigint[]) AS u(id, get_count, random_count, daily_count)
+        WHERE EXISTS (SELECT 1 FROM quotes WHERE quotes.id = u.id)
+        ON CONFLICT (quote_id) DO UPDATE SET
+            get_count = quote_views.get_count + EXCLUDED.get_count,
+            random_count = quote_views.random_count + EXCLUDED.random_count,
+            daily_count = quote_views.daily_count + EXCLUDED.daily_count,
+            last_served_at = EXCLUDED.last_served_at
+    `
+	if _, err := s.conn(ctx).Exec(ctx, query, ids, gets, randoms, dailies); err != nil {
+		logger.Errorf("Ошибка сохранения счетчиков просмотров: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) ViewStats(ctx context.Context, ids []int) (map[int]models.ViewStats, error) {
+	query := `SELECT quote_id, get_count, random_count, daily_count FROM quote_views WHERE quote_id = ANY($1)`
+	rows, err := s.conn(ctx).Query(ctx, query, ids)
+	if err != nil {
+		logger.Errorf("Ошибка получения счетчиков просмотров: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	views := make(map[int]models.ViewStats, len(ids))
+	for rows.Next() {
+		var id int
+		var v models.ViewStats
+		if err := rows.Scan(&id, &v.Get, &v.Random, &v.Daily); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		views[id] = v
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return views, nil
+}
+
+func (s *Storage) MostViewed(ctx context.Context, source string, limit int) ([]models.Quote, error) {
+	order, ok := viewOrder[source]
+	if !ok {
+		return nil, domain.ErrInvalidInput
+	}
+	query := `
+        SELECT q.id, q.author, q.quote, q.created_at, COALESCE(q.owner_id, ''), q.status, COALESCE(q.moderation_reason, ''), q.updated_at, v.get_count, v.random_count, v.daily_count
+        FROM quotes q
+        JOIN quote_views v ON v.quote_id = q.id
+        WHERE q.status = 'approved'
+        ORDER BY ` + order + ` DESC, q.id
+        LIMIT $1
+    `
+	rows, err := s.conn(ctx).Query(ctx, query, limit)
+	if err != nil {
+		logger.Errorf("Ошибка получения самых просматриваемых цитат: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var quotes []models.Quote
+	for rows.Next() {
+		var q models.Quote
+		if err := rows.Scan(&q.ID, &q.Author, &q.Quote, &q.CreatedAt, &q.OwnerID, &q.Status, &q.ModerationReason, &q.UpdatedAt, &q.Views.Get, &q.Views.Random, &q.Views.Daily); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		quotes = append(quotes, q)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return quotes, nil
+}
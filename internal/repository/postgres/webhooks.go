@@ -0,0 +1,199 @@
+package postgres
+
+import (
+	"context"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/pkg/logger"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at, COALESCE(response_status, 0), COALESCE(last_error, ''), created_at, delivered_at`
+
+func (s *Storage) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	query := `INSERT INTO webhooks (url, secret, events) VALUES ($1, $2, $3) RETURNING id, created_at`
+	if err := s.conn(ctx).QueryRow(ctx, query, webhook.URL, webhook.Secret, webhook.Events).Scan(&webhook.ID, &webhook.CreatedAt); err != nil {
+		logger.Errorf("Ошибка создания вебхука: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) ListWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	query := `SELECT id, url, events, created_at FROM webhooks ORDER BY id`
+	rows, err := s.conn(ctx).Query(ctx, query)
+	if err != nil {
+		logger.Errorf("Ошибка получения вебхуков: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		var w models.Webhook
+		if err := rows.Scan(&w.ID, &w.URL, &w.Events, &w.CreatedAt); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		webhooks = append(webhooks, w)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (s *Storage) GetWebhook(ctx context.Context, id int) (*models.Webhook, error) {
+	query := `SELECT id, url, events, created_at FROM webhooks WHERE id = $1`
+	var w models.Webhook
+	err := s.conn(ctx).QueryRow(ctx, query, id).Scan(&w.ID, &w.URL, &w.Events, &w.CreatedAt)
+	if err == pgx.ErrNoRows {
+		return nil, domain.ErrWebhookNotFound
+	}
+	if err != nil {
+		logger.Errorf("Ошибка получения вебхука: %v", err)
+		return nil, err
+	}
+	return &w, nil
+}
+
+func (s *Storage) DeleteWebhook(ctx context.Context, id int) error {
+	result, err := s.conn(ctx).Exec(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		logger.Errorf("Ошибка удаления вебхука: %v", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// EnqueueDeliveries создает доставку для каждого вебхука, подписанного на событие.
+// Повторная постановка события журнала пропускается уникальным индексом (webhook_id, event_id).
+func (s *Storage) EnqueueDeliveries(ctx context.Context, eventID int64, event string, payload []byte) error {
+	query := `
+        INSERT INTO webhook_deliveries (webhook_id, event_id, event, payload)
+        SELECT id, NULLIF($1, 0), $2, $3 FROM webhooks WHERE $2 = ANY(events)
+        ON CONFLICT (webhook_id, event_id) DO NOTHING
+    `
+	if _, err := s.conn(ctx).Exec(ctx, query, eventID, event, payload); err != nil {
+		logger.Errorf("Ошибка постановки доставок вебхуков в очередь: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ClaimDeliveries откладывает выбранные доставки на lease, поэтому другой экземпляр сервиса
+// не возьмет их повторно, а если этот экземпляр упадет, доставка вернется в очередь по истечении lease.
+// SKIP LOCKED не дает экземплярам ждать друг друга на одних и тех же строках.
+func (s *Storage) ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.DueDelivery, error) {
+	query := `
+        UPDATE webhook_deliveries d
+        SET next_attempt_at = CURRENT_TIMESTAMP + make_interval(secs => $2)
+        FROM webhooks w
+        WHERE w.id = d.webhook_id AND d.id IN (
+            SELECT id FROM webhook_deliveries
+            WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+            ORDER BY next_attempt_at, id
+            LIMIT $1
+            FOR UPDATE SKIP LOCKED
+        )
+        RETURNING d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.created_at, w.url, w.secret
+    `
+	rows, err := s.conn(ctx).Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		logger.Errorf("Ошибка выборки доставок вебхуков: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []models.DueDelivery
+	for rows.Next() {
+		var d models.DueDelivery
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &d.CreatedAt, &d.URL, &d.Secret); err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return due, nil
+}
+
+func (s *Storage) FinishDelivery(ctx context.Context, d *models.WebhookDelivery, retryIn time.Duration) error {
+	query := `
+        UPDATE webhook_deliveries
+        SET status = $2, attempts = $3, response_status = NULLIF($4, 0), last_error = NULLIF($5, ''),
+            next_attempt_at = CASE WHEN $2 = 'pending' THEN CURRENT_TIMESTAMP + make_interval(secs => $6) ELSE next_attempt_at END,
+            delivered_at = CASE WHEN $2 = 'delivered' THEN CURRENT_TIMESTAMP END
+        WHERE id = $1
+    `
+	if _, err := s.conn(ctx).Exec(ctx, query, d.ID, d.Status, d.Attempts, d.ResponseStatus, d.LastError, retryIn.Seconds()); err != nil {
+		logger.Errorf("Ошибка сохранения результата доставки вебхука: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (s *Storage) ListDeliveries(ctx context.Context, webhookID, limit int) ([]models.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY id DESC LIMIT $2`
+	rows, err := s.conn(ctx).Query(ctx, query, webhookID, limit)
+	if err != nil {
+		logger.Errorf("Ошибка получения журнала доставок: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			logger.Errorf("Ошибка сканирования строки: %v", err)
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	if err := rows.Err(); err != nil {
+		logger.Errorf("Ошибка при итерации строк: %v", err)
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (s *Storage) ReplayDelivery(ctx context.Context, webhookID int, id int64) (*models.WebhookDelivery, error) {
+	query := `
+        INSERT INTO webhook_deliveries (webhook_id, event, payload)
+        SELECT webhook_id, event, payload FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2
+        RETURNING ` + deliveryColumns
+	d, err := scanDelivery(s.conn(ctx).QueryRow(ctx, query, id, webhookID))
+	if err == pgx.ErrNoRows {
+		return nil, domain.ErrDeliveryNotFound
+	}
+	if err != nil {
+		logger.Errorf("Ошибка повтора доставки вебхука: %v", err)
+		return nil, err
+	}
+	return d, nil
+}
+
+func scanDelivery(row pgx.Row) (*models.WebhookDelivery, error) {
+	var (
+		d    models.WebhookDelivery
+		next time.Time
+	)
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &next,
+		&d.ResponseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt); err != nil {
+		return nil, err
+	}
+	if d.Status == models.DeliveryPending {
+		d.NextAttemptAt = &next
+	}
+	return &d, nil
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"quote-service/internal/auth"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"strconv"
+	"strings"
+)
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey, hash string) error
+	FindAPIKey(ctx context.Context, hash string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int) error
+}
+
+type APIKeyService struct {
+	repo APIKeyRepository
+}
+
+func NewAPIKeyService(repo APIKeyRepository) *APIKeyService {
+	return &APIKeyService{repo: repo}
+}
+
+// Issue выдает новый ключ. Открытый ключ возвращается только здесь, в БД сохраняется его хеш.
+// Если роли не указаны, они выводятся из областей доступа.
+func (s *APIKeyService) Issue(ctx context.Context, name string, scopes, roles []string) (string, *models.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(scopes) == 0 {
+		return "", nil, domain.ErrInvalidInput
+	}
+	for _, scope := range scopes {
+		if !auth.ValidScope(scope) {
+			return "", nil, domain.ErrInvalidInput
+		}
+	}
+	for _, role := range roles {
+		if !auth.ValidRole(role) {
+			return "", nil, domain.ErrInvalidInput
+		}
+	}
+
+	plain, prefix, err := auth.GenerateKey()
+	if err != nil {
+		return "", nil, err
+	}
+	key := &models.APIKey{Name: name, Prefix: prefix, Scopes: scopes, Roles: roles}
+	if err := s.repo.CreateAPIKey(ctx, key, auth.HashKey(plain)); err != nil {
+		return "", nil, err
+	}
+	return plain, key, nil
+}
+
+func (s *APIKeyService) List(ctx context.Context) ([]models.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx)
+}
+
+func (s *APIKeyService) Revoke(ctx context.Context, id int) error {
+	if id <= 0 {
+		return domain.ErrInvalidInput
+	}
+	return s.repo.RevokeAPIKey(ctx, id)
+}
+
+// Authenticate возвращает клиента по открытому ключу
+func (s *APIKeyService) Authenticate(ctx context.Context, plain string) (*auth.Principal, error) {
+	if plain == "" {
+		return nil, domain.ErrUnauthorized
+	}
+	key, err := s.repo.FindAPIKey(ctx, auth.HashKey(plain))
+	if err == domain.ErrAPIKeyNotFound {
+		return nil, domain.ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+	roles := key.Roles
+	if len(roles) == 0 {
+		roles = auth.RolesFromScopes(key.Scopes)
+	}
+	return &auth.Principal{
+		ID:     "key:" + strconv.Itoa(key.ID),
+		Name:   key.Name,
+		Roles:  roles,
+		Scopes: key.Scopes,
+	}, nil
+}
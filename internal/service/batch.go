@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+)
+
+// Операции пакетного запроса
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// Transactor выполняет fn в транзакции БД. Репозитории, вызванные с контекстом из fn, работают в ней,
+// вложенный вызов откатывает при ошибке только свою часть.
+type Transactor interface {
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// BatchOp - одна операция пакета. Для delete из Quote используется только ID.
+type BatchOp struct {
+	Op    string
+	Quote models.Quote
+}
+
+// BatchResult - итог операции: сохраненная цитата для create и update или причина отказа
+type BatchResult struct {
+	Quote *models.Quote
+	Err   error
+}
+
+// errBatchAborted откатывает транзакцию атомарного пакета после первой неудачной операции
+var errBatchAborted = errors.New("batch aborted")
+
+// WithTransactions включает пакетные операции
+func WithTransactions(tx Transactor) Option {
+	return func(s *QuoteService) {
+		s.tx = tx
+	}
+}
+
+// Batch выполняет операции в одной транзакции. В атомарном режиме первая ошибка откатывает весь пакет,
+// и остальные операции получают domain.ErrRolledBack. Иначе каждая операция выполняется в своей точке
+// сохранения, и ошибка откатывает только ее. Ошибка возвращается, только если не удалось выполнить саму транзакцию.
+// События рассылаются после фиксации транзакции и только для сохраненных операций.
+// Кандидаты в дубликаты для всех создаваемых цитат загружаются одним запросом, а новые цитаты
+// сравниваются еще и с созданными раньше в этом же пакете.
+func (s *QuoteService) Batch(ctx context.Context, ops []BatchOp, atomic bool) ([]BatchResult, error) {
+	if s.tx == nil {
+		return nil, errors.New("batch operations require transactions")
+	}
+
+	ctx, pending := withPendingEvents(ctx)
+	results := make([]BatchResult, len(ops))
+	err := s.tx.InTx(ctx, func(ctx context.Context) error {
+		candidates, err := s.batchCandidates(ctx, ops)
+		if err != nil {
+			return err
+		}
+		for i, op := range ops {
+			if op.Op == BatchCreate && s.duplicatesEnabled() {
+				candidates[i] = append(candidates[i], createdQuotes(results[:i])...)
+			}
+			if atomic {
+				if results[i] = s.batchOp(ctx, op, candidates[i]); results[i].Err != nil {
+					return errBatchAborted
+				}
+				continue
+			}
+			emitted := len(pending.events)
+			err := s.tx.InTx(ctx, func(ctx context.Context) error {
+				results[i] = s.batchOp(ctx, op, candidates[i])
+				return results[i].Err
+			})
+			if err != nil {
+				pending.events = pending.events[:emitted]
+				if results[i].Err == nil {
+					results[i] = BatchResult{Err: err}
+				}
+			}
+		}
+		return nil
+	})
+	if err == errBatchAborted {
+		for i := range results {
+			if results[i].Err == nil {
+				results[i] = BatchResult{Err: domain.ErrRolledBack}
+			}
+		}
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.flushEvents(pending)
+	return results, nil
+}
+
+// batchCandidates загружает кандидатов в дубликаты для всех операций create одним запросом к индексу
+func (s *QuoteService) batchCandidates(ctx context.Context, ops []BatchOp) ([][]models.Quote, error) {
+	candidates := make([][]models.Quote, len(ops))
+	if !s.duplicatesEnabled() {
+		return candidates, nil
+	}
+	var quotes []models.Quote
+	var index []int
+	for i, op := range ops {
+		if op.Op != BatchCreate {
+			continue
+		}
+		quote := op.Quote
+		normalize(&quote)
+		if quote.Author == "" || quote.Quote == "" {
+			// такая операция не пройдет проверку в Create
+			continue
+		}
+		quotes = append(quotes, quote)
+		index = append(index, i)
+	}
+	if len(quotes) == 0 {
+		return candidates, nil
+	}
+	loaded, err := s.duplicateCandidates(ctx, quotes)
+	if err != nil {
+		return nil, err
+	}
+	for k, i := range index {
+		candidates[i] = loaded[k]
+	}
+	return candidates, nil
+}
+
+// createdQuotes возвращает цитаты, уже созданные предыдущими операциями пакета
+func createdQuotes(results []BatchResult) []models.Quote {
+	var quotes []models.Quote
+	for _, res := range results {
+		if res.Err == nil && res.Quote != nil && res.Quote.ID > 0 {
+			quotes = append(quotes, *res.Quote)
+		}
+	}
+	return quotes
+}
+
+func (s *QuoteService) batchOp(ctx context.Context, op BatchOp, candidates []models.Quote) BatchResult {
+	quote := op.Quote
+	switch op.Op {
+	case BatchCreate:
+		quote.ID = 0
+		if err := s.Create(ctx, &quote, withCandidates(candidates)); err != nil {
+			return BatchResult{Err: err}
+		}
+		return BatchResult{Quote: &quote}
+	case BatchUpdate:
+		if err := s.Update(ctx, &quote); err != nil {
+			return BatchResult{Err: err}
+		}
+		return BatchResult{Quote: &quote}
+	case BatchDelete:
+		return BatchResult{Err: s.Delete(ctx, quote.ID)}
+	}
+	return BatchResult{Err: domain.ErrInvalidInput}
+}
@@ -0,0 +1,325 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"math/rand/v2"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const cacheAllKey = "all"
+
+func cacheAuthorKey(author string) string {
+	return "author:" + author
+}
+
+// CacheStats - счетчики кэша с момента запуска
+type CacheStats struct {
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Evictions     int64 `json:"evictions"`
+	Invalidations int64 `json:"invalidations"`
+	Entries       int   `json:"entries"`
+}
+
+type cacheEntry struct {
+	key     string
+	quotes  []models.Quote
+	expires time.Time
+}
+
+// CachedQuerier - читающий кэш перед Querier. В памяти хранятся списки одобренных цитат:
+// все и по автору, не дольше ttl и не больше size штук, при переполнении вытесняется список,
+// который дольше всех не запрашивали. Случайная цитата выбирается из закэшированного списка всех цитат.
+// Запросы с явными статусами (модерация, администратор) идут мимо кэша.
+//
+// Create, Update и Delete сбрасывают только списки, которые они меняют. Одобрения модератором
+// и изменения, сделанные другими экземплярами сервиса, кэш узнает из событий (Publish),
+// а то, о чем событий нет, устаревает не дольше чем через ttl.
+//
+// Транзакции, открытые через Transactions, кэш видит: чтения внутри них идут мимо кэша,
+// потому что видят незафиксированные строки, а сбросы откладываются до фиксации.
+type CachedQuerier struct {
+	Querier
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// gen растет при каждом сбросе: список, загрузка которого началась раньше, не сохраняется
+	gen   uint64
+	loads singleflight.Group
+
+	hits          atomic.Int64
+	misses        atomic.Int64
+	evictions     atomic.Int64
+	invalidations atomic.Int64
+}
+
+func NewCachedQuerier(repo Querier, size int, ttl time.Duration) *CachedQuerier {
+	if size < 1 {
+		size = 1
+	}
+	return &CachedQuerier{
+		Querier: repo,
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+func (c *CachedQuerier) GetAll(ctx context.Context, statuses ...string) ([]models.Quote, error) {
+	if len(statuses) > 0 {
+		return c.Querier.GetAll(ctx, statuses...)
+	}
+	return c.cached(ctx, cacheAllKey, func(ctx context.Context) ([]models.Quote, error) {
+		return c.Querier.GetAll(ctx)
+	})
+}
+
+func (c *CachedQuerier) GetByAuthor(ctx context.Context, author string, statuses ...string) ([]models.Quote, error) {
+	if len(statuses) > 0 {
+		return c.Querier.GetByAuthor(ctx, author, statuses...)
+	}
+	return c.cached(ctx, cacheAuthorKey(author), func(ctx context.Context) ([]models.Quote, error) {
+		return c.Querier.GetByAuthor(ctx, author)
+	})
+}
+
+// GetRandom выбирает цитату из закэшированного списка всех одобренных цитат
+func (c *CachedQuerier) GetRandom(ctx context.Context, statuses ...string) (*models.Quote, error) {
+	if len(statuses) > 0 {
+		return c.Querier.GetRandom(ctx, statuses...)
+	}
+	quotes, err := c.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(quotes) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	quote := quotes[rand.IntN(len(quotes))]
+	return &quote, nil
+}
+
+func (c *CachedQuerier) Create(ctx context.Context, quote *models.Quote) error {
+	if err := c.Querier.Create(ctx, quote); err != nil {
+		return err
+	}
+	if quote.Status == "" || quote.Status == models.StatusApproved {
+		c.invalidateAfterCommit(ctx, 0, cacheAllKey, cacheAuthorKey(quote.Author))
+	}
+	return nil
+}
+
+// Update сбрасывает списки, где была цитата, и список нового автора
+func (c *CachedQuerier) Update(ctx context.Context, quote *models.Quote) error {
+	if err := c.Querier.Update(ctx, quote); err != nil {
+		return err
+	}
+	c.invalidateAfterCommit(ctx, quote.ID, cacheAuthorKey(quote.Author))
+	return nil
+}
+
+func (c *CachedQuerier) Delete(ctx context.Context, id int) error {
+	if err := c.Querier.Delete(ctx, id); err != nil {
+		return err
+	}
+	c.invalidateAfterCommit(ctx, id)
+	return nil
+}
+
+// Publish сбрасывает списки по событию. События своих же изменений повторяют сброс, это безопасно.
+func (c *CachedQuerier) Publish(ev Event) {
+	switch ev.Type {
+	case EventCreated, EventApproved:
+		c.invalidate(cacheAllKey, cacheAuthorKey(ev.Quote.Author))
+	case EventUpdated:
+		c.invalidateQuote(ev.Quote.ID, cacheAuthorKey(ev.Quote.Author))
+	case EventDeleted:
+		c.invalidateQuote(ev.Quote.ID)
+	}
+}
+
+func (c *CachedQuerier) Stats() CacheStats {
+	c.mu.Lock()
+	entries := c.lru.Len()
+	c.mu.Unlock()
+	return CacheStats{
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Evictions:     c.evictions.Load(),
+		Invalidations: c.invalidations.Load(),
+		Entries:       entries,
+	}
+}
+
+// cached возвращает копию списка из кэша или загружает его. Одновременные промахи
+// по одному ключу выполняют один запрос к БД. В открытой транзакции список читается из нее
+// и не сохраняется и не отдается другим запросам.
+func (c *CachedQuerier) cached(ctx context.Context, key string, load func(ctx context.Context) ([]models.Quote, error)) ([]models.Quote, error) {
+	if _, ok := ctx.Value(cacheTxKey{}).(*cacheTx); ok {
+		return load(ctx)
+	}
+	c.mu.Lock()
+	quotes, ok := c.get(key)
+	gen := c.gen
+	c.mu.Unlock()
+	if ok {
+		c.hits.Add(1)
+		return slices.Clone(quotes), nil
+	}
+	c.misses.Add(1)
+
+	// Поколение в ключе не дает присоединиться к загрузке, начатой до сброса
+	v, err, _ := c.loads.Do(key+"#"+strconv.FormatUint(gen, 10), func() (interface{}, error) {
+		quotes, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.put(key, quotes, gen)
+		return quotes, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(v.([]models.Quote)), nil
+}
+
+// get вызывается под c.mu
+func (c *CachedQuerier) get(key string) ([]models.Quote, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return entry.quotes, true
+}
+
+func (c *CachedQuerier) put(key string, quotes []models.Quote, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen {
+		return
+	}
+	entry := &cacheEntry{key: key, quotes: slices.Clone(quotes), expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// invalidateAfterCommit сбрасывает списки с цитатой id (0 - без нее) и списки keys сразу
+// или, в открытой транзакции, после ее фиксации
+func (c *CachedQuerier) invalidateAfterCommit(ctx context.Context, id int, keys ...string) {
+	if tx, ok := ctx.Value(cacheTxKey{}).(*cacheTx); ok {
+		tx.mu.Lock()
+		defer tx.mu.Unlock()
+		if id != 0 {
+			tx.ids = append(tx.ids, id)
+		}
+		tx.keys = append(tx.keys, keys...)
+		return
+	}
+	if id == 0 {
+		c.invalidate(keys...)
+		return
+	}
+	c.invalidateQuote(id, keys...)
+}
+
+// invalidate сбрасывает списки с указанными ключами
+func (c *CachedQuerier) invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drop(keys)
+}
+
+// invalidateQuote сбрасывает списки, в которых есть цитата id, и списки с ключами keys
+func (c *CachedQuerier) invalidateQuote(id int, keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, el := range c.entries {
+		if slices.ContainsFunc(el.Value.(*cacheEntry).quotes, func(q models.Quote) bool { return q.ID == id }) {
+			keys = append(keys, key)
+		}
+	}
+	c.drop(keys)
+}
+
+// drop вызывается под c.mu
+func (c *CachedQuerier) drop(keys []string) {
+	c.gen++
+	for _, key := range keys {
+		if el, ok := c.entries[key]; ok {
+			c.remove(el)
+			c.invalidations.Add(1)
+		}
+	}
+}
+
+// remove вызывается под c.mu
+func (c *CachedQuerier) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*cacheEntry).key)
+}
+
+// cacheTxKey - ключ контекста с отложенными сбросами открытой транзакции
+type cacheTxKey struct{}
+
+// cacheTx копит сбросы, сделанные в транзакции
+type cacheTx struct {
+	mu   sync.Mutex
+	ids  []int
+	keys []string
+}
+
+type cachedTransactor struct {
+	cache *CachedQuerier
+	tx    Transactor
+}
+
+// Transactions оборачивает tx так, что кэш знает об открытых транзакциях: изменения, сделанные в них,
+// сбрасывают кэш только после фиксации, а откаченные не сбрасывают его вовсе
+func (c *CachedQuerier) Transactions(tx Transactor) Transactor {
+	return &cachedTransactor{cache: c, tx: tx}
+}
+
+func (t *cachedTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(cacheTxKey{}).(*cacheTx); ok {
+		// точка сохранения: ее сбросы выполнит внешняя транзакция
+		return t.tx.InTx(ctx, fn)
+	}
+	pending := &cacheTx{}
+	if err := t.tx.InTx(context.WithValue(ctx, cacheTxKey{}, pending), fn); err != nil {
+		return err
+	}
+	for _, id := range pending.ids {
+		t.cache.invalidateQuote(id)
+	}
+	t.cache.invalidate(pending.keys...)
+	return nil
+}
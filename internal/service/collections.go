@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"quote-service/internal/auth"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"strings"
+)
+
+type CollectionRepository interface {
+	CreateCollection(ctx context.Context, c *models.Collection) error
+	GetCollections(ctx context.Context) ([]models.Collection, error)
+	GetCollection(ctx context.Context, id int) (*models.Collection, error)
+	UpdateCollection(ctx context.Context, c *models.Collection) error
+	DeleteCollection(ctx context.Context, id int) error
+	AddToCollection(ctx context.Context, id, quoteID int) error
+	RemoveFromCollection(ctx context.Context, id, quoteID int) error
+	ReorderCollection(ctx context.Context, id int, quoteIDs []int) error
+	GetRandomFromCollection(ctx context.Context, id int) (*models.Quote, error)
+	GetByIDs(ctx context.Context, ids []int) ([]models.Quote, error)
+}
+
+// CollectionService управляет подборками. Цитаты хранятся в подборке по ID,
+// поэтому удаление цитаты убирает ее из всех подборок.
+type CollectionService struct {
+	repo   CollectionRepository
+	policy CollectionPolicy
+}
+
+type CollectionOption func(*CollectionService)
+
+// WithCollectionPolicy включает проверку прав перед созданием и изменением подборок
+func WithCollectionPolicy(policy CollectionPolicy) CollectionOption {
+	return func(s *CollectionService) {
+		s.policy = policy
+	}
+}
+
+func NewCollectionService(repo CollectionRepository, opts ...CollectionOption) *CollectionService {
+	s := &CollectionService{repo: repo}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Create создает подборку, владельцем становится клиент из контекста
+func (s *CollectionService) Create(ctx context.Context, c *models.Collection) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" || !uniquePositiveIDs(c.QuoteIDs) {
+		return domain.ErrInvalidInput
+	}
+	if s.policy != nil {
+		if err := s.policy.AuthorizeCollection(ctx, ActionCreateCollection, nil); err != nil {
+			return err
+		}
+	}
+	c.OwnerID = ""
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		c.OwnerID = principal.ID
+	}
+	return s.repo.CreateCollection(ctx, c)
+}
+
+func (s *CollectionService) GetAll(ctx context.Context) ([]models.Collection, error) {
+	return s.repo.GetCollections(ctx)
+}
+
+// Get возвращает подборку вместе с цитатами в заданном порядке
+func (s *CollectionService) Get(ctx context.Context, id int) (*models.Collection, error) {
+	if id <= 0 {
+		return nil, domain.ErrInvalidInput
+	}
+	c, err := s.repo.GetCollection(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c.Quotes = []models.Quote{}
+	if len(c.QuoteIDs) == 0 {
+		return c, nil
+	}
+
+	quotes, err := s.repo.GetByIDs(ctx, c.QuoteIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]models.Quote, len(quotes))
+	for _, q := range quotes {
+		byID[q.ID] = q
+	}
+	for _, id := range c.QuoteIDs {
+		if q, ok := byID[id]; ok {
+			c.Quotes = append(c.Quotes, q)
+		}
+	}
+	return c, nil
+}
+
+func (s *CollectionService) Update(ctx context.Context, c *models.Collection) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.ID <= 0 || c.Name == "" {
+		return domain.ErrInvalidInput
+	}
+	if err := s.authorize(ctx, ActionUpdateCollection, c.ID); err != nil {
+		return err
+	}
+	return s.repo.UpdateCollection(ctx, c)
+}
+
+func (s *CollectionService) Delete(ctx context.Context, id int) error {
+	if id <= 0 {
+		return domain.ErrInvalidInput
+	}
+	if err := s.authorize(ctx, ActionDeleteCollection, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteCollection(ctx, id)
+}
+
+func (s *CollectionService) AddQuote(ctx context.Context, id, quoteID int) error {
+	if id <= 0 || quoteID <= 0 {
+		return domain.ErrInvalidInput
+	}
+	if err := s.authorize(ctx, ActionUpdateCollection, id); err != nil {
+		return err
+	}
+	return s.repo.AddToCollection(ctx, id, quoteID)
+}
+
+func (s *CollectionService) RemoveQuote(ctx context.Context, id, quoteID int) error {
+	if id <= 0 || quoteID <= 0 {
+		return domain.ErrInvalidInput
+	}
+	if err := s.authorize(ctx, ActionUpdateCollection, id); err != nil {
+		return err
+	}
+	return s.repo.RemoveFromCollection(ctx, id, quoteID)
+}
+
+// Reorder задает новый порядок цитат. Список должен состоять ровно из одобренных цитат подборки,
+// остальные сохраняют свои позиции.
+func (s *CollectionService) Reorder(ctx context.Context, id int, quoteIDs []int) error {
+	if id <= 0 || !uniquePositiveIDs(quoteIDs) {
+		return domain.ErrInvalidInput
+	}
+	c, err := s.repo.GetCollection(ctx, id)
+	if err != nil {
+		return err
+	}
+	if s.policy != nil {
+		if err := s.policy.AuthorizeCollection(ctx, ActionUpdateCollection, c); err != nil {
+			return err
+		}
+	}
+	if len(c.QuoteIDs) != len(quoteIDs) {
+		return domain.ErrInvalidInput
+	}
+	current := make(map[int]struct{}, len(c.QuoteIDs))
+	for _, qid := range c.QuoteIDs {
+		current[qid] = struct{}{}
+	}
+	for _, qid := range quoteIDs {
+		if _, ok := current[qid]; !ok {
+			return domain.ErrInvalidInput
+		}
+	}
+	return s.repo.ReorderCollection(ctx, id, quoteIDs)
+}
+
+func (s *CollectionService) GetRandom(ctx context.Context, id int) (*models.Quote, error) {
+	if id <= 0 {
+		return nil, domain.ErrInvalidInput
+	}
+	if _, err := s.repo.GetCollection(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRandomFromCollection(ctx, id)
+}
+
+// authorize загружает подборку, чтобы политика могла учесть ее владельца
+func (s *CollectionService) authorize(ctx context.Context, action string, id int) error {
+	if s.policy == nil {
+		return nil
+	}
+	c, err := s.repo.GetCollection(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.policy.AuthorizeCollection(ctx, action, c)
+}
+
+func uniquePositiveIDs(ids []int) bool {
+	seen := make(map[int]struct{}, len(ids))
+	for _, id := range ids {
+		if id <= 0 {
+			return false
+		}
+		if _, ok := seen[id]; ok {
+			return false
+		}
+		seen[id] = struct{}{}
+	}
+	return true
+}
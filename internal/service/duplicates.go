@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/pkg/textsim"
+	"sort"
+)
+
+const (
+	maxDuplicateCandidates = 5
+
+	// duplicateSearchLimit - сколько цитат с общими словами сравнивается с новой по триграммам
+	duplicateSearchLimit = 50
+)
+
+// DuplicateIndex отбирает по индексу слов цитаты, с которыми нужно сравнить новые
+type DuplicateIndex interface {
+	TermCandidates(ctx context.Context, terms [][]string, limit int, statuses ...string) ([][]models.Quote, error)
+}
+
+type CreateOption func(*createOptions)
+
+type createOptions struct {
+	allowDuplicates bool
+	// candidates - заранее загруженные кандидаты в дубликаты, если preloaded
+	candidates []models.Quote
+	preloaded  bool
+}
+
+// AllowDuplicates отключает проверку на почти совпадающие цитаты (force=true)
+func AllowDuplicates() CreateOption {
+	return func(o *createOptions) {
+		o.allowDuplicates = true
+	}
+}
+
+// withCandidates передает кандидатов в дубликаты, загруженных для всего пакета сразу
+func withCandidates(candidates []models.Quote) CreateOption {
+	return func(o *createOptions) {
+		o.candidates = candidates
+		o.preloaded = true
+	}
+}
+
+// WithDuplicateDetection отклоняет цитаты, у которых уже есть цитата того же автора, ожидающая модерации
+// или одобренная, с триграммным сходством текста не ниже threshold. Кандидаты отбираются по индексу слов.
+func WithDuplicateDetection(index DuplicateIndex, threshold float64) Option {
+	return func(s *QuoteService) {
+		s.duplicates = index
+		s.duplicateThreshold = threshold
+	}
+}
+
+// duplicateCandidates загружает для каждой цитаты кандидатов в дубликаты одним запросом к индексу.
+// Цитата без значимых слов сравнивается со всеми цитатами своего автора.
+func (s *QuoteService) duplicateCandidates(ctx context.Context, quotes []models.Quote) ([][]models.Quote, error) {
+	terms := make([][]string, len(quotes))
+	for i := range quotes {
+		terms[i] = termList(textsim.Terms(quotes[i].Quote))
+	}
+	candidates, err := s.duplicates.TermCandidates(ctx, terms, duplicateSearchLimit, models.StatusPending, models.StatusApproved)
+	if err != nil {
+		return nil, err
+	}
+	for i := range quotes {
+		if len(terms[i]) > 0 {
+			continue
+		}
+		if candidates[i], err = s.repo.GetByAuthor(ctx, quotes[i].Author, models.StatusPending, models.StatusApproved); err != nil {
+			return nil, err
+		}
+	}
+	return candidates, nil
+}
+
+// matchDuplicates возвращает ID цитат того же автора, похожих на quote, начиная с наиболее близкой
+func (s *QuoteService) matchDuplicates(quote models.Quote, candidates []models.Quote) []int {
+	type candidate struct {
+		id    int
+		score float64
+	}
+	author := textsim.Normalize(quote.Author)
+	grams := textsim.Trigrams(quote.Quote)
+	var matches []candidate
+	for _, q := range candidates {
+		if textsim.Normalize(q.Author) != author {
+			continue
+		}
+		score := textsim.Jaccard(grams, textsim.Trigrams(q.Quote))
+		if score >= s.duplicateThreshold {
+			matches = append(matches, candidate{id: q.ID, score: score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	if len(matches) > maxDuplicateCandidates {
+		matches = matches[:maxDuplicateCandidates]
+	}
+
+	ids := make([]int, len(matches))
+	for i, c := range matches {
+		ids[i] = c.id
+	}
+	return ids
+}
+
+func (s *QuoteService) duplicatesEnabled() bool {
+	return s.duplicates != nil && s.duplicateThreshold > 0
+}
+
+func (s *QuoteService) checkDuplicates(ctx context.Context, quote *models.Quote, o createOptions) error {
+	if !s.duplicatesEnabled() {
+		return nil
+	}
+	if o.preloaded {
+		return duplicateError(s.matchDuplicates(*quote, o.candidates))
+	}
+	candidates, err := s.duplicateCandidates(ctx, []models.Quote{*quote})
+	if err != nil {
+		return err
+	}
+	return duplicateError(s.matchDuplicates(*quote, candidates[0]))
+}
+
+func duplicateError(ids []int) error {
+	if len(ids) > 0 {
+		return &domain.DuplicateError{Candidates: ids}
+	}
+	return nil
+}
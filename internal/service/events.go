@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"quote-service/internal/models"
+	"sync"
+	"time"
+)
+
+// Типы событий изменения цитат
+const (
+	EventCreated  = models.EventCreated
+	EventDeleted  = models.EventDeleted
+	EventApproved = models.EventApproved
+	EventUpdated  = models.EventUpdated
+)
+
+// Event - изменение набора опубликованных цитат. Для deleted в Quote заполнен только ID.
+type Event struct {
+	ID    int64
+	Type  string
+	Quote models.Quote
+	Time  time.Time
+}
+
+// FeedType - тип события для лент (SSE, WebSocket): читатель видит одобрение как появление новой цитаты
+func (ev Event) FeedType() string {
+	if ev.Type == EventApproved {
+		return EventCreated
+	}
+	return ev.Type
+}
+
+// Internal сообщает, что событие нужно только кэшам: об изменении текста ленты
+// и вебхуки не сообщают
+func (ev Event) Internal() bool {
+	return ev.Type == EventUpdated
+}
+
+// EventPublisher получает события после успешной записи
+type EventPublisher interface {
+	Publish(ev Event)
+}
+
+// WithEvents включает рассылку событий; опцию можно передать несколько раз для разных получателей.
+// created отправляется при создании цитаты без модерации, approved - при одобрении модератором.
+func WithEvents(events EventPublisher) Option {
+	return func(s *QuoteService) {
+		s.events = append(s.events, events)
+	}
+}
+
+type pendingEventsKey struct{}
+
+// pendingEvents копит события транзакции, чтобы разослать их только после фиксации
+type pendingEvents struct {
+	events []Event
+}
+
+func withPendingEvents(ctx context.Context) (context.Context, *pendingEvents) {
+	pending := &pendingEvents{}
+	return context.WithValue(ctx, pendingEventsKey{}, pending), pending
+}
+
+// WithOutbox сообщает, что события пишет в журнал само хранилище в транзакции изменения.
+// QuoteService тогда их не рассылает: получателям их доставляет EventDispatcher, в том числе
+// об изменениях, сделанных другими экземплярами сервиса.
+func WithOutbox() Option {
+	return func(s *QuoteService) {
+		s.outbox = true
+	}
+}
+
+// eventsEnabled сообщает, рассылает ли события сам QuoteService
+func (s *QuoteService) eventsEnabled() bool {
+	return len(s.events) > 0 && !s.outbox
+}
+
+func (s *QuoteService) emit(ctx context.Context, typ string, quote models.Quote) {
+	if !s.eventsEnabled() {
+		return
+	}
+	ev := Event{Type: typ, Quote: quote, Time: s.now().UTC()}
+	if pending, ok := ctx.Value(pendingEventsKey{}).(*pendingEvents); ok {
+		pending.events = append(pending.events, ev)
+		return
+	}
+	s.publish(ev)
+}
+
+func (s *QuoteService) flushEvents(pending *pendingEvents) {
+	for _, ev := range pending.events {
+		s.publish(ev)
+	}
+}
+
+func (s *QuoteService) publish(ev Event) {
+	for _, events := range s.events {
+		events.Publish(ev)
+	}
+}
+
+// Subscription - подписка на события. C закрывается, если подписчик не успевает читать
+// или брокер остановлен; клиент может переподключиться с последним полученным ID.
+type Subscription struct {
+	C <-chan Event
+
+	ch     chan Event
+	broker *EventBroker
+	once   sync.Once
+}
+
+// Close отписывает подписчика
+func (sub *Subscription) Close() {
+	sub.broker.unsubscribe(sub)
+}
+
+// EventBroker хранит последние history событий для возобновления по Last-Event-ID и раздает их
+// всем подписчикам. События из журнала сохраняют его номера, остальные нумеруются по порядку.
+// Запись в канал подписчика не блокирует публикацию: подписчик с заполненным буфером отключается.
+type EventBroker struct {
+	clientBuffer int
+
+	mu     sync.Mutex
+	lastID int64
+	// lost - номер последнего события, которое уже нельзя вернуть из истории
+	lost    int64
+	history []Event
+	next    int
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+func NewEventBroker(history, clientBuffer int) *EventBroker {
+	if history < 1 {
+		history = 1
+	}
+	if clientBuffer < 1 {
+		clientBuffer = 1
+	}
+	return &EventBroker{
+		clientBuffer: clientBuffer,
+		history:      make([]Event, 0, history),
+		subs:         make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *EventBroker) Publish(ev Event) {
+	if ev.Internal() {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	switch {
+	case ev.ID == 0:
+		ev.ID = b.lastID + 1
+	case ev.ID <= b.lastID:
+		// событие журнала уже разослано
+		return
+	case b.lastID == 0:
+		// что было в журнале до первого события, брокер не знает
+		b.lost = ev.ID
+	}
+	b.lastID = ev.ID
+	if len(b.history) < cap(b.history) {
+		b.history = append(b.history, ev)
+	} else {
+		b.lost = b.history[b.next].ID
+		b.history[b.next] = ev
+		b.next = (b.next + 1) % cap(b.history)
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub.ch <- ev:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// Subscribe подписывает на события с ID больше lastID. Пропущенные события из истории
+// возвращаются отдельно; ok == false, если часть из них уже вытеснена или lastID неизвестен
+// (например, после перезапуска) - тогда клиенту нужно заново загрузить список.
+func (b *EventBroker) Subscribe(lastID int64) (sub *Subscription, missed []Event, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, b.clientBuffer)
+	sub = &Subscription{C: ch, ch: ch, broker: b}
+	if b.closed {
+		sub.once.Do(func() { close(ch) })
+		return sub, nil, true
+	}
+	b.subs[sub] = struct{}{}
+
+	if lastID <= 0 || lastID == b.lastID {
+		return sub, nil, true
+	}
+	if lastID > b.lastID {
+		return sub, nil, false
+	}
+	ok = lastID >= b.lost
+	for _, ev := range b.ordered() {
+		if ev.ID > lastID {
+			missed = append(missed, ev)
+		}
+	}
+	return sub, missed, ok
+}
+
+// Close отключает всех подписчиков, новые события больше не принимаются
+func (b *EventBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		b.drop(sub)
+	}
+}
+
+func (b *EventBroker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop(sub)
+}
+
+// drop вызывается под b.mu
+func (b *EventBroker) drop(sub *Subscription) {
+	delete(b.subs, sub)
+	sub.once.Do(func() { close(sub.ch) })
+}
+
+// ordered возвращает историю от старых событий к новым, вызывается под b.mu
+func (b *EventBroker) ordered() []Event {
+	events := make([]Event, 0, len(b.history))
+	events = append(events, b.history[b.next:]...)
+	return append(events, b.history[:b.next]...)
+}
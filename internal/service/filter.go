@@ -0,0 +1,224 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/pkg/textsim"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Коды нарушений правил проверки содержимого
+const (
+	ViolationTooShort   = "too_short"
+	ViolationTooLong    = "too_long"
+	ViolationBannedWord = "banned_word"
+	ViolationURL        = "url_not_allowed"
+	ViolationSpam       = "spam"
+)
+
+// ContentRule - шаг проверки цитаты перед сохранением. Правило может исправить цитату
+// (например, убрать управляющие символы) или вернуть нарушения.
+type ContentRule interface {
+	Apply(quote *models.Quote) []domain.Violation
+}
+
+// ContentRuleFunc позволяет использовать функцию как правило
+type ContentRuleFunc func(quote *models.Quote) []domain.Violation
+
+func (f ContentRuleFunc) Apply(quote *models.Quote) []domain.Violation {
+	return f(quote)
+}
+
+// WithContentFilter проверяет цитаты правилами по порядку при создании и изменении
+func WithContentFilter(rules ...ContentRule) Option {
+	return func(s *QuoteService) {
+		s.rules = append(s.rules, rules...)
+	}
+}
+
+// checkContent прогоняет цитату через все правила и собирает нарушения
+func (s *QuoteService) checkContent(quote *models.Quote, extra ...domain.Violation) error {
+	var violations []domain.Violation
+	for _, rule := range s.rules {
+		violations = append(violations, rule.Apply(quote)...)
+	}
+	violations = append(violations, extra...)
+	if len(violations) > 0 {
+		return &domain.ValidationError{Violations: violations}
+	}
+	return nil
+}
+
+// StripControlChars удаляет управляющие и невидимые символы форматирования, оставляя переводы строк и табуляцию
+func StripControlChars() ContentRule {
+	strip := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if r == '\n' || r == '\t' {
+				return r
+			}
+			if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+				return -1
+			}
+			return r
+		}, s)
+	}
+	return ContentRuleFunc(func(quote *models.Quote) []domain.Violation {
+		quote.Author = strings.TrimSpace(strip(quote.Author))
+		quote.Quote = strings.TrimSpace(strip(quote.Quote))
+		return nil
+	})
+}
+
+// LengthLimits ограничивает длину текста и автора в символах. Нулевое значение - без ограничения.
+type LengthLimits struct {
+	MinQuote  int
+	MaxQuote  int
+	MaxAuthor int
+}
+
+func (l LengthLimits) Apply(quote *models.Quote) []domain.Violation {
+	var violations []domain.Violation
+	n := utf8.RuneCountInString(quote.Quote)
+	if l.MinQuote > 0 && n < l.MinQuote {
+		violations = append(violations, domain.Violation{
+			Field: "quote", Code: ViolationTooShort,
+			Message: fmt.Sprintf("quote must be at least %d characters", l.MinQuote),
+		})
+	}
+	if l.MaxQuote > 0 && n > l.MaxQuote {
+		violations = append(violations, domain.Violation{
+			Field: "quote", Code: ViolationTooLong,
+			Message: fmt.Sprintf("quote must be at most %d characters", l.MaxQuote),
+		})
+	}
+	if l.MaxAuthor > 0 && utf8.RuneCountInString(quote.Author) > l.MaxAuthor {
+		violations = append(violations, domain.Violation{
+			Field: "author", Code: ViolationTooLong,
+			Message: fmt.Sprintf("author must be at most %d characters", l.MaxAuthor),
+		})
+	}
+	return violations
+}
+
+// BannedWords отклоняет цитаты, содержащие запрещенные слова. Сравнение идет после
+// нормализации (регистр, пунктуация, NFKC), поэтому "СЛОВО!" совпадает со "слово".
+type BannedWords struct {
+	words map[string]struct{}
+}
+
+func NewBannedWords(words ...string) *BannedWords {
+	b := &BannedWords{words: make(map[string]struct{}, len(words))}
+	for _, word := range words {
+		if word = textsim.Normalize(word); word != "" {
+			b.words[word] = struct{}{}
+		}
+	}
+	return b
+}
+
+// LoadBannedWords читает списки слов из файлов: одно слово на строку, строки с # пропускаются
+func LoadBannedWords(paths ...string) (*BannedWords, error) {
+	var words []string
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line != "" && !strings.HasPrefix(line, "#") {
+				words = append(words, line)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+	}
+	return NewBannedWords(words...), nil
+}
+
+func (b *BannedWords) Apply(quote *models.Quote) []domain.Violation {
+	var violations []domain.Violation
+	for _, field := range []struct{ name, text string }{{"author", quote.Author}, {"quote", quote.Quote}} {
+		for _, word := range strings.Fields(textsim.Normalize(field.text)) {
+			if _, ok := b.words[word]; ok {
+				violations = append(violations, domain.Violation{
+					Field: field.name, Code: ViolationBannedWord,
+					Message: field.name + " contains a banned word",
+				})
+				break
+			}
+		}
+	}
+	return violations
+}
+
+var urlPattern = regexp.MustCompile(`(?i)(https?://|www\.|\b[a-z0-9-]+\.(com|net|org|ru|io|info|biz|xyz|top|me|ly)\b)`)
+
+// NoURLs отклоняет ссылки в тексте и авторе
+func NoURLs() ContentRule {
+	return ContentRuleFunc(func(quote *models.Quote) []domain.Violation {
+		var violations []domain.Violation
+		if urlPattern.MatchString(quote.Author) {
+			violations = append(violations, domain.Violation{Field: "author", Code: ViolationURL, Message: "links are not allowed"})
+		}
+		if urlPattern.MatchString(quote.Quote) {
+			violations = append(violations, domain.Violation{Field: "quote", Code: ViolationURL, Message: "links are not allowed"})
+		}
+		return violations
+	})
+}
+
+// Пороги эвристик спама
+const (
+	spamCharRun     = 8   // один и тот же символ подряд
+	spamWordRepeats = 5   // одно слово повторяется не меньше раз...
+	spamWordShare   = 0.5 // ...и составляет не меньше этой доли текста
+)
+
+// NoSpam отклоняет явный мусор: длинные повторы одного символа и текст из одного повторяющегося слова
+func NoSpam() ContentRule {
+	return ContentRuleFunc(func(quote *models.Quote) []domain.Violation {
+		if hasCharRun(quote.Quote, spamCharRun) || hasWordFlood(quote.Quote) {
+			return []domain.Violation{{Field: "quote", Code: ViolationSpam, Message: "quote looks like spam"}}
+		}
+		return nil
+	})
+}
+
+func hasCharRun(s string, limit int) bool {
+	var prev rune
+	run := 0
+	for _, r := range s {
+		if r == prev && !unicode.IsSpace(r) {
+			run++
+		} else {
+			prev, run = r, 1
+		}
+		if run >= limit {
+			return true
+		}
+	}
+	return false
+}
+
+func hasWordFlood(s string) bool {
+	words := strings.Fields(textsim.Normalize(s))
+	counts := make(map[string]int, len(words))
+	for _, word := range words {
+		counts[word]++
+		if counts[word] >= spamWordRepeats && float64(counts[word]) >= spamWordShare*float64(len(words)) {
+			return true
+		}
+	}
+	return false
+}
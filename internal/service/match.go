@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"math/rand/v2"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// QuoteFilter отбирает опубликованные цитаты по автору и тегу. Пустое поле не ограничивает выборку.
+type QuoteFilter struct {
+	Author string
+	Tag    string
+}
+
+// Normalize приводит автора и тег к виду, в котором они хранятся
+func (f QuoteFilter) Normalize() QuoteFilter {
+	return QuoteFilter{
+		Author: strings.TrimSpace(norm.NFC.String(f.Author)),
+		Tag:    strings.ToLower(strings.TrimSpace(norm.NFC.String(f.Tag))),
+	}
+}
+
+func (f QuoteFilter) IsZero() bool {
+	return f.Author == "" && f.Tag == ""
+}
+
+// Matches проверяет цитату; для отбора по тегу у цитаты должны быть загружены теги
+func (f QuoteFilter) Matches(quote models.Quote) bool {
+	if f.Author != "" && quote.Author != f.Author {
+		return false
+	}
+	return f.Tag == "" || slices.Contains(quote.Tags, f.Tag)
+}
+
+// RandomMatching возвращает случайную одобренную цитату, подходящую под фильтр
+func (s *QuoteService) RandomMatching(ctx context.Context, filter QuoteFilter) (*models.Quote, error) {
+	filter = filter.Normalize()
+	if filter.IsZero() {
+		return s.GetRandom(ctx)
+	}
+	if filter.Tag != "" && s.loaders == nil {
+		return nil, domain.ErrNotFound
+	}
+
+	var (
+		candidates []models.Quote
+		err        error
+	)
+	if filter.Author != "" {
+		candidates, err = s.repo.GetByAuthor(ctx, filter.Author)
+	} else {
+		candidates, err = s.repo.GetAll(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if filter.Tag != "" {
+		if err := s.attachTags(ctx, candidates); err != nil {
+			return nil, err
+		}
+		var matching []models.Quote
+		for _, q := range candidates {
+			if filter.Matches(q) {
+				matching = append(matching, q)
+			}
+		}
+		candidates = matching
+	}
+	if len(candidates) == 0 {
+		return nil, domain.ErrNotFound
+	}
+	return s.served(ctx, &candidates[rand.IntN(len(candidates))], ViewSourceRandom)
+}
+
+// attachTags заполняет теги цитат одним запросом
+func (s *QuoteService) attachTags(ctx context.Context, quotes []models.Quote) error {
+	if s.loaders == nil || len(quotes) == 0 {
+		return nil
+	}
+	tags, err := s.loaders.TagsByQuoteIDs(ctx, quoteIDs(quotes))
+	if err != nil {
+		return err
+	}
+	for i := range quotes {
+		quotes[i].Tags = tags[quotes[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"quote-service/internal/auth"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"strings"
+)
+
+type ModerationRepository interface {
+	Moderate(ctx context.Context, id int, status, reason, moderator string) (*models.Quote, error)
+}
+
+// WithModeration отправляет цитаты от авторов без роли moderator в очередь на проверку
+func WithModeration(repo ModerationRepository) Option {
+	return func(s *QuoteService) {
+		s.moderation = repo
+	}
+}
+
+// Pending возвращает очередь цитат, ожидающих проверки
+func (s *QuoteService) Pending(ctx context.Context) ([]models.Quote, error) {
+	if err := s.authorize(ctx, ActionModerateQuote, nil); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAll(ctx, models.StatusPending)
+}
+
+// Approve публикует цитату, reason необязателен
+func (s *QuoteService) Approve(ctx context.Context, id int, reason string) (*models.Quote, error) {
+	return s.moderate(ctx, id, models.StatusApproved, reason)
+}
+
+// Reject отклоняет цитату, причина обязательна: ее увидит автор
+func (s *QuoteService) Reject(ctx context.Context, id int, reason string) (*models.Quote, error) {
+	if strings.TrimSpace(reason) == "" {
+		return nil, domain.ErrInvalidInput
+	}
+	return s.moderate(ctx, id, models.StatusRejected, reason)
+}
+
+// ModerationStatus возвращает статус проверки цитаты, чтобы автор мог его опрашивать.
+// Статус и причину отклонения неодобренной цитаты видят только ее автор и модераторы.
+func (s *QuoteService) ModerationStatus(ctx context.Context, id int) (*models.Quote, error) {
+	if id <= 0 {
+		return nil, domain.ErrInvalidInput
+	}
+	quote, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canSee(ctx, quote) {
+		return nil, domain.ErrNotFound
+	}
+	return quote, nil
+}
+
+func (s *QuoteService) moderate(ctx context.Context, id int, status, reason string) (*models.Quote, error) {
+	if s.moderation == nil {
+		return nil, domain.ErrNotFound
+	}
+	if id <= 0 {
+		return nil, domain.ErrInvalidInput
+	}
+	if err := s.authorize(ctx, ActionModerateQuote, nil); err != nil {
+		return nil, err
+	}
+	var moderator string
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		moderator = principal.ID
+	}
+	quote, err := s.moderation.Moderate(ctx, id, status, strings.TrimSpace(reason), moderator)
+	if err != nil {
+		return nil, err
+	}
+	if status == models.StatusApproved && s.eventsEnabled() {
+		// Подписчики отбирают события по тегам, поэтому событие несет их вместе с цитатой.
+		// Цитата уже одобрена: если теги не загрузились, событие уходит без них.
+		announced := []models.Quote{*quote}
+		_ = s.attachTags(ctx, announced)
+		s.emit(ctx, EventApproved, announced[0])
+	}
+	return quote, nil
+}
+
+// initialStatus - статус новой цитаты: модераторы публикуют сразу, остальные попадают в очередь
+func (s *QuoteService) initialStatus(ctx context.Context) string {
+	if s.moderation == nil {
+		return models.StatusApproved
+	}
+	if principal, ok := auth.PrincipalFrom(ctx); ok && principal.HasRole(auth.RoleModerator) {
+		return models.StatusApproved
+	}
+	return models.StatusPending
+}
+
+// editStatus - статус цитаты после правки: текст от автора без роли moderator проверяется заново,
+// иначе (пустая строка) статус не меняется
+func (s *QuoteService) editStatus(ctx context.Context) string {
+	if s.moderation == nil {
+		return ""
+	}
+	if principal, ok := auth.PrincipalFrom(ctx); ok && principal.HasRole(auth.RoleModerator) {
+		return ""
+	}
+	return models.StatusPending
+}
+
+// canSee сообщает, видна ли клиенту цитата: неодобренные видят только автор и модераторы
+func canSee(ctx context.Context, quote *models.Quote) bool {
+	if quote.Status == "" || quote.Status == models.StatusApproved {
+		return true
+	}
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return false
+	}
+	return principal.HasRole(auth.RoleModerator) || (quote.OwnerID != "" && quote.OwnerID == principal.ID)
+}
+
+// checkStatuses разрешает запрашивать неодобренные цитаты только администратору
+func (s *QuoteService) checkStatuses(ctx context.Context, statuses []string) error {
+	for _, status := range statuses {
+		if !models.ValidStatus(status) {
+			return domain.ErrInvalidInput
+		}
+		if status != models.StatusApproved {
+			return s.authorize(ctx, ActionListUnapproved, nil)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"quote-service/internal/models"
+	"quote-service/pkg/logger"
+	"time"
+)
+
+const (
+	outboxBatchSize = 100
+	// outboxGapTimeout - сколько ждать событие с пропущенным ID. Номера выдаются при вставке,
+	// а видны строки после фиксации, поэтому более раннее событие может появиться позже следующих.
+	// Если его транзакция откатилась, номер не появится никогда.
+	outboxGapTimeout = 5 * time.Second
+	// outboxPurgeInterval - как часто удалять из журнала события старше срока хранения
+	outboxPurgeInterval = time.Hour
+)
+
+// OutboxRepository читает журнал событий, который хранилище пополняет в транзакции каждого изменения цитат
+type OutboxRepository interface {
+	// DispatchedEventID возвращает номер последнего разосланного события
+	DispatchedEventID(ctx context.Context) (int64, error)
+	// SaveDispatchedEventID запоминает номер разосланного события
+	SaveDispatchedEventID(ctx context.Context, id int64) error
+	// EventsAfter возвращает до limit событий с номером больше afterID по возрастанию номера
+	EventsAfter(ctx context.Context, afterID int64, limit int) ([]models.OutboxEvent, error)
+	// PurgeEvents удаляет события старше olderThan
+	PurgeEvents(ctx context.Context, olderThan time.Duration) error
+}
+
+// EventNotifier сообщает о новых событиях в журнале (LISTEN/NOTIFY)
+type EventNotifier interface {
+	// Wait блокируется до уведомления. Ошибка означает, что уведомления могли быть потеряны.
+	Wait(ctx context.Context) error
+}
+
+// EventDispatcher раздает события журнала получателям внутри процесса: брокеру SSE и WebSocket,
+// вебхукам и т. п. Журнал общий для всех экземпляров сервиса, поэтому каждый из них узнает
+// и о чужих изменениях. Диспетчер просыпается по уведомлению, а на случай потерянных
+// уведомлений еще и перечитывает журнал каждые pollInterval.
+type EventDispatcher struct {
+	repo       OutboxRepository
+	notifier   EventNotifier
+	publishers []EventPublisher
+	now        func() time.Time
+
+	pollInterval time.Duration
+	retention    time.Duration
+
+	lastID    int64
+	gapSince  time.Time
+	lastPurge time.Time
+}
+
+type DispatcherOption func(*EventDispatcher)
+
+// WithDispatchInterval задает, как часто журнал перечитывается без уведомлений
+func WithDispatchInterval(interval time.Duration) DispatcherOption {
+	return func(d *EventDispatcher) {
+		d.pollInterval = interval
+	}
+}
+
+// WithEventRetention задает срок хранения событий в журнале; 0 отключает очистку
+func WithEventRetention(retention time.Duration) DispatcherOption {
+	return func(d *EventDispatcher) {
+		d.retention = retention
+	}
+}
+
+// NewEventDispatcher создает диспетчер. notifier может быть nil - тогда журнал только перечитывается.
+func NewEventDispatcher(repo OutboxRepository, notifier EventNotifier, publishers []EventPublisher, opts ...DispatcherOption) *EventDispatcher {
+	d := &EventDispatcher{
+		repo:         repo,
+		notifier:     notifier,
+		publishers:   publishers,
+		now:          time.Now,
+		pollInterval: 5 * time.Second,
+		retention:    24 * time.Hour,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Run раздает события, пока ctx не отменен. Раздача продолжается с сохраненной позиции журнала,
+// поэтому события, записанные, пока ни один диспетчер не работал, тоже доходят до получателей.
+// Событие, разосланное повторно после перезапуска, вебхуки в очередь второй раз не ставят.
+func (d *EventDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		lastID, err := d.repo.DispatchedEventID(ctx)
+		if err == nil {
+			d.lastID = lastID
+			break
+		}
+		logger.Errorf("Ошибка чтения журнала событий: %v", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+	d.lastPurge = d.now()
+
+	wake := make(chan struct{}, 1)
+	if d.notifier != nil {
+		listening := make(chan struct{})
+		go func() {
+			defer close(listening)
+			d.listen(ctx, wake)
+		}()
+		// Соединение для уведомлений освобождается до возврата из Run
+		defer func() { <-listening }()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+		for {
+			n, err := d.Dispatch(ctx)
+			if err != nil {
+				logger.Errorf("Ошибка рассылки событий: %v", err)
+			}
+			// Полная пачка означает, что в журнале могут быть еще события
+			if err != nil || n < outboxBatchSize {
+				break
+			}
+		}
+		d.purge(ctx)
+	}
+}
+
+// listen переводит уведомления в сигналы wake. После ошибки журнал все равно перечитывается:
+// уведомления, пришедшие, пока соединение было разорвано, потеряны.
+func (d *EventDispatcher) listen(ctx context.Context, wake chan<- struct{}) {
+	for {
+		err := d.notifier.Wait(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Errorf("Ошибка ожидания уведомлений о событиях: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(d.pollInterval):
+			}
+		}
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Dispatch раздает одну пачку новых событий, сохраняет позицию и возвращает число событий.
+// Раздача останавливается перед пропущенным номером, пока его ждать не дольше outboxGapTimeout.
+func (d *EventDispatcher) Dispatch(ctx context.Context) (int, error) {
+	events, err := d.repo.EventsAfter(ctx, d.lastID, outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	n := d.relay(events)
+	if n == 0 {
+		return 0, nil
+	}
+	return n, d.repo.SaveDispatchedEventID(ctx, d.lastID)
+}
+
+func (d *EventDispatcher) relay(events []models.OutboxEvent) int {
+	for i, ev := range events {
+		if ev.ID != d.lastID+1 && d.lastID > 0 {
+			if d.gapSince.IsZero() {
+				d.gapSince = d.now()
+			}
+			if d.now().Sub(d.gapSince) < outboxGapTimeout {
+				return i
+			}
+		}
+		d.gapSince = time.Time{}
+		d.lastID = ev.ID
+		d.publish(Event{ID: ev.ID, Type: ev.Type, Quote: ev.Quote, Time: ev.CreatedAt.UTC()})
+	}
+	return len(events)
+}
+
+func (d *EventDispatcher) publish(ev Event) {
+	for _, p := range d.publishers {
+		p.Publish(ev)
+	}
+}
+
+func (d *EventDispatcher) purge(ctx context.Context) {
+	if d.retention <= 0 || d.now().Sub(d.lastPurge) < outboxPurgeInterval {
+		return
+	}
+	d.lastPurge = d.now()
+	if err := d.repo.PurgeEvents(ctx, d.retention); err != nil {
+		logger.Errorf("Ошибка очистки журнала событий: %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"quote-service/internal/auth"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+)
+
+// Действия, которые проверяет политика доступа
+const (
+	ActionCreateQuote = "quote.create"
+	ActionUpdateQuote = "quote.update"
+	ActionDeleteQuote = "quote.delete"
+	// ActionModerateQuote - просмотр очереди, одобрение и отклонение цитат
+	ActionModerateQuote = "quote.moderate"
+	// ActionListUnapproved - выборка неодобренных цитат через обычные списки
+	ActionListUnapproved = "quote.list_unapproved"
+	ActionManageKeys     = "keys.manage"
+	ActionManageWebhooks = "webhooks.manage"
+
+	ActionCreateCollection = "collection.create"
+	// ActionUpdateCollection - изменение подборки и ее состава и порядка цитат
+	ActionUpdateCollection = "collection.update"
+	ActionDeleteCollection = "collection.delete"
+)
+
+// Policy решает, может ли клиент из контекста выполнить действие.
+// quote - цитата, над которой выполняется действие, nil для создания и действий без цитаты.
+type Policy interface {
+	Authorize(ctx context.Context, action string, quote *models.Quote) error
+}
+
+// CollectionPolicy решает, может ли клиент из контекста изменить подборку.
+// c - подборка, над которой выполняется действие, nil для создания.
+type CollectionPolicy interface {
+	AuthorizeCollection(ctx context.Context, action string, c *models.Collection) error
+}
+
+// RolePolicy - политика на ролях: автор создает цитаты и подборки и правит свои,
+// модератор правит и удаляет любые, администратор управляет ключами и вебхуками
+type RolePolicy struct{}
+
+func (RolePolicy) Authorize(ctx context.Context, action string, quote *models.Quote) error {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return domain.ErrUnauthorized
+	}
+
+	switch action {
+	case ActionCreateQuote:
+		if principal.HasRole(auth.RoleContributor) {
+			return nil
+		}
+		return forbidden(action, "contributor role required")
+	case ActionUpdateQuote:
+		if principal.HasRole(auth.RoleModerator) {
+			return nil
+		}
+		if principal.HasRole(auth.RoleContributor) && quote != nil && quote.OwnerID == principal.ID {
+			return nil
+		}
+		return forbidden(action, "only the owner or a moderator can edit this quote")
+	case ActionDeleteQuote, ActionModerateQuote:
+		if principal.HasRole(auth.RoleModerator) {
+			return nil
+		}
+		return forbidden(action, "moderator role required")
+	case ActionListUnapproved, ActionManageKeys, ActionManageWebhooks:
+		if principal.HasRole(auth.RoleAdmin) {
+			return nil
+		}
+		return forbidden(action, "admin role required")
+	}
+	return forbidden(action, "unknown action")
+}
+
+func (RolePolicy) AuthorizeCollection(ctx context.Context, action string, c *models.Collection) error {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return domain.ErrUnauthorized
+	}
+
+	switch action {
+	case ActionCreateCollection:
+		if principal.HasRole(auth.RoleContributor) {
+			return nil
+		}
+		return forbidden(action, "contributor role required")
+	case ActionUpdateCollection, ActionDeleteCollection:
+		if principal.HasRole(auth.RoleModerator) {
+			return nil
+		}
+		if principal.HasRole(auth.RoleContributor) && c != nil && c.OwnerID == principal.ID {
+			return nil
+		}
+		return forbidden(action, "only the owner or a moderator can change this collection")
+	}
+	return forbidden(action, "unknown action")
+}
+
+func forbidden(action, reason string) error {
+	return &domain.ForbiddenError{Action: action, Reason: reason}
+}
@@ -2,54 +2,245 @@ package service
 
 import (
 	"context"
+	"quote-service/internal/auth"
 	"quote-service/internal/domain"
 	"quote-service/internal/models"
+	"strings"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 type Querier interface {
 	Create(ctx context.Context, quote *models.Quote) error
-	GetAll(ctx context.Context) ([]models.Quote, error)
-	GetRandom(ctx context.Context) (*models.Quote, error)
-	GetByAuthor(ctx context.Context, author string) ([]models.Quote, error)
+	// GetAll, GetRandom и GetByAuthor без статусов возвращают только одобренные цитаты
+	GetAll(ctx context.Context, statuses ...string) ([]models.Quote, error)
+	GetRandom(ctx context.Context, statuses ...string) (*models.Quote, error)
+	GetByID(ctx context.Context, id int) (*models.Quote, error)
+	GetDaily(ctx context.Context, day time.Time) (*models.Quote, error)
+	GetByAuthor(ctx context.Context, author string, statuses ...string) ([]models.Quote, error)
+	Update(ctx context.Context, quote *models.Quote) error
 	Delete(ctx context.Context, id int) error
 	Exists(ctx context.Context, author, quote string) (bool, error)
 }
 
 type QuoteService struct {
-	repo Querier
+	repo   Querier
+	stats  RatingRepository
+	views  *ViewCounter
+	terms  TermIndex
+	policy Policy
+	now    func() time.Time
+
+	moderation ModerationRepository
+	rules      []ContentRule
+	tx         Transactor
+	loaders    LoaderRepository
+	events     []EventPublisher
+	outbox     bool
+
+	duplicates         DuplicateIndex
+	duplicateThreshold float64
+}
+
+type Option func(*QuoteService)
+
+// WithRatingStats подмешивает в выдаваемые цитаты средний рейтинг и число лайков
+func WithRatingStats(repo RatingRepository) Option {
+	return func(s *QuoteService) {
+		s.stats = repo
+	}
+}
+
+// WithViewCounter включает подсчет выдач цитат через GetByID, GetRandom и GetDaily
+func WithViewCounter(views *ViewCounter) Option {
+	return func(s *QuoteService) {
+		s.views = views
+	}
+}
+
+// WithPolicy включает проверку прав перед созданием, изменением и удалением цитат
+func WithPolicy(policy Policy) Option {
+	return func(s *QuoteService) {
+		s.policy = policy
+	}
 }
 
-func NewQuoteService(repo Querier) *QuoteService {
-	return &QuoteService{repo: repo}
+func NewQuoteService(repo Querier, opts ...Option) *QuoteService {
+	s := &QuoteService{repo: repo, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func (s *QuoteService) Create(ctx context.Context, quote *models.Quote) error {
+func (s *QuoteService) Create(ctx context.Context, quote *models.Quote, opts ...CreateOption) error {
+	var o createOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	normalize(quote)
+	tags, tagViolations := normalizeTags(quote.Tags)
+	quote.Tags = tags
+	if err := s.checkContent(quote, tagViolations...); err != nil {
+		return err
+	}
 	if quote.Author == "" || quote.Quote == "" {
 		return domain.ErrInvalidInput
 	}
-	return s.repo.Create(ctx, quote)
+	if err := s.authorize(ctx, ActionCreateQuote, nil); err != nil {
+		return err
+	}
+	quote.OwnerID = ""
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		quote.OwnerID = principal.ID
+	}
+	quote.Status = s.initialStatus(ctx)
+	quote.ModerationReason = ""
+	if !o.allowDuplicates {
+		if err := s.checkDuplicates(ctx, quote, o); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.Create(ctx, quote); err != nil {
+		return err
+	}
+	if quote.Status == models.StatusApproved {
+		s.emit(ctx, EventCreated, *quote)
+	}
+	return nil
 }
 
-func (s *QuoteService) GetAll(ctx context.Context) ([]models.Quote, error) {
-	return s.repo.GetAll(ctx)
+// GetAll возвращает одобренные цитаты. Администратор может запросить цитаты с другими статусами.
+func (s *QuoteService) GetAll(ctx context.Context, statuses ...string) ([]models.Quote, error) {
+	if err := s.checkStatuses(ctx, statuses); err != nil {
+		return nil, err
+	}
+	quotes, err := s.repo.GetAll(ctx, statuses...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enrich(ctx, quotes); err != nil {
+		return nil, err
+	}
+	return quotes, nil
+}
+
+func (s *QuoteService) GetRandom(ctx context.Context, statuses ...string) (*models.Quote, error) {
+	if err := s.checkStatuses(ctx, statuses); err != nil {
+		return nil, err
+	}
+	quote, err := s.repo.GetRandom(ctx, statuses...)
+	if err != nil {
+		return nil, err
+	}
+	return s.served(ctx, quote, ViewSourceRandom)
+}
+
+func (s *QuoteService) GetByID(ctx context.Context, id int) (*models.Quote, error) {
+	if id <= 0 {
+		return nil, domain.ErrInvalidInput
+	}
+	quote, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !canSee(ctx, quote) {
+		return nil, domain.ErrNotFound
+	}
+	return s.served(ctx, quote, ViewSourceGet)
 }
 
-func (s *QuoteService) GetRandom(ctx context.Context) (*models.Quote, error) {
-	return s.repo.GetRandom(ctx)
+// GetDaily возвращает цитату дня, одну и ту же в течение суток по UTC
+func (s *QuoteService) GetDaily(ctx context.Context) (*models.Quote, error) {
+	quote, err := s.repo.GetDaily(ctx, s.now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return s.served(ctx, quote, ViewSourceDaily)
 }
 
-func (s *QuoteService) GetByAuthor(ctx context.Context, author string) ([]models.Quote, error) {
+// MostViewed возвращает самые часто выдаваемые цитаты
+func (s *QuoteService) MostViewed(ctx context.Context, source string, limit int) ([]models.Quote, error) {
+	if s.views == nil {
+		return nil, domain.ErrNotFound
+	}
+	quotes, err := s.views.MostViewed(ctx, source, limit)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachStats(ctx, quotes); err != nil {
+		return nil, err
+	}
+	return quotes, nil
+}
+
+func (s *QuoteService) GetByAuthor(ctx context.Context, author string, statuses ...string) ([]models.Quote, error) {
 	if author == "" {
 		return nil, domain.ErrInvalidInput
 	}
-	return s.repo.GetByAuthor(ctx, author)
+	if err := s.checkStatuses(ctx, statuses); err != nil {
+		return nil, err
+	}
+	quotes, err := s.repo.GetByAuthor(ctx, author, statuses...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enrich(ctx, quotes); err != nil {
+		return nil, err
+	}
+	return quotes, nil
+}
+
+// Update меняет автора и текст цитаты. Владелец цитаты не меняется.
+func (s *QuoteService) Update(ctx context.Context, quote *models.Quote) error {
+	if quote.ID <= 0 {
+		return domain.ErrInvalidInput
+	}
+	normalize(quote)
+	if err := s.checkContent(quote); err != nil {
+		return err
+	}
+	if quote.Author == "" || quote.Quote == "" {
+		return domain.ErrInvalidInput
+	}
+	var previous *models.Quote
+	if s.policy != nil || s.moderation != nil {
+		var err error
+		if previous, err = s.repo.GetByID(ctx, quote.ID); err != nil {
+			return err
+		}
+		if err := s.authorize(ctx, ActionUpdateQuote, previous); err != nil {
+			return err
+		}
+	}
+	quote.Status = s.editStatus(ctx)
+	if err := s.repo.Update(ctx, quote); err != nil {
+		return err
+	}
+	switch {
+	case quote.Status == "" || quote.Status == models.StatusApproved:
+		s.emit(ctx, EventUpdated, *quote)
+	case previous != nil && previous.Status == models.StatusApproved:
+		// Правка снимает цитату с публикации до проверки: для читателей она удалена
+		s.emit(ctx, EventDeleted, models.Quote{ID: quote.ID})
+	}
+	return nil
 }
 
 func (s *QuoteService) Delete(ctx context.Context, id int) error {
 	if id <= 0 {
 		return domain.ErrInvalidInput
 	}
-	return s.repo.Delete(ctx, id)
+	if err := s.authorizeQuote(ctx, ActionDeleteQuote, id); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.emit(ctx, EventDeleted, models.Quote{ID: id})
+	return nil
 }
 
 func (s *QuoteService) Exists(ctx context.Context, author, quote string) (bool, error) {
@@ -58,3 +249,84 @@ func (s *QuoteService) Exists(ctx context.Context, author, quote string) (bool,
 	}
 	return s.repo.Exists(ctx, author, quote)
 }
+
+func (s *QuoteService) authorize(ctx context.Context, action string, quote *models.Quote) error {
+	if s.policy == nil {
+		return nil
+	}
+	return s.policy.Authorize(ctx, action, quote)
+}
+
+// authorizeQuote загружает цитату, чтобы политика могла учесть ее владельца
+func (s *QuoteService) authorizeQuote(ctx context.Context, action string, id int) error {
+	if s.policy == nil {
+		return nil
+	}
+	quote, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.policy.Authorize(ctx, action, quote)
+}
+
+func normalize(quote *models.Quote) {
+	quote.Author = strings.TrimSpace(norm.NFC.String(quote.Author))
+	quote.Quote = strings.TrimSpace(norm.NFC.String(quote.Quote))
+}
+
+// served учитывает выдачу цитаты и дополняет ее агрегатами
+func (s *QuoteService) served(ctx context.Context, quote *models.Quote, source string) (*models.Quote, error) {
+	if s.views != nil {
+		s.views.Record(quote.ID, source)
+	}
+	quotes := []models.Quote{*quote}
+	if err := s.enrich(ctx, quotes); err != nil {
+		return nil, err
+	}
+	return &quotes[0], nil
+}
+
+// enrich дополняет цитаты оценками и счетчиками выдач
+func (s *QuoteService) enrich(ctx context.Context, quotes []models.Quote) error {
+	if err := s.attachStats(ctx, quotes); err != nil {
+		return err
+	}
+	return s.attachViews(ctx, quotes)
+}
+
+func (s *QuoteService) attachViews(ctx context.Context, quotes []models.Quote) error {
+	if s.views == nil || len(quotes) == 0 {
+		return nil
+	}
+	views, err := s.views.Stats(ctx, quoteIDs(quotes))
+	if err != nil {
+		return err
+	}
+	for i := range quotes {
+		quotes[i].Views = views[quotes[i].ID]
+	}
+	return nil
+}
+
+// attachStats заполняет агрегаты оценок одним запросом на всю выборку
+func (s *QuoteService) attachStats(ctx context.Context, quotes []models.Quote) error {
+	if s.stats == nil || len(quotes) == 0 {
+		return nil
+	}
+	stats, err := s.stats.Stats(ctx, quoteIDs(quotes))
+	if err != nil {
+		return err
+	}
+	for i := range quotes {
+		quotes[i].SetStats(stats[quotes[i].ID])
+	}
+	return nil
+}
+
+func quoteIDs(quotes []models.Quote) []int {
+	ids := make([]int, len(quotes))
+	for i := range quotes {
+		ids[i] = quotes[i].ID
+	}
+	return ids
+}
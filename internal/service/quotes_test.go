@@ -2,8 +2,20 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"quote-service/internal/auth"
 	"quote-service/internal/domain"
 	"quote-service/internal/models"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -20,21 +32,36 @@ func (m *MockQuerier) Create(ctx context.Context, quote *models.Quote) error {
 	return args.Error(0)
 }
 
-func (m *MockQuerier) GetAll(ctx context.Context) ([]models.Quote, error) {
-	args := m.Called(ctx)
+func (m *MockQuerier) GetAll(ctx context.Context, statuses ...string) ([]models.Quote, error) {
+	args := m.Called(ctx, statuses)
 	return args.Get(0).([]models.Quote), args.Error(1)
 }
 
-func (m *MockQuerier) GetRandom(ctx context.Context) (*models.Quote, error) {
-	args := m.Called(ctx)
+func (m *MockQuerier) GetRandom(ctx context.Context, statuses ...string) (*models.Quote, error) {
+	args := m.Called(ctx, statuses)
+	return args.Get(0).(*models.Quote), args.Error(1)
+}
+
+func (m *MockQuerier) GetByID(ctx context.Context, id int) (*models.Quote, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Quote), args.Error(1)
+}
+
+func (m *MockQuerier) GetDaily(ctx context.Context, day time.Time) (*models.Quote, error) {
+	args := m.Called(ctx, day)
 	return args.Get(0).(*models.Quote), args.Error(1)
 }
 
-func (m *MockQuerier) GetByAuthor(ctx context.Context, author string) ([]models.Quote, error) {
-	args := m.Called(ctx, author)
+func (m *MockQuerier) GetByAuthor(ctx context.Context, author string, statuses ...string) ([]models.Quote, error) {
+	args := m.Called(ctx, author, statuses)
 	return args.Get(0).([]models.Quote), args.Error(1)
 }
 
+func (m *MockQuerier) Update(ctx context.Context, quote *models.Quote) error {
+	args := m.Called(ctx, quote)
+	return args.Error(0)
+}
+
 func (m *MockQuerier) Delete(ctx context.Context, id int) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
@@ -66,6 +93,25 @@ func TestQuoteService_Create(t *testing.T) {
 		err := service.Create(context.Background(), invalidQuote)
 		assert.ErrorIs(t, err, domain.ErrInvalidInput)
 	})
+
+	t.Run("tags normalized", func(t *testing.T) {
+		tagged := &models.Quote{Author: "Seneca", Quote: "Luck is preparation", Tags: []string{" Wisdom", "luck", "wisdom", ""}}
+		mockRepo.On("Create", mock.Anything, tagged).Return(nil).Once()
+
+		assert.NoError(t, service.Create(context.Background(), tagged))
+		assert.Equal(t, []string{"luck", "wisdom"}, tagged.Tags)
+	})
+
+	t.Run("too many tags", func(t *testing.T) {
+		tagged := &models.Quote{Author: "Seneca", Quote: "Luck is preparation"}
+		for i := 0; i <= maxTags; i++ {
+			tagged.Tags = append(tagged.Tags, fmt.Sprintf("tag%d", i))
+		}
+		var validationErr *domain.ValidationError
+		if assert.ErrorAs(t, service.Create(context.Background(), tagged), &validationErr) {
+			assert.Equal(t, "tags", validationErr.Violations[0].Field)
+		}
+	})
 }
 
 func TestQuoteService_GetAll(t *testing.T) {
@@ -76,7 +122,7 @@ func TestQuoteService_GetAll(t *testing.T) {
 		{ID: 1, Author: "Confucius", Quote: "Life is simple", CreatedAt: time.Now()},
 	}
 
-	mockRepo.On("GetAll", mock.Anything).Return(quotes, nil).Once()
+	mockRepo.On("GetAll", mock.Anything, mock.Anything).Return(quotes, nil).Once()
 
 	result, err := service.GetAll(context.Background())
 	assert.NoError(t, err)
@@ -89,7 +135,7 @@ func TestQuoteService_GetRandom(t *testing.T) {
 	service := NewQuoteService(mockRepo)
 
 	quote := &models.Quote{ID: 1, Author: "Confucius", Quote: "Life is simple", CreatedAt: time.Now()}
-	mockRepo.On("GetRandom", mock.Anything).Return(quote, nil).Once()
+	mockRepo.On("GetRandom", mock.Anything, mock.Anything).Return(quote, nil).Once()
 
 	result, err := service.GetRandom(context.Background())
 	assert.NoError(t, err)
@@ -105,7 +151,7 @@ func TestQuoteService_GetByAuthor(t *testing.T) {
 	}
 
 	t.Run("valid author", func(t *testing.T) {
-		mockRepo.On("GetByAuthor", mock.Anything, "Confucius").Return(quotes, nil).Once()
+		mockRepo.On("GetByAuthor", mock.Anything, "Confucius", mock.Anything).Return(quotes, nil).Once()
 
 		result, err := service.GetByAuthor(context.Background(), "Confucius")
 		assert.NoError(t, err)
@@ -154,3 +200,1773 @@ func TestQuoteService_Exists(t *testing.T) {
 		assert.False(t, exists)
 	})
 }
+
+type MockRatingRepository struct {
+	mock.Mock
+}
+
+func (m *MockRatingRepository) Rate(ctx context.Context, quoteID int, userID string, rating int) error {
+	args := m.Called(ctx, quoteID, userID, rating)
+	return args.Error(0)
+}
+
+func (m *MockRatingRepository) Like(ctx context.Context, quoteID int, userID string) error {
+	args := m.Called(ctx, quoteID, userID)
+	return args.Error(0)
+}
+
+func (m *MockRatingRepository) Unlike(ctx context.Context, quoteID int, userID string) error {
+	args := m.Called(ctx, quoteID, userID)
+	return args.Error(0)
+}
+
+func (m *MockRatingRepository) Stats(ctx context.Context, ids []int) (map[int]models.RatingStats, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).(map[int]models.RatingStats), args.Error(1)
+}
+
+func (m *MockRatingRepository) Top(ctx context.Context, since time.Time, limit int) ([]models.Quote, error) {
+	args := m.Called(ctx, since, limit)
+	return args.Get(0).([]models.Quote), args.Error(1)
+}
+
+func TestRatingService_Rate(t *testing.T) {
+	mockRepo := new(MockRatingRepository)
+	mockQuotes := new(MockQuerier)
+	service := NewRatingService(mockRepo, mockQuotes)
+
+	t.Run("successful rate", func(t *testing.T) {
+		stats := map[int]models.RatingStats{1: {AvgRating: 4, RatingsCount: 1}}
+		mockQuotes.On("GetByID", mock.Anything, 1).Return(&models.Quote{ID: 1, Status: models.StatusApproved}, nil).Once()
+		mockRepo.On("Rate", mock.Anything, 1, "user-1", 4).Return(nil).Once()
+		mockRepo.On("Stats", mock.Anything, []int{1}).Return(stats, nil).Once()
+
+		result, err := service.Rate(context.Background(), 1, "user-1", 4)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.RatingsCount)
+	})
+
+	t.Run("rating out of range", func(t *testing.T) {
+		_, err := service.Rate(context.Background(), 1, "user-1", 6)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("missing user", func(t *testing.T) {
+		_, err := service.Rate(context.Background(), 1, "", 3)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("pending quote is hidden", func(t *testing.T) {
+		pending := &models.Quote{ID: 2, Status: models.StatusPending, OwnerID: "key:1"}
+		mockQuotes.On("GetByID", mock.Anything, 2).Return(pending, nil).Twice()
+
+		_, err := service.Rate(context.Background(), 2, "key:2", 5)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		_, err = service.Like(context.Background(), 2, "key:2")
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		mockRepo.AssertNotCalled(t, "Rate", mock.Anything, 2, mock.Anything, mock.Anything)
+		mockRepo.AssertNotCalled(t, "Like", mock.Anything, 2, mock.Anything)
+	})
+
+	t.Run("owner rates own pending quote", func(t *testing.T) {
+		pending := &models.Quote{ID: 2, Status: models.StatusPending, OwnerID: "key:1"}
+		owner := auth.WithPrincipal(context.Background(), &auth.Principal{ID: "key:1", Roles: []string{auth.RoleContributor}})
+		mockQuotes.On("GetByID", mock.Anything, 2).Return(pending, nil).Once()
+		mockRepo.On("Rate", mock.Anything, 2, "key:1", 5).Return(nil).Once()
+		mockRepo.On("Stats", mock.Anything, []int{2}).Return(map[int]models.RatingStats{2: {AvgRating: 5, RatingsCount: 1}}, nil).Once()
+
+		_, err := service.Rate(owner, 2, "key:1", 5)
+		assert.NoError(t, err)
+	})
+}
+
+func TestRatingService_Top(t *testing.T) {
+	mockRepo := new(MockRatingRepository)
+	service := NewRatingService(mockRepo, new(MockQuerier))
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	t.Run("week", func(t *testing.T) {
+		mockRepo.On("Top", mock.Anything, now.Add(-7*24*time.Hour), defaultTopLimit).Return([]models.Quote{{ID: 1}}, nil).Once()
+
+		result, err := service.Top(context.Background(), "week", 0)
+		assert.NoError(t, err)
+		assert.Len(t, result, 1)
+	})
+
+	t.Run("unknown period", func(t *testing.T) {
+		_, err := service.Top(context.Background(), "decade", 0)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+}
+
+func TestQuoteService_GetAllWithStats(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	mockRatings := new(MockRatingRepository)
+	service := NewQuoteService(mockRepo, WithRatingStats(mockRatings))
+
+	quotes := []models.Quote{{ID: 1, Author: "Confucius", Quote: "Life is simple"}}
+	mockRepo.On("GetAll", mock.Anything, mock.Anything).Return(quotes, nil).Once()
+	mockRatings.On("Stats", mock.Anything, []int{1}).Return(map[int]models.RatingStats{1: {AvgRating: 4.5, RatingsCount: 2, Likes: 3}}, nil).Once()
+
+	result, err := service.GetAll(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 4.5, result[0].AvgRating)
+	assert.Equal(t, 3, result[0].Likes)
+}
+
+type MockViewRepository struct {
+	mock.Mock
+}
+
+func (m *MockViewRepository) FlushViews(ctx context.Context, deltas map[int]models.ViewStats) error {
+	args := m.Called(ctx, deltas)
+	return args.Error(0)
+}
+
+func (m *MockViewRepository) ViewStats(ctx context.Context, ids []int) (map[int]models.ViewStats, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).(map[int]models.ViewStats), args.Error(1)
+}
+
+func (m *MockViewRepository) MostViewed(ctx context.Context, source string, limit int) ([]models.Quote, error) {
+	args := m.Called(ctx, source, limit)
+	return args.Get(0).([]models.Quote), args.Error(1)
+}
+
+func TestViewCounter_Flush(t *testing.T) {
+	mockRepo := new(MockViewRepository)
+	counter := NewViewCounter(mockRepo)
+
+	counter.Record(1, ViewSourceGet)
+	counter.Record(1, ViewSourceRandom)
+	counter.Record(2, ViewSourceDaily)
+
+	t.Run("failed flush keeps counters", func(t *testing.T) {
+		mockRepo.On("FlushViews", mock.Anything, mock.Anything).Return(assert.AnError).Once()
+
+		err := counter.Flush(context.Background())
+		assert.Error(t, err)
+	})
+
+	t.Run("successful flush", func(t *testing.T) {
+		expected := map[int]models.ViewStats{
+			1: {Get: 1, Random: 1},
+			2: {Daily: 1},
+		}
+		mockRepo.On("FlushViews", mock.Anything, expected).Return(nil).Once()
+
+		err := counter.Flush(context.Background())
+		assert.NoError(t, err)
+	})
+
+	t.Run("nothing to flush", func(t *testing.T) {
+		err := counter.Flush(context.Background())
+		assert.NoError(t, err)
+		mockRepo.AssertNumberOfCalls(t, "FlushViews", 2)
+	})
+}
+
+func TestViewCounter_RunWithoutInterval(t *testing.T) {
+	mockRepo := new(MockViewRepository)
+	counter := NewViewCounter(mockRepo)
+	counter.Record(1, ViewSourceGet)
+	mockRepo.On("FlushViews", mock.Anything, map[int]models.ViewStats{1: {Get: 1}}).Return(nil).Once()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Нулевой интервал из конфигурации не должен ронять сервис
+	assert.NotPanics(t, func() { counter.Run(ctx, 0) })
+	mockRepo.AssertExpectations(t)
+}
+
+func TestQuoteService_GetByIDCountsViews(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	mockViews := new(MockViewRepository)
+	counter := NewViewCounter(mockViews)
+	service := NewQuoteService(mockRepo, WithViewCounter(counter))
+
+	quote := &models.Quote{ID: 1, Author: "Confucius", Quote: "Life is simple"}
+	mockRepo.On("GetByID", mock.Anything, 1).Return(quote, nil).Twice()
+	mockViews.On("ViewStats", mock.Anything, []int{1}).Return(map[int]models.ViewStats{1: {Get: 10}}, nil).Once()
+	mockViews.On("ViewStats", mock.Anything, []int{1}).Return(map[int]models.ViewStats{1: {Get: 10}}, nil).Once()
+
+	_, err := service.GetByID(context.Background(), 1)
+	assert.NoError(t, err)
+	result, err := service.GetByID(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), result.Views.Get)
+
+	_, err = service.GetByID(context.Background(), 0)
+	assert.ErrorIs(t, err, domain.ErrInvalidInput)
+}
+
+type MockDuplicateIndex struct {
+	mock.Mock
+}
+
+func (m *MockDuplicateIndex) TermCandidates(ctx context.Context, terms [][]string, limit int, statuses ...string) ([][]models.Quote, error) {
+	args := m.Called(ctx, terms, limit, statuses)
+	return args.Get(0).([][]models.Quote), args.Error(1)
+}
+
+func TestQuoteService_CreateDuplicates(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	mockIndex := new(MockDuplicateIndex)
+	service := NewQuoteService(mockRepo, WithDuplicateDetection(mockIndex, 0.8))
+
+	existing := []models.Quote{
+		{ID: 3, Author: "Oscar Wilde", Quote: "Be yourself; everyone else is already taken."},
+		{ID: 4, Author: "Socrates", Quote: "Know thyself"},
+	}
+	statuses := []string{models.StatusPending, models.StatusApproved}
+
+	t.Run("near duplicate", func(t *testing.T) {
+		terms := [][]string{{"already", "else", "everyone", "taken", "yourself"}}
+		mockIndex.On("TermCandidates", mock.Anything, terms, duplicateSearchLimit, statuses).Return([][]models.Quote{existing}, nil).Once()
+
+		err := service.Create(context.Background(), &models.Quote{Author: "oscar  wilde", Quote: "be yourself, everyone else is already taken  "})
+		var dupErr *domain.DuplicateError
+		assert.ErrorAs(t, err, &dupErr)
+		assert.ErrorIs(t, err, domain.ErrDuplicate)
+		assert.Equal(t, []int{3}, dupErr.Candidates)
+	})
+
+	t.Run("same text by another author", func(t *testing.T) {
+		quote := &models.Quote{Author: "Socrates", Quote: "Be yourself; everyone else is already taken."}
+		mockIndex.On("TermCandidates", mock.Anything, mock.Anything, duplicateSearchLimit, statuses).Return([][]models.Quote{existing}, nil).Once()
+		mockRepo.On("Create", mock.Anything, quote).Return(nil).Once()
+
+		err := service.Create(context.Background(), quote)
+		assert.NoError(t, err)
+	})
+
+	t.Run("no significant words", func(t *testing.T) {
+		quote := &models.Quote{Author: "Socrates", Quote: "Is it?"}
+		mockIndex.On("TermCandidates", mock.Anything, [][]string{{}}, duplicateSearchLimit, statuses).Return([][]models.Quote{nil}, nil).Once()
+		mockRepo.On("GetByAuthor", mock.Anything, "Socrates", statuses).Return([]models.Quote{{ID: 5, Author: "Socrates", Quote: "Is it!"}}, nil).Once()
+
+		err := service.Create(context.Background(), quote)
+		var dupErr *domain.DuplicateError
+		assert.ErrorAs(t, err, &dupErr)
+		assert.Equal(t, []int{5}, dupErr.Candidates)
+	})
+
+	t.Run("forced create", func(t *testing.T) {
+		quote := &models.Quote{Author: "Oscar Wilde", Quote: "Be yourself!"}
+		mockRepo.On("Create", mock.Anything, quote).Return(nil).Once()
+
+		err := service.Create(context.Background(), quote, AllowDuplicates())
+		assert.NoError(t, err)
+	})
+
+	t.Run("distinct quote", func(t *testing.T) {
+		quote := &models.Quote{Author: " Seneca ", Quote: "Luck is what happens when preparation meets opportunity"}
+		mockIndex.On("TermCandidates", mock.Anything, mock.Anything, duplicateSearchLimit, statuses).Return([][]models.Quote{existing}, nil).Once()
+		mockRepo.On("Create", mock.Anything, quote).Return(nil).Once()
+
+		err := service.Create(context.Background(), quote)
+		assert.NoError(t, err)
+		assert.Equal(t, "Seneca", quote.Author)
+	})
+}
+
+type MockTermIndex struct {
+	mock.Mock
+}
+
+func (m *MockTermIndex) TermVector(ctx context.Context, quoteID int) (map[string]int, error) {
+	args := m.Called(ctx, quoteID)
+	return args.Get(0).(map[string]int), args.Error(1)
+}
+
+func (m *MockTermIndex) TermNeighbors(ctx context.Context, quoteID int, limit int) (map[int]map[string]int, error) {
+	args := m.Called(ctx, quoteID, limit)
+	return args.Get(0).(map[int]map[string]int), args.Error(1)
+}
+
+func (m *MockTermIndex) TermStats(ctx context.Context, terms []string) (map[string]int, int, error) {
+	args := m.Called(ctx, terms)
+	return args.Get(0).(map[string]int), args.Int(1), args.Error(2)
+}
+
+func (m *MockTermIndex) TermMatches(ctx context.Context, terms []string, limit int) (map[int]map[string]int, error) {
+	args := m.Called(ctx, terms, limit)
+	return args.Get(0).(map[int]map[string]int), args.Error(1)
+}
+
+func (m *MockTermIndex) GetByIDs(ctx context.Context, ids []int) ([]models.Quote, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).([]models.Quote), args.Error(1)
+}
+
+func TestQuoteService_Similar(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	mockIndex := new(MockTermIndex)
+	service := NewQuoteService(mockRepo, WithSimilarity(mockIndex))
+
+	t.Run("ranked by similarity", func(t *testing.T) {
+		mockRepo.On("GetByID", mock.Anything, 1).Return(&models.Quote{ID: 1}, nil).Once()
+		mockIndex.On("TermVector", mock.Anything, 1).Return(map[string]int{"life": 1, "simple": 1}, nil).Once()
+		mockIndex.On("TermNeighbors", mock.Anything, 1, similarCandidates).Return(map[int]map[string]int{
+			2: {"life": 1, "hard": 1, "long": 1},
+			3: {"life": 1, "simple": 1, "really": 1},
+		}, nil).Once()
+		mockIndex.On("TermStats", mock.Anything, mock.Anything).Return(map[string]int{"life": 3, "simple": 2, "hard": 1, "long": 1, "really": 1}, 3, nil).Once()
+		mockIndex.On("GetByIDs", mock.Anything, []int{3, 2}).Return([]models.Quote{{ID: 2}, {ID: 3}}, nil).Once()
+
+		result, err := service.Similar(context.Background(), 1, 0)
+		assert.NoError(t, err)
+		if assert.Len(t, result, 2) {
+			assert.Equal(t, 3, result[0].ID)
+			assert.Equal(t, 2, result[1].ID)
+		}
+	})
+
+	t.Run("quote not found", func(t *testing.T) {
+		mockRepo.On("GetByID", mock.Anything, 999).Return((*models.Quote)(nil), domain.ErrNotFound).Once()
+
+		_, err := service.Similar(context.Background(), 999, 0)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("pending quote is hidden", func(t *testing.T) {
+		mockRepo.On("GetByID", mock.Anything, 4).Return(&models.Quote{ID: 4, Status: models.StatusPending, OwnerID: "key:1"}, nil).Once()
+
+		_, err := service.Similar(context.Background(), 4, 0)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+}
+
+func TestQuoteService_Search(t *testing.T) {
+	mockIndex := new(MockTermIndex)
+	service := NewQuoteService(new(MockQuerier), WithSimilarity(mockIndex))
+
+	t.Run("ranked by relevance", func(t *testing.T) {
+		mockIndex.On("TermMatches", mock.Anything, mock.Anything, similarCandidates).Return(map[int]map[string]int{
+			2: {"life": 1, "hard": 1, "long": 1},
+			3: {"life": 1, "simple": 1},
+		}, nil).Once()
+		mockIndex.On("TermStats", mock.Anything, mock.Anything).Return(map[string]int{"life": 2, "simple": 1, "hard": 1, "long": 1}, 3, nil).Once()
+		mockIndex.On("GetByIDs", mock.Anything, []int{3, 2}).Return([]models.Quote{{ID: 2}, {ID: 3}}, nil).Once()
+
+		result, err := service.Search(context.Background(), "Simple life", 0)
+		assert.NoError(t, err)
+		if assert.Len(t, result, 2) {
+			assert.Equal(t, 3, result[0].ID)
+			assert.Equal(t, 2, result[1].ID)
+		}
+	})
+
+	t.Run("empty query", func(t *testing.T) {
+		_, err := service.Search(context.Background(), "  ", 0)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+}
+
+type MockCollectionRepository struct {
+	mock.Mock
+}
+
+func (m *MockCollectionRepository) CreateCollection(ctx context.Context, c *models.Collection) error {
+	args := m.Called(ctx, c)
+	return args.Error(0)
+}
+
+func (m *MockCollectionRepository) GetCollections(ctx context.Context) ([]models.Collection, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.Collection), args.Error(1)
+}
+
+func (m *MockCollectionRepository) GetCollection(ctx context.Context, id int) (*models.Collection, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Collection), args.Error(1)
+}
+
+func (m *MockCollectionRepository) UpdateCollection(ctx context.Context, c *models.Collection) error {
+	args := m.Called(ctx, c)
+	return args.Error(0)
+}
+
+func (m *MockCollectionRepository) DeleteCollection(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockCollectionRepository) AddToCollection(ctx context.Context, id, quoteID int) error {
+	args := m.Called(ctx, id, quoteID)
+	return args.Error(0)
+}
+
+func (m *MockCollectionRepository) RemoveFromCollection(ctx context.Context, id, quoteID int) error {
+	args := m.Called(ctx, id, quoteID)
+	return args.Error(0)
+}
+
+func (m *MockCollectionRepository) ReorderCollection(ctx context.Context, id int, quoteIDs []int) error {
+	args := m.Called(ctx, id, quoteIDs)
+	return args.Error(0)
+}
+
+func (m *MockCollectionRepository) GetRandomFromCollection(ctx context.Context, id int) (*models.Quote, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Quote), args.Error(1)
+}
+
+func (m *MockCollectionRepository) GetByIDs(ctx context.Context, ids []int) ([]models.Quote, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).([]models.Quote), args.Error(1)
+}
+
+func TestCollectionService_Create(t *testing.T) {
+	mockRepo := new(MockCollectionRepository)
+	service := NewCollectionService(mockRepo)
+
+	t.Run("successful create", func(t *testing.T) {
+		c := &models.Collection{Name: " Monday Motivation ", QuoteIDs: []int{3, 1}}
+		mockRepo.On("CreateCollection", mock.Anything, c).Return(nil).Once()
+
+		err := service.Create(context.Background(), c)
+		assert.NoError(t, err)
+		assert.Equal(t, "Monday Motivation", c.Name)
+	})
+
+	t.Run("repeated quote", func(t *testing.T) {
+		err := service.Create(context.Background(), &models.Collection{Name: "Monday", QuoteIDs: []int{1, 1}})
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+}
+
+func TestCollectionService_Get(t *testing.T) {
+	mockRepo := new(MockCollectionRepository)
+	service := NewCollectionService(mockRepo)
+
+	mockRepo.On("GetCollection", mock.Anything, 1).Return(&models.Collection{ID: 1, QuoteIDs: []int{3, 1}}, nil).Once()
+	mockRepo.On("GetByIDs", mock.Anything, []int{3, 1}).Return([]models.Quote{{ID: 1}, {ID: 3}}, nil).Once()
+
+	c, err := service.Get(context.Background(), 1)
+	assert.NoError(t, err)
+	if assert.Len(t, c.Quotes, 2) {
+		assert.Equal(t, 3, c.Quotes[0].ID)
+		assert.Equal(t, 1, c.Quotes[1].ID)
+	}
+}
+
+func TestCollectionService_Reorder(t *testing.T) {
+	mockRepo := new(MockCollectionRepository)
+	service := NewCollectionService(mockRepo)
+
+	t.Run("successful reorder", func(t *testing.T) {
+		mockRepo.On("GetCollection", mock.Anything, 1).Return(&models.Collection{ID: 1, QuoteIDs: []int{1, 2, 3}}, nil).Once()
+		mockRepo.On("ReorderCollection", mock.Anything, 1, []int{3, 1, 2}).Return(nil).Once()
+
+		err := service.Reorder(context.Background(), 1, []int{3, 1, 2})
+		assert.NoError(t, err)
+	})
+
+	t.Run("foreign quote", func(t *testing.T) {
+		mockRepo.On("GetCollection", mock.Anything, 1).Return(&models.Collection{ID: 1, QuoteIDs: []int{1, 2, 3}}, nil).Once()
+
+		err := service.Reorder(context.Background(), 1, []int{3, 1, 4})
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("collection not found", func(t *testing.T) {
+		mockRepo.On("GetCollection", mock.Anything, 2).Return((*models.Collection)(nil), domain.ErrCollectionNotFound).Once()
+
+		err := service.Reorder(context.Background(), 2, []int{1})
+		assert.ErrorIs(t, err, domain.ErrCollectionNotFound)
+	})
+}
+
+func TestCollectionService_Policy(t *testing.T) {
+	mockRepo := new(MockCollectionRepository)
+	service := NewCollectionService(mockRepo, WithCollectionPolicy(RolePolicy{}))
+
+	owner := auth.WithPrincipal(context.Background(), &auth.Principal{ID: "key:1", Roles: []string{auth.RoleContributor}})
+	stranger := auth.WithPrincipal(context.Background(), &auth.Principal{ID: "key:2", Roles: []string{auth.RoleContributor}})
+	moderator := auth.WithPrincipal(context.Background(), &auth.Principal{ID: "key:3", Roles: []string{auth.RoleModerator}})
+
+	t.Run("create sets owner", func(t *testing.T) {
+		c := &models.Collection{Name: "Monday", OwnerID: "key:9"}
+		mockRepo.On("CreateCollection", mock.Anything, c).Return(nil).Once()
+
+		err := service.Create(owner, c)
+		assert.NoError(t, err)
+		assert.Equal(t, "key:1", c.OwnerID)
+	})
+
+	t.Run("anonymous create", func(t *testing.T) {
+		err := service.Create(context.Background(), &models.Collection{Name: "Monday"})
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+
+	t.Run("owner updates", func(t *testing.T) {
+		mockRepo.On("GetCollection", mock.Anything, 1).Return(&models.Collection{ID: 1, OwnerID: "key:1"}, nil).Once()
+		mockRepo.On("AddToCollection", mock.Anything, 1, 5).Return(nil).Once()
+
+		assert.NoError(t, service.AddQuote(owner, 1, 5))
+	})
+
+	t.Run("stranger cannot delete", func(t *testing.T) {
+		mockRepo.On("GetCollection", mock.Anything, 1).Return(&models.Collection{ID: 1, OwnerID: "key:1"}, nil).Once()
+
+		err := service.Delete(stranger, 1)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("moderator reorders any", func(t *testing.T) {
+		mockRepo.On("GetCollection", mock.Anything, 1).Return(&models.Collection{ID: 1, OwnerID: "key:1", QuoteIDs: []int{1, 2}}, nil).Once()
+		mockRepo.On("ReorderCollection", mock.Anything, 1, []int{2, 1}).Return(nil).Once()
+
+		assert.NoError(t, service.Reorder(moderator, 1, []int{2, 1}))
+	})
+
+	mockRepo.AssertExpectations(t)
+}
+
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+func (m *MockAPIKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey, hash string) error {
+	args := m.Called(ctx, key, hash)
+	return args.Error(0)
+}
+
+func (m *MockAPIKeyRepository) FindAPIKey(ctx context.Context, hash string) (*models.APIKey, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(*models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) RevokeAPIKey(ctx context.Context, id int) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func TestAPIKeyService(t *testing.T) {
+	mockRepo := new(MockAPIKeyRepository)
+	service := NewAPIKeyService(mockRepo)
+
+	var storedHash string
+	mockRepo.On("CreateAPIKey", mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		storedHash = args.String(2)
+		args.Get(1).(*models.APIKey).ID = 7
+	}).Return(nil).Once()
+
+	plain, key, err := service.Issue(context.Background(), "sync job", []string{auth.ScopeWrite}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, key.ID)
+	assert.NotContains(t, storedHash, plain)
+	assert.Equal(t, auth.HashKey(plain), storedHash)
+
+	t.Run("authenticate", func(t *testing.T) {
+		mockRepo.On("FindAPIKey", mock.Anything, storedHash).Return(&models.APIKey{ID: 7, Name: "sync job", Scopes: []string{auth.ScopeWrite}}, nil).Once()
+
+		principal, err := service.Authenticate(context.Background(), plain)
+		assert.NoError(t, err)
+		assert.Equal(t, "key:7", principal.ID)
+		assert.True(t, principal.HasScope(auth.ScopeWrite))
+		assert.False(t, principal.HasScope(auth.ScopeAdmin))
+		assert.True(t, principal.HasRole(auth.RoleContributor), "роль выводится из области write")
+		assert.False(t, principal.HasRole(auth.RoleModerator))
+	})
+
+	t.Run("revoked key", func(t *testing.T) {
+		mockRepo.On("FindAPIKey", mock.Anything, auth.HashKey("qs_revoked")).Return((*models.APIKey)(nil), domain.ErrAPIKeyNotFound).Once()
+
+		_, err := service.Authenticate(context.Background(), "qs_revoked")
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+
+	t.Run("unknown scope", func(t *testing.T) {
+		_, _, err := service.Issue(context.Background(), "sync job", []string{"superuser"}, nil)
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("unknown role", func(t *testing.T) {
+		_, _, err := service.Issue(context.Background(), "sync job", []string{auth.ScopeWrite}, []string{"owner"})
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+}
+
+func TestQuoteService_Policy(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	service := NewQuoteService(mockRepo, WithPolicy(RolePolicy{}))
+
+	as := func(id string, roles ...string) context.Context {
+		return auth.WithPrincipal(context.Background(), &auth.Principal{ID: id, Roles: roles})
+	}
+	owned := &models.Quote{ID: 1, Author: "Confucius", Quote: "Life is simple", OwnerID: "key:1"}
+
+	t.Run("create records owner", func(t *testing.T) {
+		mockRepo.On("Create", mock.Anything, mock.MatchedBy(func(q *models.Quote) bool {
+			return q.OwnerID == "key:1"
+		})).Return(nil).Once()
+
+		quote := &models.Quote{Author: "Confucius", Quote: "Life is simple", OwnerID: "someone-else"}
+		err := service.Create(as("key:1", auth.RoleContributor), quote, AllowDuplicates())
+		assert.NoError(t, err)
+		assert.Equal(t, "key:1", quote.OwnerID)
+	})
+
+	t.Run("anonymous create", func(t *testing.T) {
+		err := service.Create(context.Background(), &models.Quote{Author: "Confucius", Quote: "Life is simple"})
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+
+	t.Run("owner edits own quote", func(t *testing.T) {
+		mockRepo.On("GetByID", mock.Anything, 1).Return(owned, nil).Once()
+		mockRepo.On("Update", mock.Anything, mock.Anything).Return(nil).Once()
+
+		err := service.Update(as("key:1", auth.RoleContributor), &models.Quote{ID: 1, Author: "Confucius", Quote: "Life is really simple"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("contributor cannot edit foreign quote", func(t *testing.T) {
+		mockRepo.On("GetByID", mock.Anything, 1).Return(owned, nil).Once()
+
+		err := service.Update(as("key:2", auth.RoleContributor), &models.Quote{ID: 1, Author: "Confucius", Quote: "Mine now"})
+		var forbidden *domain.ForbiddenError
+		assert.ErrorAs(t, err, &forbidden)
+		assert.Equal(t, ActionUpdateQuote, forbidden.Action)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("contributor cannot delete own quote", func(t *testing.T) {
+		mockRepo.On("GetByID", mock.Anything, 1).Return(owned, nil).Once()
+
+		err := service.Delete(as("key:1", auth.RoleContributor), 1)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("moderator deletes any quote", func(t *testing.T) {
+		mockRepo.On("GetByID", mock.Anything, 1).Return(owned, nil).Once()
+		mockRepo.On("Delete", mock.Anything, 1).Return(nil).Once()
+
+		err := service.Delete(as("key:3", auth.RoleModerator), 1)
+		assert.NoError(t, err)
+	})
+
+	t.Run("only admin manages keys", func(t *testing.T) {
+		assert.ErrorIs(t, RolePolicy{}.Authorize(as("key:3", auth.RoleModerator), ActionManageKeys, nil), domain.ErrForbidden)
+		assert.NoError(t, RolePolicy{}.Authorize(as("key:4", auth.RoleAdmin), ActionManageKeys, nil))
+	})
+
+	mockRepo.AssertExpectations(t)
+}
+
+type MockModerationRepository struct {
+	mock.Mock
+}
+
+func (m *MockModerationRepository) Moderate(ctx context.Context, id int, status, reason, moderator string) (*models.Quote, error) {
+	args := m.Called(ctx, id, status, reason, moderator)
+	return args.Get(0).(*models.Quote), args.Error(1)
+}
+
+func TestQuoteService_Moderation(t *testing.T) {
+	mockRepo := new(MockQuerier)
+	mockModeration := new(MockModerationRepository)
+	service := NewQuoteService(mockRepo, WithPolicy(RolePolicy{}), WithModeration(mockModeration))
+
+	contributor := auth.WithPrincipal(context.Background(), &auth.Principal{ID: "key:1", Roles: []string{auth.RoleContributor}})
+	moderator := auth.WithPrincipal(context.Background(), &auth.Principal{ID: "key:2", Roles: []string{auth.RoleModerator}})
+	pending := &models.Quote{ID: 1, Author: "Confucius", Quote: "Life is simple", OwnerID: "key:1", Status: models.StatusPending}
+
+	t.Run("contributor submission is pending", func(t *testing.T) {
+		mockRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+
+		quote := &models.Quote{Author: "Confucius", Quote: "Life is simple"}
+		assert.NoError(t, service.Create(contributor, quote, AllowDuplicates()))
+		assert.Equal(t, models.StatusPending, quote.Status)
+	})
+
+	t.Run("moderator publishes directly", func(t *testing.T) {
+		mockRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+
+		quote := &models.Quote{Author: "Confucius", Quote: "Life is simple"}
+		assert.NoError(t, service.Create(moderator, quote, AllowDuplicates()))
+		assert.Equal(t, models.StatusApproved, quote.Status)
+	})
+
+	t.Run("pending quote is visible to owner only", func(t *testing.T) {
+		mockRepo.On("GetByID", mock.Anything, 1).Return(pending, nil).Twice()
+
+		_, err := service.GetByID(context.Background(), 1)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		quote, err := service.GetByID(contributor, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatusPending, quote.Status)
+	})
+
+	t.Run("unapproved listing requires admin", func(t *testing.T) {
+		_, err := service.GetAll(moderator, models.StatusPending)
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+		_, err = service.GetAll(moderator, "draft")
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("contributor cannot approve", func(t *testing.T) {
+		_, err := service.Approve(contributor, 1, "")
+		assert.ErrorIs(t, err, domain.ErrForbidden)
+	})
+
+	t.Run("reject requires reason", func(t *testing.T) {
+		_, err := service.Reject(moderator, 1, " ")
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		rejected := &models.Quote{ID: 1, Status: models.StatusRejected, ModerationReason: "not a quote"}
+		mockModeration.On("Moderate", mock.Anything, 1, models.StatusRejected, "not a quote", "key:2").Return(rejected, nil).Once()
+
+		quote, err := service.Reject(moderator, 1, "not a quote")
+		assert.NoError(t, err)
+		assert.Equal(t, models.StatusRejected, quote.Status)
+	})
+
+	t.Run("owner edit of a published quote goes back to review", func(t *testing.T) {
+		events := &recordingPublisher{}
+		svc := NewQuoteService(mockRepo, WithPolicy(RolePolicy{}), WithModeration(mockModeration), WithEvents(events))
+		approved := &models.Quote{ID: 2, Author: "Confucius", Quote: "Life is simple", OwnerID: "key:1", Status: models.StatusApproved}
+		mockRepo.On("GetByID", mock.Anything, 2).Return(approved, nil).Twice()
+		mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(q *models.Quote) bool {
+			return q.Status == models.StatusPending
+		})).Return(nil).Once()
+		mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(q *models.Quote) bool {
+			return q.Status == ""
+		})).Run(func(args mock.Arguments) {
+			args.Get(1).(*models.Quote).Status = models.StatusApproved
+		}).Return(nil).Once()
+
+		quote := &models.Quote{ID: 2, Author: "Confucius", Quote: "Buy cheap pills"}
+		assert.NoError(t, svc.Update(contributor, quote))
+		assert.Equal(t, models.StatusPending, quote.Status)
+		// для читателей цитата снята с публикации
+		if assert.Len(t, events.events, 1) {
+			assert.Equal(t, EventDeleted, events.events[0].Type)
+			assert.Equal(t, 2, events.events[0].Quote.ID)
+		}
+
+		// правка модератора статус не меняет
+		assert.NoError(t, svc.Update(moderator, &models.Quote{ID: 2, Author: "Confucius", Quote: "Life is really simple"}))
+		if assert.Len(t, events.events, 2) {
+			assert.Equal(t, EventUpdated, events.events[1].Type)
+		}
+	})
+
+	mockRepo.AssertExpectations(t)
+	mockModeration.AssertExpectations(t)
+}
+
+func TestQuoteService_ContentFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("# список\ncasino\n"), 0o644))
+	banned, err := LoadBannedWords(path)
+	assert.NoError(t, err)
+
+	mockRepo := new(MockQuerier)
+	service := NewQuoteService(mockRepo, WithContentFilter(
+		StripControlChars(),
+		LengthLimits{MinQuote: 5, MaxQuote: 40, MaxAuthor: 10},
+		banned,
+		NoURLs(),
+		NoSpam(),
+	))
+
+	codes := func(err error) []string {
+		var validationErr *domain.ValidationError
+		if !assert.ErrorAs(t, err, &validationErr) {
+			return nil
+		}
+		var list []string
+		for _, v := range validationErr.Violations {
+			list = append(list, v.Field+":"+v.Code)
+		}
+		return list
+	}
+
+	t.Run("clean quote with control characters", func(t *testing.T) {
+		mockRepo.On("Create", mock.Anything, mock.Anything).Return(nil).Once()
+
+		quote := &models.Quote{Author: "Confucius\u200b", Quote: "Life is\x00 simple"}
+		assert.NoError(t, service.Create(context.Background(), quote, AllowDuplicates()))
+		assert.Equal(t, "Confucius", quote.Author)
+		assert.Equal(t, "Life is simple", quote.Quote)
+	})
+
+	t.Run("lengths", func(t *testing.T) {
+		err := service.Create(context.Background(), &models.Quote{Author: "Someone very verbose", Quote: "Hi"})
+		assert.Equal(t, []string{"quote:too_short", "author:too_long"}, codes(err))
+		assert.ErrorIs(t, err, domain.ErrInvalidInput)
+	})
+
+	t.Run("banned word", func(t *testing.T) {
+		err := service.Create(context.Background(), &models.Quote{Author: "Bot", Quote: "Best CASINO in town!"})
+		assert.Equal(t, []string{"quote:banned_word"}, codes(err))
+	})
+
+	t.Run("link spam", func(t *testing.T) {
+		err := service.Create(context.Background(), &models.Quote{Author: "Bot", Quote: "Visit www.example.com now"})
+		assert.Equal(t, []string{"quote:url_not_allowed"}, codes(err))
+	})
+
+	t.Run("repeated words", func(t *testing.T) {
+		err := service.Create(context.Background(), &models.Quote{Author: "Bot", Quote: "Buy buy buy buy buy now"})
+		assert.Equal(t, []string{"quote:spam"}, codes(err))
+	})
+
+	mockRepo.AssertExpectations(t)
+}
+
+// fakeTransactor считает зафиксированные и откаченные транзакции и точки сохранения
+type fakeTransactor struct {
+	committed, rolledBack int
+}
+
+func (tx *fakeTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := fn(ctx); err != nil {
+		tx.rolledBack++
+		return err
+	}
+	tx.committed++
+	return nil
+}
+
+func TestQuoteService_Batch(t *testing.T) {
+	ops := []BatchOp{
+		{Op: BatchCreate, Quote: models.Quote{Author: "Seneca", Quote: "Luck is preparation"}},
+		{Op: BatchDelete, Quote: models.Quote{ID: 404}},
+		{Op: BatchUpdate, Quote: models.Quote{ID: 2, Author: "Seneca", Quote: "We suffer more in imagination"}},
+	}
+	setup := func() (*MockQuerier, *fakeTransactor, *QuoteService) {
+		mockQuerier := new(MockQuerier)
+		tx := &fakeTransactor{}
+		mockQuerier.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mockQuerier.On("Delete", mock.Anything, 404).Return(domain.ErrNotFound)
+		mockQuerier.On("Update", mock.Anything, mock.Anything).Return(nil)
+		return mockQuerier, tx, NewQuoteService(mockQuerier, WithTransactions(tx))
+	}
+
+	t.Run("atomic", func(t *testing.T) {
+		mockQuerier, tx, svc := setup()
+
+		results, err := svc.Batch(context.Background(), ops, true)
+		assert.NoError(t, err)
+		assert.Equal(t, domain.ErrRolledBack, results[0].Err)
+		assert.Equal(t, domain.ErrNotFound, results[1].Err)
+		assert.Equal(t, domain.ErrRolledBack, results[2].Err)
+		assert.Equal(t, 1, tx.rolledBack)
+		assert.Equal(t, 0, tx.committed)
+		mockQuerier.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+	})
+
+	t.Run("best effort", func(t *testing.T) {
+		_, tx, svc := setup()
+
+		results, err := svc.Batch(context.Background(), ops, false)
+		assert.NoError(t, err)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, "Seneca", results[0].Quote.Author)
+		assert.Equal(t, domain.ErrNotFound, results[1].Err)
+		assert.NoError(t, results[2].Err)
+		assert.Equal(t, 2, results[2].Quote.ID)
+		// три точки сохранения и внешняя транзакция, откачена только точка неудачного delete
+		assert.Equal(t, 1, tx.rolledBack)
+		assert.Equal(t, 3, tx.committed)
+	})
+
+	t.Run("without transactions", func(t *testing.T) {
+		_, err := NewQuoteService(new(MockQuerier)).Batch(context.Background(), ops, true)
+		assert.Error(t, err)
+	})
+}
+
+func TestQuoteService_BatchDuplicates(t *testing.T) {
+	mockQuerier := new(MockQuerier)
+	mockIndex := new(MockDuplicateIndex)
+	svc := NewQuoteService(mockQuerier, WithTransactions(&fakeTransactor{}), WithDuplicateDetection(mockIndex, 0.8))
+
+	ops := []BatchOp{
+		{Op: BatchCreate, Quote: models.Quote{Author: "Seneca", Quote: "Luck is what happens when preparation meets opportunity"}},
+		{Op: BatchDelete, Quote: models.Quote{ID: 404}},
+		{Op: BatchCreate, Quote: models.Quote{Author: "Seneca", Quote: "Luck is what happens when preparation meets opportunity!"}},
+		{Op: BatchCreate, Quote: models.Quote{Author: "Confucius", Quote: "Life is really simple"}},
+	}
+	stored := models.Quote{ID: 7, Author: "Confucius", Quote: "Life is really simple, but we insist on making it complicated"}
+	// кандидаты для всех трех create загружаются одним запросом
+	mockIndex.On("TermCandidates", mock.Anything, mock.MatchedBy(func(terms [][]string) bool {
+		return len(terms) == 3
+	}), duplicateSearchLimit, []string{models.StatusPending, models.StatusApproved}).
+		Return([][]models.Quote{nil, nil, {{ID: 8, Author: "Confucius", Quote: "Life is really simple"}, stored}}, nil).Once()
+	mockQuerier.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(1).(*models.Quote).ID = 9
+	}).Return(nil).Once()
+	mockQuerier.On("Delete", mock.Anything, 404).Return(domain.ErrNotFound).Once()
+
+	results, err := svc.Batch(context.Background(), ops, false)
+	assert.NoError(t, err)
+	assert.NoError(t, results[0].Err)
+
+	// вторая цитата совпадает с созданной первой операцией пакета
+	var dup *domain.DuplicateError
+	if assert.ErrorAs(t, results[2].Err, &dup) {
+		assert.Equal(t, []int{9}, dup.Candidates)
+	}
+	if assert.ErrorAs(t, results[3].Err, &dup) {
+		assert.Equal(t, []int{8}, dup.Candidates)
+	}
+	mockIndex.AssertExpectations(t)
+	mockQuerier.AssertExpectations(t)
+}
+
+// recordingPublisher запоминает опубликованные события
+type recordingPublisher struct {
+	events []Event
+}
+
+func (p *recordingPublisher) Publish(ev Event) {
+	p.events = append(p.events, ev)
+}
+
+// checkedTransactor проверяет, что до фиксации внешней транзакции не опубликовано ни одного события
+type checkedTransactor struct {
+	fakeTransactor
+	t      *testing.T
+	events *recordingPublisher
+}
+
+func (tx *checkedTransactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	err := tx.fakeTransactor.InTx(ctx, fn)
+	assert.Empty(tx.t, tx.events.events, "события до фиксации транзакции")
+	return err
+}
+
+func TestQuoteService_Events(t *testing.T) {
+	t.Run("create and delete", func(t *testing.T) {
+		mockQuerier := new(MockQuerier)
+		events := &recordingPublisher{}
+		svc := NewQuoteService(mockQuerier, WithEvents(events))
+		mockQuerier.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mockQuerier.On("Delete", mock.Anything, 3).Return(nil)
+		mockQuerier.On("Delete", mock.Anything, 404).Return(domain.ErrNotFound)
+
+		assert.NoError(t, svc.Create(context.Background(), &models.Quote{Author: "Seneca", Quote: "Luck is preparation"}))
+		assert.NoError(t, svc.Delete(context.Background(), 3))
+		assert.Error(t, svc.Delete(context.Background(), 404))
+
+		assert.Len(t, events.events, 2)
+		assert.Equal(t, EventCreated, events.events[0].Type)
+		assert.Equal(t, "Seneca", events.events[0].Quote.Author)
+		assert.Equal(t, EventDeleted, events.events[1].Type)
+		assert.Equal(t, 3, events.events[1].Quote.ID)
+	})
+
+	t.Run("pending quote is announced on approval", func(t *testing.T) {
+		mockQuerier := new(MockQuerier)
+		mockModeration := new(MockModerationRepository)
+		events := &recordingPublisher{}
+		svc := NewQuoteService(mockQuerier, WithModeration(mockModeration), WithEvents(events))
+		mockQuerier.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mockModeration.On("Moderate", mock.Anything, 5, models.StatusApproved, "", "").
+			Return(&models.Quote{ID: 5, Author: "Seneca", Status: models.StatusApproved}, nil)
+
+		assert.NoError(t, svc.Create(context.Background(), &models.Quote{Author: "Seneca", Quote: "Luck is preparation"}))
+		assert.Empty(t, events.events)
+
+		_, err := svc.Approve(context.Background(), 5, "")
+		assert.NoError(t, err)
+		assert.Len(t, events.events, 1)
+		assert.Equal(t, EventApproved, events.events[0].Type)
+		assert.Equal(t, EventCreated, events.events[0].FeedType())
+		assert.Equal(t, 5, events.events[0].Quote.ID)
+	})
+
+	t.Run("batch publishes after commit", func(t *testing.T) {
+		ops := []BatchOp{
+			{Op: BatchCreate, Quote: models.Quote{Author: "Seneca", Quote: "Luck is preparation"}},
+			{Op: BatchDelete, Quote: models.Quote{ID: 404}},
+			{Op: BatchDelete, Quote: models.Quote{ID: 3}},
+		}
+		for _, atomic := range []bool{false, true} {
+			mockQuerier := new(MockQuerier)
+			events := &recordingPublisher{}
+			tx := &checkedTransactor{t: t, events: events}
+			svc := NewQuoteService(mockQuerier, WithTransactions(tx), WithEvents(events))
+			mockQuerier.On("Create", mock.Anything, mock.Anything).Return(nil)
+			mockQuerier.On("Delete", mock.Anything, 3).Return(nil)
+			mockQuerier.On("Delete", mock.Anything, 404).Return(domain.ErrNotFound)
+
+			_, err := svc.Batch(context.Background(), ops, atomic)
+			assert.NoError(t, err)
+			if atomic {
+				// откаченный пакет ничего не публикует
+				assert.Empty(t, events.events)
+				continue
+			}
+			assert.Len(t, events.events, 2)
+			assert.Equal(t, EventCreated, events.events[0].Type)
+			assert.Equal(t, EventDeleted, events.events[1].Type)
+		}
+	})
+
+	t.Run("outbox leaves events to the storage", func(t *testing.T) {
+		mockQuerier := new(MockQuerier)
+		mockModeration := new(MockModerationRepository)
+		events := &recordingPublisher{}
+		svc := NewQuoteService(mockQuerier, WithModeration(mockModeration), WithEvents(events), WithOutbox())
+		mockQuerier.On("Delete", mock.Anything, 3).Return(nil)
+		mockModeration.On("Moderate", mock.Anything, 5, models.StatusApproved, "", "").
+			Return(&models.Quote{ID: 5, Status: models.StatusApproved}, nil)
+
+		assert.NoError(t, svc.Delete(context.Background(), 3))
+		_, err := svc.Approve(context.Background(), 5, "")
+		assert.NoError(t, err)
+		assert.Empty(t, events.events)
+	})
+}
+
+// memOutbox - журнал событий в памяти; visible скрывает еще не зафиксированные события
+type memOutbox struct {
+	mu         sync.Mutex
+	events     []models.OutboxEvent
+	visible    func(id int64) bool
+	purged     []time.Duration
+	dispatched int64
+}
+
+func (m *memOutbox) DispatchedEventID(context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.dispatched > 0 || len(m.events) == 0 {
+		return m.dispatched, nil
+	}
+	return m.events[len(m.events)-1].ID, nil
+}
+
+func (m *memOutbox) SaveDispatchedEventID(_ context.Context, id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dispatched = id
+	return nil
+}
+
+func (m *memOutbox) EventsAfter(_ context.Context, afterID int64, limit int) ([]models.OutboxEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var events []models.OutboxEvent
+	for _, ev := range m.events {
+		if ev.ID > afterID && len(events) < limit && (m.visible == nil || m.visible(ev.ID)) {
+			events = append(events, ev)
+		}
+	}
+	return events, nil
+}
+
+func (m *memOutbox) PurgeEvents(_ context.Context, olderThan time.Duration) error {
+	m.purged = append(m.purged, olderThan)
+	return nil
+}
+
+func (m *memOutbox) append(id int64, typ string, quoteID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = append(m.events, models.OutboxEvent{ID: id, Type: typ, Quote: models.Quote{ID: quoteID}, CreatedAt: time.Now()})
+}
+
+// chanNotifier отдает уведомление на каждое значение в канале
+type chanNotifier chan struct{}
+
+func (n chanNotifier) Wait(ctx context.Context) error {
+	select {
+	case <-n:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestEventDispatcher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("relays events in order to every publisher", func(t *testing.T) {
+		outbox := &memOutbox{}
+		outbox.append(1, EventCreated, 1)
+		outbox.append(2, EventDeleted, 1)
+		first, second := &recordingPublisher{}, &recordingPublisher{}
+		d := NewEventDispatcher(outbox, nil, []EventPublisher{first, second})
+
+		n, err := d.Dispatch(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, n)
+		for _, p := range []*recordingPublisher{first, second} {
+			assert.Len(t, p.events, 2)
+			assert.Equal(t, int64(1), p.events[0].ID)
+			assert.Equal(t, EventCreated, p.events[0].Type)
+			assert.Equal(t, EventDeleted, p.events[1].Type)
+		}
+
+		// повторно уже разосланные события не раздаются
+		n, err = d.Dispatch(ctx)
+		assert.NoError(t, err)
+		assert.Zero(t, n)
+		assert.Equal(t, int64(2), outbox.dispatched)
+	})
+
+	t.Run("waits for a gap", func(t *testing.T) {
+		now := time.Now()
+		committed := map[int64]bool{1: true, 3: true}
+		outbox := &memOutbox{visible: func(id int64) bool { return committed[id] }}
+		outbox.append(1, EventCreated, 1)
+		outbox.append(2, EventCreated, 2)
+		outbox.append(3, EventCreated, 3)
+		events := &recordingPublisher{}
+		d := NewEventDispatcher(outbox, nil, []EventPublisher{events})
+		d.now = func() time.Time { return now }
+
+		_, err := d.Dispatch(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, events.events, 1)
+
+		// событие 2 зафиксировано позже события 3, но раздается раньше него
+		committed[2] = true
+		_, err = d.Dispatch(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, events.events, 3)
+		assert.Equal(t, 2, events.events[1].Quote.ID)
+
+		// откаченная транзакция оставляет пропуск навсегда: его ждут не дольше outboxGapTimeout
+		committed[5] = true
+		outbox.append(5, EventDeleted, 1)
+		_, err = d.Dispatch(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, events.events, 3)
+		now = now.Add(outboxGapTimeout)
+		_, err = d.Dispatch(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, events.events, 4)
+	})
+
+	t.Run("run starts after the last event and wakes on notify", func(t *testing.T) {
+		outbox := &memOutbox{}
+		outbox.append(1, EventCreated, 1)
+		events := &syncPublisher{ch: make(chan Event, 1)}
+		notify := make(chanNotifier)
+		d := NewEventDispatcher(outbox, notify, []EventPublisher{events}, WithDispatchInterval(time.Hour))
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			d.Run(runCtx)
+		}()
+
+		// первое уведомление принимается после чтения последнего номера: событие 1 уже было
+		notify <- struct{}{}
+		outbox.append(2, EventApproved, 2)
+		notify <- struct{}{}
+		select {
+		case ev := <-events.ch:
+			assert.Equal(t, int64(2), ev.ID)
+		case <-time.After(time.Second):
+			t.Fatal("событие не разослано")
+		}
+		cancel()
+		<-done
+	})
+
+	t.Run("run resumes from the saved position", func(t *testing.T) {
+		outbox := &memOutbox{dispatched: 1}
+		outbox.append(1, EventCreated, 1)
+		// событие 2 записано, пока ни один диспетчер не работал
+		outbox.append(2, EventApproved, 2)
+		events := &syncPublisher{ch: make(chan Event, 1)}
+		d := NewEventDispatcher(outbox, nil, []EventPublisher{events}, WithDispatchInterval(10*time.Millisecond))
+
+		runCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			d.Run(runCtx)
+		}()
+
+		select {
+		case ev := <-events.ch:
+			assert.Equal(t, int64(2), ev.ID)
+		case <-time.After(time.Second):
+			t.Fatal("пропущенное событие не разослано")
+		}
+		cancel()
+		<-done
+	})
+}
+
+// syncPublisher передает события в канал
+type syncPublisher struct {
+	ch chan Event
+}
+
+func (p *syncPublisher) Publish(ev Event) {
+	p.ch <- ev
+}
+
+func TestEventBroker(t *testing.T) {
+	publish := func(b *EventBroker, n int) {
+		for i := 0; i < n; i++ {
+			b.Publish(Event{Type: EventDeleted, Quote: models.Quote{ID: i + 1}})
+		}
+	}
+
+	t.Run("internal events are not streamed", func(t *testing.T) {
+		b := NewEventBroker(10, 4)
+		sub, _, _ := b.Subscribe(0)
+		defer sub.Close()
+		b.Publish(Event{Type: EventUpdated, Quote: models.Quote{ID: 1}})
+		publish(b, 1)
+		assert.Equal(t, EventDeleted, (<-sub.C).Type)
+	})
+
+	t.Run("fan out", func(t *testing.T) {
+		b := NewEventBroker(10, 4)
+		first, _, _ := b.Subscribe(0)
+		second, _, _ := b.Subscribe(0)
+		publish(b, 2)
+
+		for _, sub := range []*Subscription{first, second} {
+			assert.Equal(t, int64(1), (<-sub.C).ID)
+			assert.Equal(t, int64(2), (<-sub.C).ID)
+		}
+		first.Close()
+		publish(b, 1)
+		assert.Equal(t, int64(3), (<-second.C).ID)
+		_, open := <-first.C
+		assert.False(t, open)
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		b := NewEventBroker(3, 4)
+		publish(b, 5)
+
+		_, missed, ok := b.Subscribe(3)
+		assert.True(t, ok)
+		assert.Len(t, missed, 2)
+		assert.Equal(t, int64(4), missed[0].ID)
+
+		// событие 2 уже вытеснено из истории
+		_, missed, ok = b.Subscribe(1)
+		assert.False(t, ok)
+		assert.Len(t, missed, 3)
+
+		// ID из будущего - сервис перезапускался
+		_, missed, ok = b.Subscribe(100)
+		assert.False(t, ok)
+		assert.Empty(t, missed)
+
+		_, missed, ok = b.Subscribe(5)
+		assert.True(t, ok)
+		assert.Empty(t, missed)
+	})
+
+	t.Run("journal IDs are kept", func(t *testing.T) {
+		b := NewEventBroker(10, 4)
+		sub, _, _ := b.Subscribe(0)
+		defer sub.Close()
+		for _, id := range []int64{41, 42, 42, 45} {
+			b.Publish(Event{ID: id, Type: EventDeleted, Quote: models.Quote{ID: 1}})
+		}
+		assert.Equal(t, int64(41), (<-sub.C).ID)
+		assert.Equal(t, int64(42), (<-sub.C).ID)
+		assert.Equal(t, int64(45), (<-sub.C).ID)
+
+		// номера журнала идут с пропусками, возобновление от них не зависит
+		_, missed, ok := b.Subscribe(42)
+		assert.True(t, ok)
+		assert.Len(t, missed, 1)
+
+		// событий до 41 брокер не видел
+		_, _, ok = b.Subscribe(40)
+		assert.False(t, ok)
+	})
+
+	t.Run("slow subscriber is dropped", func(t *testing.T) {
+		b := NewEventBroker(10, 2)
+		slow, _, _ := b.Subscribe(0)
+		fast, _, _ := b.Subscribe(0)
+
+		received := 0
+		for i := 0; i < 5; i++ {
+			publish(b, 1)
+			<-fast.C
+			received++
+		}
+		assert.Equal(t, 5, received)
+
+		var got []int64
+		for ev := range slow.C {
+			got = append(got, ev.ID)
+		}
+		assert.Equal(t, []int64{1, 2}, got)
+	})
+
+	t.Run("close", func(t *testing.T) {
+		b := NewEventBroker(10, 2)
+		sub, _, _ := b.Subscribe(0)
+		b.Close()
+		_, open := <-sub.C
+		assert.False(t, open)
+		sub.Close()
+	})
+}
+
+type MockLoaderRepository struct {
+	mock.Mock
+}
+
+func (m *MockLoaderRepository) TagsByQuoteIDs(ctx context.Context, ids []int) (map[int][]string, error) {
+	args := m.Called(ctx, ids)
+	return args.Get(0).(map[int][]string), args.Error(1)
+}
+
+func (m *MockLoaderRepository) GetByAuthors(ctx context.Context, authors []string) ([]models.Quote, error) {
+	args := m.Called(ctx, authors)
+	return args.Get(0).([]models.Quote), args.Error(1)
+}
+
+func TestQuoteService_RandomMatching(t *testing.T) {
+	mockQuerier := new(MockQuerier)
+	mockLoaders := new(MockLoaderRepository)
+	svc := NewQuoteService(mockQuerier, WithLoaders(mockLoaders))
+	ctx := context.Background()
+
+	mockQuerier.On("GetByAuthor", ctx, "Seneca", mock.Anything).Return([]models.Quote{
+		{ID: 1, Author: "Seneca"}, {ID: 2, Author: "Seneca"},
+	}, nil)
+	mockLoaders.On("TagsByQuoteIDs", ctx, []int{1, 2}).Return(map[int][]string{2: {"life", "time"}}, nil)
+
+	for i := 0; i < 5; i++ {
+		quote, err := svc.RandomMatching(ctx, QuoteFilter{Author: " Seneca", Tag: "Life"})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, quote.ID)
+	}
+
+	_, err := svc.RandomMatching(ctx, QuoteFilter{Author: "Seneca", Tag: "death"})
+	assert.Equal(t, domain.ErrNotFound, err)
+
+	_, err = NewQuoteService(mockQuerier).RandomMatching(ctx, QuoteFilter{Tag: "life"})
+	assert.Equal(t, domain.ErrNotFound, err)
+}
+
+// memWebhookRepository - вебхуки и исходящая очередь в памяти
+type memWebhookRepository struct {
+	mu         sync.Mutex
+	webhooks   []models.Webhook
+	deliveries []models.WebhookDelivery
+	retryIn    []time.Duration
+	// enqueued - события журнала, уже поставленные в очередь, по вебхукам
+	enqueued map[[2]int64]bool
+}
+
+func (m *memWebhookRepository) CreateWebhook(_ context.Context, webhook *models.Webhook) error {
+	webhook.ID = len(m.webhooks) + 1
+	m.webhooks = append(m.webhooks, *webhook)
+	return nil
+}
+
+func (m *memWebhookRepository) ListWebhooks(context.Context) ([]models.Webhook, error) {
+	return m.webhooks, nil
+}
+
+func (m *memWebhookRepository) GetWebhook(_ context.Context, id int) (*models.Webhook, error) {
+	for _, w := range m.webhooks {
+		if w.ID == id {
+			return &w, nil
+		}
+	}
+	return nil, domain.ErrWebhookNotFound
+}
+
+func (m *memWebhookRepository) DeleteWebhook(context.Context, int) error { return nil }
+
+func (m *memWebhookRepository) EnqueueDeliveries(_ context.Context, eventID int64, event string, payload []byte) error {
+	for _, w := range m.webhooks {
+		if slices.Contains(w.Events, event) {
+			if eventID > 0 {
+				key := [2]int64{int64(w.ID), eventID}
+				if m.enqueued[key] {
+					continue
+				}
+				if m.enqueued == nil {
+					m.enqueued = make(map[[2]int64]bool)
+				}
+				m.enqueued[key] = true
+			}
+			m.deliveries = append(m.deliveries, models.WebhookDelivery{
+				ID: int64(len(m.deliveries) + 1), WebhookID: w.ID, Event: event, Payload: payload, Status: models.DeliveryPending,
+			})
+		}
+	}
+	return nil
+}
+
+// ClaimDeliveries отдает все ожидающие доставки: задержки проверяются по retryIn
+func (m *memWebhookRepository) ClaimDeliveries(_ context.Context, limit int, _ time.Duration) ([]models.DueDelivery, error) {
+	var due []models.DueDelivery
+	for _, d := range m.deliveries {
+		if d.Status == models.DeliveryPending && len(due) < limit {
+			w, _ := m.GetWebhook(context.Background(), d.WebhookID)
+			due = append(due, models.DueDelivery{WebhookDelivery: d, URL: w.URL, Secret: w.Secret})
+		}
+	}
+	return due, nil
+}
+
+func (m *memWebhookRepository) FinishDelivery(_ context.Context, d *models.WebhookDelivery, retryIn time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries[d.ID-1] = *d
+	if d.Status == models.DeliveryPending {
+		m.retryIn = append(m.retryIn, retryIn)
+	}
+	return nil
+}
+
+func (m *memWebhookRepository) ListDeliveries(context.Context, int, int) ([]models.WebhookDelivery, error) {
+	return m.deliveries, nil
+}
+
+func (m *memWebhookRepository) ReplayDelivery(_ context.Context, webhookID int, id int64) (*models.WebhookDelivery, error) {
+	if id > int64(len(m.deliveries)) || m.deliveries[id-1].WebhookID != webhookID {
+		return nil, domain.ErrDeliveryNotFound
+	}
+	d := m.deliveries[id-1]
+	d.ID, d.Status, d.Attempts, d.LastError = int64(len(m.deliveries)+1), models.DeliveryPending, 0, ""
+	m.deliveries = append(m.deliveries, d)
+	return &d, nil
+}
+
+// webhookReceiver - получатель вебхуков, проверяющий подпись; первые fail запросов получают 500
+type webhookReceiver struct {
+	t      *testing.T
+	secret string
+	fail   int
+
+	mu       sync.Mutex
+	received []webhookPayload
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	timestamp, err := strconv.ParseInt(r.Header.Get(WebhookTimestampHeader), 10, 64)
+	assert.NoError(rcv.t, err)
+	assert.Equal(rcv.t, SignWebhook(rcv.secret, timestamp, body), r.Header.Get(WebhookSignatureHeader))
+	assert.NotEmpty(rcv.t, r.Header.Get(WebhookDeliveryHeader))
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	if rcv.fail > 0 {
+		rcv.fail--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var payload webhookPayload
+	assert.NoError(rcv.t, json.Unmarshal(body, &payload))
+	assert.Equal(rcv.t, r.Header.Get(WebhookEventHeader), payload.Event)
+	rcv.received = append(rcv.received, payload)
+}
+
+func TestWebhookService(t *testing.T) {
+	ctx := context.Background()
+	setup := func(fail int) (*memWebhookRepository, *WebhookService, *webhookReceiver) {
+		repo := &memWebhookRepository{}
+		svc := NewWebhookService(repo, WithWebhookRetries(3, time.Second, 3*time.Second), AllowPrivateWebhooks())
+		rcv := &webhookReceiver{t: t, fail: fail}
+		srv := httptest.NewServer(rcv)
+		t.Cleanup(srv.Close)
+
+		webhook, secret, err := svc.Register(ctx, srv.URL+"/hook", []string{EventCreated, EventApproved, EventCreated})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{EventCreated, EventApproved}, webhook.Events)
+		assert.True(t, strings.HasPrefix(secret, "whsec_"))
+		rcv.secret = secret
+		return repo, svc, rcv
+	}
+
+	t.Run("register validation", func(t *testing.T) {
+		svc := NewWebhookService(&memWebhookRepository{})
+		_, _, err := svc.Register(ctx, "ftp://example.com", []string{"updated"})
+		var validationErr *domain.ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+		assert.Equal(t, []string{"url", "events[0]"}, fields(validationErr.Violations))
+	})
+
+	t.Run("delivers signed events", func(t *testing.T) {
+		repo, svc, rcv := setup(0)
+		svc.Publish(Event{Type: EventCreated, Quote: models.Quote{ID: 7, Author: "Seneca"}})
+		svc.Publish(Event{Type: EventDeleted, Quote: models.Quote{ID: 7}}) // вебхук на deleted не подписан
+		assert.Len(t, repo.deliveries, 1)
+
+		n, err := svc.DeliverDue(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, models.DeliveryDelivered, repo.deliveries[0].Status)
+		assert.Equal(t, http.StatusOK, repo.deliveries[0].ResponseStatus)
+		assert.Len(t, rcv.received, 1)
+		assert.Equal(t, EventCreated, rcv.received[0].Event)
+	})
+
+	t.Run("outbox event is enqueued once", func(t *testing.T) {
+		repo, svc, _ := setup(0)
+		ev := Event{ID: 42, Type: EventCreated, Quote: models.Quote{ID: 7}}
+		// событие журнала разносит каждый экземпляр сервиса
+		svc.Publish(ev)
+		svc.Publish(ev)
+		assert.Len(t, repo.deliveries, 1)
+	})
+
+	t.Run("retries with backoff", func(t *testing.T) {
+		repo, svc, rcv := setup(2)
+		svc.Publish(Event{Type: EventApproved, Quote: models.Quote{ID: 7}})
+
+		for i := 0; i < 3; i++ {
+			_, err := svc.DeliverDue(ctx)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, repo.retryIn)
+		assert.Equal(t, models.DeliveryDelivered, repo.deliveries[0].Status)
+		assert.Equal(t, 3, repo.deliveries[0].Attempts)
+		assert.Len(t, rcv.received, 1)
+	})
+
+	t.Run("gives up and replays", func(t *testing.T) {
+		repo, svc, rcv := setup(3)
+		svc.Publish(Event{Type: EventCreated, Quote: models.Quote{ID: 7}})
+
+		for i := 0; i < 4; i++ {
+			_, err := svc.DeliverDue(ctx)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, models.DeliveryFailed, repo.deliveries[0].Status)
+		assert.Equal(t, http.StatusInternalServerError, repo.deliveries[0].ResponseStatus)
+		assert.Contains(t, repo.deliveries[0].LastError, "500")
+		assert.Empty(t, rcv.received)
+
+		replayed, err := svc.Replay(ctx, 1, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), replayed.ID)
+		_, err = svc.DeliverDue(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, models.DeliveryDelivered, repo.deliveries[1].Status)
+		assert.Equal(t, models.DeliveryFailed, repo.deliveries[0].Status)
+		assert.Len(t, rcv.received, 1)
+
+		_, err = svc.Replay(ctx, 2, 1)
+		assert.Equal(t, domain.ErrDeliveryNotFound, err)
+	})
+
+	t.Run("private addresses", func(t *testing.T) {
+		svc := NewWebhookService(&memWebhookRepository{})
+		for _, target := range []string{"http://localhost/hook", "http://127.0.0.1:8080/hook", "http://10.1.2.3/hook",
+			"http://169.254.169.254/latest/meta-data", "http://[::1]/hook", "http://0.0.0.0/hook"} {
+			_, _, err := svc.Register(ctx, target, []string{EventCreated})
+			var validationErr *domain.ValidationError
+			if assert.ErrorAs(t, err, &validationErr, target) {
+				assert.Equal(t, []string{"url"}, fields(validationErr.Violations))
+			}
+		}
+		_, _, err := svc.Register(ctx, "https://cms.example.com/hooks", []string{EventCreated})
+		assert.NoError(t, err)
+	})
+
+	t.Run("private address is refused on connect", func(t *testing.T) {
+		// имя прошло регистрацию, но при доставке указывает на loopback
+		repo := &memWebhookRepository{}
+		svc := NewWebhookService(repo, WithWebhookRetries(3, time.Second, time.Second))
+		rcv := &webhookReceiver{t: t}
+		srv := httptest.NewServer(rcv)
+		defer srv.Close()
+		repo.webhooks = []models.Webhook{{ID: 1, URL: srv.URL, Events: []string{EventCreated}}}
+		svc.Publish(Event{Type: EventCreated, Quote: models.Quote{ID: 7}})
+
+		_, err := svc.DeliverDue(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, models.DeliveryPending, repo.deliveries[0].Status)
+		assert.Contains(t, repo.deliveries[0].LastError, "not allowed")
+		assert.Empty(t, rcv.received)
+	})
+
+	t.Run("lease covers the whole batch", func(t *testing.T) {
+		svc := NewWebhookService(nil, WithWebhookTimeout(10*time.Second))
+		// 20 доставок по 5 одновременно - четыре круга по таймауту попытки
+		assert.Equal(t, 40*time.Second+webhookLeaseMargin, svc.lease())
+	})
+
+	t.Run("batch is sent concurrently", func(t *testing.T) {
+		repo, svc, rcv := setup(0)
+		for i := 1; i <= 7; i++ {
+			svc.Publish(Event{Type: EventCreated, Quote: models.Quote{ID: i}})
+		}
+		n, err := svc.DeliverDue(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, 7, n)
+		for _, d := range repo.deliveries {
+			assert.Equal(t, models.DeliveryDelivered, d.Status)
+		}
+		assert.Len(t, rcv.received, 7)
+	})
+
+	t.Run("backoff is capped", func(t *testing.T) {
+		svc := NewWebhookService(nil, WithWebhookRetries(10, 10*time.Second, time.Minute))
+		var delays []time.Duration
+		for attempts := 1; attempts <= 5; attempts++ {
+			delays = append(delays, svc.backoff(attempts))
+		}
+		assert.Equal(t, []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute}, delays)
+	})
+}
+
+func fields(violations []domain.Violation) []string {
+	var out []string
+	for _, v := range violations {
+		out = append(out, v.Field)
+	}
+	return out
+}
+
+func TestCachedQuerier(t *testing.T) {
+	ctx := context.Background()
+	seneca := models.Quote{ID: 1, Author: "Seneca", Quote: "Luck is preparation", Status: models.StatusApproved}
+	cicero := models.Quote{ID: 2, Author: "Cicero", Quote: "While there is life", Status: models.StatusApproved}
+
+	t.Run("hits, copies and bypass", func(t *testing.T) {
+		mockQuerier := new(MockQuerier)
+		cache := NewCachedQuerier(mockQuerier, 10, time.Minute)
+		mockQuerier.On("GetAll", mock.Anything, []string(nil)).Return([]models.Quote{seneca, cicero}, nil).Once()
+		mockQuerier.On("GetAll", mock.Anything, []string{models.StatusPending}).Return([]models.Quote{}, nil).Twice()
+
+		quotes, err := cache.GetAll(ctx)
+		assert.NoError(t, err)
+		quotes[0].Likes = 100 // изменение выданной копии не попадает в кэш
+		quotes, err = cache.GetAll(ctx)
+		assert.NoError(t, err)
+		assert.Zero(t, quotes[0].Likes)
+
+		random, err := cache.GetRandom(ctx)
+		assert.NoError(t, err)
+		assert.Contains(t, []int{1, 2}, random.ID)
+
+		// запросы со статусами идут мимо кэша
+		_, _ = cache.GetAll(ctx, models.StatusPending)
+		_, _ = cache.GetAll(ctx, models.StatusPending)
+
+		mockQuerier.AssertExpectations(t)
+		assert.Equal(t, CacheStats{Hits: 2, Misses: 1, Entries: 1}, cache.Stats())
+	})
+
+	t.Run("ttl and lru eviction", func(t *testing.T) {
+		mockQuerier := new(MockQuerier)
+		now := time.Now()
+		cache := NewCachedQuerier(mockQuerier, 2, time.Minute)
+		cache.now = func() time.Time { return now }
+		mockQuerier.On("GetByAuthor", mock.Anything, "Seneca", []string(nil)).Return([]models.Quote{seneca}, nil).Times(3)
+		mockQuerier.On("GetByAuthor", mock.Anything, "Cicero", []string(nil)).Return([]models.Quote{cicero}, nil).Once()
+		mockQuerier.On("GetAll", mock.Anything, []string(nil)).Return([]models.Quote{seneca, cicero}, nil).Once()
+
+		_, _ = cache.GetByAuthor(ctx, "Seneca")
+		now = now.Add(time.Minute)
+		_, _ = cache.GetByAuthor(ctx, "Seneca") // срок истек
+
+		_, _ = cache.GetByAuthor(ctx, "Cicero")
+		_, _ = cache.GetAll(ctx) // вытесняет Seneca, которого дольше всех не запрашивали
+		_, _ = cache.GetByAuthor(ctx, "Cicero")
+		_, _ = cache.GetByAuthor(ctx, "Seneca")
+
+		mockQuerier.AssertExpectations(t)
+		assert.Equal(t, int64(2), cache.Stats().Evictions)
+	})
+
+	t.Run("writes invalidate only affected lists", func(t *testing.T) {
+		mockQuerier := new(MockQuerier)
+		cache := NewCachedQuerier(mockQuerier, 10, time.Minute)
+		mockQuerier.On("GetAll", mock.Anything, []string(nil)).Return([]models.Quote{seneca, cicero}, nil)
+		mockQuerier.On("GetByAuthor", mock.Anything, "Seneca", []string(nil)).Return([]models.Quote{seneca}, nil)
+		mockQuerier.On("GetByAuthor", mock.Anything, "Cicero", []string(nil)).Return([]models.Quote{cicero}, nil)
+		mockQuerier.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mockQuerier.On("Delete", mock.Anything, 2).Return(nil)
+		warm := func() {
+			_, _ = cache.GetAll(ctx)
+			_, _ = cache.GetByAuthor(ctx, "Seneca")
+			_, _ = cache.GetByAuthor(ctx, "Cicero")
+		}
+		keys := func() []string {
+			var keys []string
+			for key := range cache.entries {
+				keys = append(keys, key)
+			}
+			slices.Sort(keys)
+			return keys
+		}
+
+		warm()
+		// цитата на модерации не видна в списках
+		assert.NoError(t, cache.Create(ctx, &models.Quote{Author: "Seneca", Status: models.StatusPending}))
+		assert.Len(t, keys(), 3)
+		assert.NoError(t, cache.Create(ctx, &models.Quote{Author: "Seneca", Status: models.StatusApproved}))
+		assert.Equal(t, []string{"author:Cicero"}, keys())
+
+		warm()
+		assert.NoError(t, cache.Delete(ctx, 2))
+		assert.Equal(t, []string{"author:Seneca"}, keys())
+
+		warm()
+		cache.Publish(Event{Type: EventApproved, Quote: models.Quote{ID: 3, Author: "Cicero"}})
+		assert.Equal(t, []string{"author:Seneca"}, keys())
+		cache.Publish(Event{Type: EventDeleted, Quote: models.Quote{ID: 1}})
+		assert.Empty(t, keys())
+
+		// правка на другом экземпляре: сбрасываются списки с цитатой и список нового автора
+		warm()
+		cache.Publish(Event{Type: EventUpdated, Quote: models.Quote{ID: 2, Author: "Seneca"}})
+		assert.Empty(t, keys())
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		mockQuerier := new(MockQuerier)
+		cache := NewCachedQuerier(mockQuerier, 10, time.Minute)
+		tx := cache.Transactions(&fakeTransactor{})
+		created := models.Quote{ID: 3, Author: "Seneca", Quote: "Uncommitted", Status: models.StatusApproved}
+		mockQuerier.On("GetAll", mock.Anything, []string(nil)).Return([]models.Quote{seneca}, nil).Once()
+		mockQuerier.On("GetAll", mock.Anything, []string(nil)).Return([]models.Quote{seneca, created}, nil).Twice()
+		mockQuerier.On("Create", mock.Anything, mock.Anything).Return(nil)
+
+		_, _ = cache.GetAll(ctx)
+		err := tx.InTx(ctx, func(ctx context.Context) error {
+			assert.NoError(t, cache.Create(ctx, &created))
+			// до фиксации другие запросы видят прежний список
+			quotes, _ := cache.GetAll(context.Background())
+			assert.Len(t, quotes, 1)
+			// чтение в транзакции идет мимо кэша и не сохраняется
+			quotes, _ = cache.GetAll(ctx)
+			assert.Len(t, quotes, 2)
+			assert.Len(t, cache.entries, 1)
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, cache.entries)
+
+		// откаченная транзакция кэш не сбрасывает
+		_, _ = cache.GetAll(ctx)
+		err = tx.InTx(ctx, func(ctx context.Context) error {
+			assert.NoError(t, cache.Create(ctx, &created))
+			return errBatchAborted
+		})
+		assert.ErrorIs(t, err, errBatchAborted)
+		assert.Len(t, cache.entries, 1)
+		mockQuerier.AssertExpectations(t)
+	})
+}
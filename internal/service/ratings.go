@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"time"
+)
+
+const (
+	MinRating = 1
+	MaxRating = 5
+
+	defaultTopLimit = 10
+	maxTopLimit     = 100
+)
+
+type RatingRepository interface {
+	Rate(ctx context.Context, quoteID int, userID string, rating int) error
+	Like(ctx context.Context, quoteID int, userID string) error
+	Unlike(ctx context.Context, quoteID int, userID string) error
+	Stats(ctx context.Context, ids []int) (map[int]models.RatingStats, error)
+	Top(ctx context.Context, since time.Time, limit int) ([]models.Quote, error)
+}
+
+// topPeriods задает окно, за которое считается рейтинг в GET /quotes/top
+var topPeriods = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+}
+
+// QuoteLookup находит цитату, чтобы проверить, видна ли она клиенту
+type QuoteLookup interface {
+	GetByID(ctx context.Context, id int) (*models.Quote, error)
+}
+
+type RatingService struct {
+	repo   RatingRepository
+	quotes QuoteLookup
+	now    func() time.Time
+}
+
+func NewRatingService(repo RatingRepository, quotes QuoteLookup) *RatingService {
+	return &RatingService{repo: repo, quotes: quotes, now: time.Now}
+}
+
+// visible возвращает ErrNotFound для цитаты, которую клиент не видит: оценивать
+// неодобренные цитаты могут только их автор и модераторы
+func (s *RatingService) visible(ctx context.Context, quoteID int) error {
+	quote, err := s.quotes.GetByID(ctx, quoteID)
+	if err != nil {
+		return err
+	}
+	if !canSee(ctx, quote) {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (s *RatingService) Rate(ctx context.Context, quoteID int, userID string, rating int) (models.RatingStats, error) {
+	if quoteID <= 0 || userID == "" || rating < MinRating || rating > MaxRating {
+		return models.RatingStats{}, domain.ErrInvalidInput
+	}
+	if err := s.visible(ctx, quoteID); err != nil {
+		return models.RatingStats{}, err
+	}
+	if err := s.repo.Rate(ctx, quoteID, userID, rating); err != nil {
+		return models.RatingStats{}, err
+	}
+	return s.stats(ctx, quoteID)
+}
+
+func (s *RatingService) Like(ctx context.Context, quoteID int, userID string) (models.RatingStats, error) {
+	if quoteID <= 0 || userID == "" {
+		return models.RatingStats{}, domain.ErrInvalidInput
+	}
+	if err := s.visible(ctx, quoteID); err != nil {
+		return models.RatingStats{}, err
+	}
+	if err := s.repo.Like(ctx, quoteID, userID); err != nil {
+		return models.RatingStats{}, err
+	}
+	return s.stats(ctx, quoteID)
+}
+
+func (s *RatingService) Unlike(ctx context.Context, quoteID int, userID string) (models.RatingStats, error) {
+	if quoteID <= 0 || userID == "" {
+		return models.RatingStats{}, domain.ErrInvalidInput
+	}
+	if err := s.visible(ctx, quoteID); err != nil {
+		return models.RatingStats{}, err
+	}
+	if err := s.repo.Unlike(ctx, quoteID, userID); err != nil {
+		return models.RatingStats{}, err
+	}
+	return s.stats(ctx, quoteID)
+}
+
+// Top возвращает лучшие цитаты за период: day, week, month, year или all
+func (s *RatingService) Top(ctx context.Context, period string, limit int) ([]models.Quote, error) {
+	since := time.Time{}
+	if period != "" && period != "all" {
+		window, ok := topPeriods[period]
+		if !ok {
+			return nil, domain.ErrInvalidInput
+		}
+		since = s.now().Add(-window)
+	}
+	if limit <= 0 {
+		limit = defaultTopLimit
+	}
+	if limit > maxTopLimit {
+		limit = maxTopLimit
+	}
+	return s.repo.Top(ctx, since, limit)
+}
+
+func (s *RatingService) stats(ctx context.Context, quoteID int) (models.RatingStats, error) {
+	stats, err := s.repo.Stats(ctx, []int{quoteID})
+	if err != nil {
+		return models.RatingStats{}, err
+	}
+	st, ok := stats[quoteID]
+	if !ok {
+		return models.RatingStats{}, domain.ErrNotFound
+	}
+	return st, nil
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"context"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/pkg/textsim"
+	"sort"
+)
+
+const (
+	defaultSimilarLimit = 5
+	maxSimilarLimit     = 50
+
+	// similarCandidates - сколько цитат с общими словами сравнивается по TF-IDF
+	similarCandidates = 200
+
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// TermIndex - индекс слов цитат, который Storage обновляет при создании и удалении
+type TermIndex interface {
+	TermVector(ctx context.Context, quoteID int) (map[string]int, error)
+	TermNeighbors(ctx context.Context, quoteID int, limit int) (map[int]map[string]int, error)
+	TermStats(ctx context.Context, terms []string) (map[string]int, int, error)
+	TermMatches(ctx context.Context, terms []string, limit int) (map[int]map[string]int, error)
+	GetByIDs(ctx context.Context, ids []int) ([]models.Quote, error)
+}
+
+// WithSimilarity включает поиск похожих цитат по индексу слов
+func WithSimilarity(index TermIndex) Option {
+	return func(s *QuoteService) {
+		s.terms = index
+	}
+}
+
+// Similar возвращает цитаты, ближайшие к заданной по косинусному сходству векторов TF-IDF
+func (s *QuoteService) Similar(ctx context.Context, id int, limit int) ([]models.Quote, error) {
+	if id <= 0 {
+		return nil, domain.ErrInvalidInput
+	}
+	if s.terms == nil {
+		return nil, domain.ErrNotFound
+	}
+	if limit <= 0 {
+		limit = defaultSimilarLimit
+	}
+	if limit > maxSimilarLimit {
+		limit = maxSimilarLimit
+	}
+	quote, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// неодобренная цитата, как и в GetByID, видна только ее автору и модераторам
+	if !canSee(ctx, quote) {
+		return nil, domain.ErrNotFound
+	}
+
+	target, err := s.terms.TermVector(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(target) == 0 {
+		return []models.Quote{}, nil
+	}
+	neighbors, err := s.terms.TermNeighbors(ctx, id, similarCandidates)
+	if err != nil {
+		return nil, err
+	}
+	return s.rankByTerms(ctx, target, neighbors, limit)
+}
+
+// Search ищет одобренные цитаты по словам запроса и упорядочивает их по сходству TF-IDF с запросом
+func (s *QuoteService) Search(ctx context.Context, query string, limit int) ([]models.Quote, error) {
+	if s.terms == nil {
+		return nil, domain.ErrSearchDisabled
+	}
+	target := textsim.Terms(query)
+	if len(target) == 0 {
+		return nil, domain.ErrInvalidInput
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	matches, err := s.terms.TermMatches(ctx, termList(target), similarCandidates)
+	if err != nil {
+		return nil, err
+	}
+	return s.rankByTerms(ctx, target, matches, limit)
+}
+
+// rankByTerms возвращает не больше limit цитат-кандидатов, ближайших к target по косинусному сходству TF-IDF
+func (s *QuoteService) rankByTerms(ctx context.Context, target map[string]int, neighbors map[int]map[string]int, limit int) ([]models.Quote, error) {
+	if len(neighbors) == 0 {
+		return []models.Quote{}, nil
+	}
+
+	vocabulary := make(map[string]struct{}, len(target))
+	for term := range target {
+		vocabulary[term] = struct{}{}
+	}
+	for _, vector := range neighbors {
+		for term := range vector {
+			vocabulary[term] = struct{}{}
+		}
+	}
+	terms := make([]string, 0, len(vocabulary))
+	for term := range vocabulary {
+		terms = append(terms, term)
+	}
+	df, total, err := s.terms.TermStats(ctx, terms)
+	if err != nil {
+		return nil, err
+	}
+
+	type scored struct {
+		id    int
+		score float64
+	}
+	weights := textsim.Weights(target, df, total)
+	ranked := make([]scored, 0, len(neighbors))
+	for neighborID, vector := range neighbors {
+		score := textsim.Cosine(weights, textsim.Weights(vector, df, total))
+		if score > 0 {
+			ranked = append(ranked, scored{id: neighborID, score: score})
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].id < ranked[j].id
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	ids := make([]int, len(ranked))
+	for i, r := range ranked {
+		ids[i] = r.id
+	}
+	found, err := s.terms.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]models.Quote, len(found))
+	for _, q := range found {
+		byID[q.ID] = q
+	}
+	quotes := make([]models.Quote, 0, len(ids))
+	for _, id := range ids {
+		if q, ok := byID[id]; ok {
+			quotes = append(quotes, q)
+		}
+	}
+	if err := s.enrich(ctx, quotes); err != nil {
+		return nil, err
+	}
+	return quotes, nil
+}
+
+// termList возвращает слова вектора в алфавитном порядке
+func termList(vector map[string]int) []string {
+	terms := make([]string, 0, len(vector))
+	for term := range vector {
+		terms = append(terms, term)
+	}
+	sort.Strings(terms)
+	return terms
+}
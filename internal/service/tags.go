@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	maxTags      = 10
+	maxTagLength = 32
+)
+
+// LoaderRepository - выборки сразу для многих цитат и авторов, чтобы вложенные поля
+// (теги, цитаты автора) загружались одним запросом на уровень, а не запросом на элемент
+type LoaderRepository interface {
+	TagsByQuoteIDs(ctx context.Context, ids []int) (map[int][]string, error)
+	GetByAuthors(ctx context.Context, authors []string) ([]models.Quote, error)
+}
+
+// WithLoaders включает пакетную загрузку тегов и цитат авторов
+func WithLoaders(repo LoaderRepository) Option {
+	return func(s *QuoteService) {
+		s.loaders = repo
+	}
+}
+
+// TagsByQuoteIDs возвращает теги цитат одним запросом
+func (s *QuoteService) TagsByQuoteIDs(ctx context.Context, ids []int) (map[int][]string, error) {
+	if s.loaders == nil || len(ids) == 0 {
+		return map[int][]string{}, nil
+	}
+	return s.loaders.TagsByQuoteIDs(ctx, ids)
+}
+
+// QuotesByAuthors возвращает одобренные цитаты авторов, сгруппированные по автору, с агрегатами
+func (s *QuoteService) QuotesByAuthors(ctx context.Context, authors []string) (map[string][]models.Quote, error) {
+	if s.loaders == nil {
+		return nil, domain.ErrNotFound
+	}
+	byAuthor := make(map[string][]models.Quote, len(authors))
+	if len(authors) == 0 {
+		return byAuthor, nil
+	}
+	quotes, err := s.loaders.GetByAuthors(ctx, authors)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enrich(ctx, quotes); err != nil {
+		return nil, err
+	}
+	for _, q := range quotes {
+		byAuthor[q.Author] = append(byAuthor[q.Author], q)
+	}
+	return byAuthor, nil
+}
+
+// normalizeTags приводит теги к нижнему регистру, убирает пустые и повторы и сортирует их
+func normalizeTags(tags []string) ([]string, []domain.Violation) {
+	var violations []domain.Violation
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for i, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(norm.NFC.String(tag)))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if utf8.RuneCountInString(tag) > maxTagLength {
+			violations = append(violations, domain.Violation{
+				Field:   fmt.Sprintf("tags[%d]", i),
+				Code:    ViolationTooLong,
+				Message: fmt.Sprintf("must be at most %d characters", maxTagLength),
+			})
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	if len(normalized) > maxTags {
+		violations = append(violations, domain.Violation{
+			Field:   "tags",
+			Code:    ViolationTooLong,
+			Message: fmt.Sprintf("must contain at most %d tags", maxTags),
+		})
+	}
+	sort.Strings(normalized)
+	return normalized, violations
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/pkg/logger"
+	"sync"
+	"time"
+)
+
+// Источники выдачи цитаты, для которых ведутся счетчики
+const (
+	ViewSourceGet    = "get"
+	ViewSourceRandom = "random"
+	ViewSourceDaily  = "daily"
+)
+
+const (
+	flushTimeout = 5 * time.Second
+
+	// defaultFlushInterval используется, если интервал сброса не задан или не положителен
+	defaultFlushInterval = 10 * time.Second
+)
+
+type ViewRepository interface {
+	FlushViews(ctx context.Context, deltas map[int]models.ViewStats) error
+	ViewStats(ctx context.Context, ids []int) (map[int]models.ViewStats, error)
+	MostViewed(ctx context.Context, source string, limit int) ([]models.Quote, error)
+}
+
+// ViewCounter копит счетчики выдачи в памяти и периодически сбрасывает их в БД одной пачкой,
+// чтобы чтение цитаты не превращалось в запись на каждый запрос
+type ViewCounter struct {
+	repo ViewRepository
+
+	mu      sync.Mutex
+	pending map[int]models.ViewStats
+}
+
+func NewViewCounter(repo ViewRepository) *ViewCounter {
+	return &ViewCounter{
+		repo:    repo,
+		pending: make(map[int]models.ViewStats),
+	}
+}
+
+func (c *ViewCounter) Record(id int, source string) {
+	var delta models.ViewStats
+	switch source {
+	case ViewSourceGet:
+		delta.Get = 1
+	case ViewSourceRandom:
+		delta.Random = 1
+	case ViewSourceDaily:
+		delta.Daily = 1
+	default:
+		return
+	}
+
+	c.mu.Lock()
+	c.pending[id] = c.pending[id].Add(delta)
+	c.mu.Unlock()
+}
+
+// Flush сбрасывает накопленные счетчики. При ошибке они возвращаются в буфер до следующей попытки.
+func (c *ViewCounter) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	batch := c.pending
+	c.pending = make(map[int]models.ViewStats, len(batch))
+	c.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	if err := c.repo.FlushViews(ctx, batch); err != nil {
+		c.mu.Lock()
+		for id, delta := range batch {
+			c.pending[id] = c.pending[id].Add(delta)
+		}
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Run сбрасывает счетчики каждые interval до отмены ctx, после чего делает последний сброс.
+// Нулевой или отрицательный interval заменяется на defaultFlushInterval.
+func (c *ViewCounter) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Flush(ctx); err != nil {
+				logger.Errorf("Ошибка сброса счетчиков просмотров: %v", err)
+			}
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+			if err := c.Flush(flushCtx); err != nil {
+				logger.Errorf("Ошибка сброса счетчиков просмотров: %v", err)
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+// Stats возвращает сохраненные счетчики вместе с еще не сброшенными
+func (c *ViewCounter) Stats(ctx context.Context, ids []int) (map[int]models.ViewStats, error) {
+	views, err := c.repo.ViewStats(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range ids {
+		if delta, ok := c.pending[id]; ok {
+			views[id] = views[id].Add(delta)
+		}
+	}
+	return views, nil
+}
+
+// MostViewed возвращает самые часто выдаваемые цитаты, source - get, random, daily или пусто для суммы
+func (c *ViewCounter) MostViewed(ctx context.Context, source string, limit int) ([]models.Quote, error) {
+	if source != "" && source != ViewSourceGet && source != ViewSourceRandom && source != ViewSourceDaily {
+		return nil, domain.ErrInvalidInput
+	}
+	if limit <= 0 {
+		limit = defaultTopLimit
+	}
+	if limit > maxTopLimit {
+		limit = maxTopLimit
+	}
+	quotes, err := c.repo.MostViewed(ctx, source, limit)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range quotes {
+		quotes[i].Views = quotes[i].Views.Add(c.pending[quotes[i].ID])
+	}
+	return quotes, nil
+}
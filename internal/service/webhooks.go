@@ -0,0 +1,428 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"quote-service/internal/domain"
+	"quote-service/internal/models"
+	"quote-service/pkg/logger"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// Заголовки доставки вебхука
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+const (
+	webhookEnqueueTimeout = 5 * time.Second
+	webhookBatchSize      = 20
+	// webhookWorkers - сколько доставок пачки отправляются одновременно
+	webhookWorkers = 5
+	// webhookLeaseMargin - запас аренды сверх времени отправки пачки на запись итогов в БД
+	webhookLeaseMargin = 30 * time.Second
+	// maxErrorLength ограничивает текст ошибки в журнале доставок
+	maxErrorLength = 500
+)
+
+// WebhookEvents - события, на которые можно подписать вебхук
+var WebhookEvents = []string{EventCreated, EventDeleted, EventApproved}
+
+// Коды нарушений при регистрации вебхука
+const (
+	ViolationInvalidURL   = "invalid_url"
+	ViolationInvalidEvent = "invalid_event"
+	violationRequired     = "required"
+)
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	ListWebhooks(ctx context.Context) ([]models.Webhook, error)
+	GetWebhook(ctx context.Context, id int) (*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int) error
+	// EnqueueDeliveries ставит в очередь доставку события всем вебхукам, подписанным на него.
+	// Событие журнала с номером eventID > 0 ставится в очередь вебхука только один раз.
+	EnqueueDeliveries(ctx context.Context, eventID int64, event string, payload []byte) error
+	// ClaimDeliveries берет в работу до limit доставок, срок которых наступил, и откладывает их на lease
+	ClaimDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.DueDelivery, error)
+	// FinishDelivery записывает итог попытки; retryIn - задержка следующей попытки для статуса pending
+	FinishDelivery(ctx context.Context, d *models.WebhookDelivery, retryIn time.Duration) error
+	ListDeliveries(ctx context.Context, webhookID, limit int) ([]models.WebhookDelivery, error)
+	// ReplayDelivery ставит копию доставки в очередь и возвращает ее
+	ReplayDelivery(ctx context.Context, webhookID int, id int64) (*models.WebhookDelivery, error)
+}
+
+// webhookPayload - тело запроса к вебхуку
+type webhookPayload struct {
+	Event      string      `json:"event"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Quote      interface{} `json:"quote"`
+}
+
+// WebhookService регистрирует вебхуки и доставляет им события. Publish только записывает доставки
+// в исходящую очередь в БД, отправляет их Run: неудачная попытка повторяется с экспоненциальной
+// задержкой, после maxAttempts попыток доставка помечается failed и остается в журнале.
+type WebhookService struct {
+	repo    WebhookRepository
+	client  *http.Client
+	timeout time.Duration
+	now     func() time.Time
+	// allowPrivate разрешает адреса в локальных и внутренних сетях
+	allowPrivate bool
+
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+}
+
+type WebhookOption func(*WebhookService)
+
+// WithWebhookRetries задает число попыток и границы экспоненциальной задержки между ними
+func WithWebhookRetries(maxAttempts int, base, max time.Duration) WebhookOption {
+	return func(s *WebhookService) {
+		s.maxAttempts = maxAttempts
+		s.baseBackoff = base
+		s.maxBackoff = max
+	}
+}
+
+// WithWebhookClient задает HTTP-клиент доставки. Проверку адресов при соединении клиент
+// по умолчанию выполняет сам, заданный клиент отвечает за нее сам.
+func WithWebhookClient(client *http.Client) WebhookOption {
+	return func(s *WebhookService) {
+		s.client = client
+	}
+}
+
+// WithWebhookTimeout ограничивает время одной попытки доставки
+func WithWebhookTimeout(timeout time.Duration) WebhookOption {
+	return func(s *WebhookService) {
+		if timeout > 0 {
+			s.timeout = timeout
+		}
+	}
+}
+
+// AllowPrivateWebhooks разрешает вебхуки на loopback, частные и link-local адреса.
+// Без него такие адреса отклоняются при регистрации и при соединении, чтобы через вебхук
+// нельзя было обратиться к внутренним сервисам.
+func AllowPrivateWebhooks() WebhookOption {
+	return func(s *WebhookService) {
+		s.allowPrivate = true
+	}
+}
+
+func NewWebhookService(repo WebhookRepository, opts ...WebhookOption) *WebhookService {
+	s := &WebhookService{
+		repo:        repo,
+		timeout:     10 * time.Second,
+		now:         time.Now,
+		maxAttempts: 8,
+		baseBackoff: 10 * time.Second,
+		maxBackoff:  time.Hour,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.client == nil {
+		s.client = s.defaultClient()
+	}
+	return s
+}
+
+// defaultClient проверяет адрес при каждом соединении: имя могло начать указывать
+// на внутренний адрес после регистрации, а ответ - перенаправить на него
+func (s *WebhookService) defaultClient() *http.Client {
+	dialer := &net.Dialer{Timeout: s.timeout}
+	if !s.allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if addr, err := netip.ParseAddr(host); err != nil || !publicAddr(addr) {
+				return fmt.Errorf("webhook address %s is not allowed", host)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: transport}
+}
+
+// publicAddr сообщает, что адрес не относится к loopback, частным, link-local и служебным сетям
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !addr.IsLoopback() && !addr.IsLinkLocalUnicast()
+}
+
+// checkWebhookHost отклоняет при регистрации адреса во внутренних сетях, записанные явно
+func (s *WebhookService) checkWebhookHost(host string) bool {
+	if s.allowPrivate {
+		return true
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return publicAddr(addr)
+	}
+	return true
+}
+
+// Register сохраняет вебхук. Секрет подписи возвращается только здесь.
+func (s *WebhookService) Register(ctx context.Context, rawURL string, events []string) (*models.Webhook, string, error) {
+	var violations []domain.Violation
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		violations = append(violations, domain.Violation{Field: "url", Code: ViolationInvalidURL, Message: "must be an absolute http or https URL"})
+	} else if !s.checkWebhookHost(u.Hostname()) {
+		violations = append(violations, domain.Violation{Field: "url", Code: ViolationInvalidURL, Message: "must not point to a local or private network"})
+	}
+	if len(events) == 0 {
+		violations = append(violations, domain.Violation{Field: "events", Code: violationRequired, Message: "is required"})
+	}
+	var subscribed []string
+	for i, event := range events {
+		if !slices.Contains(WebhookEvents, event) {
+			violations = append(violations, domain.Violation{
+				Field:   fmt.Sprintf("events[%d]", i),
+				Code:    ViolationInvalidEvent,
+				Message: "must be one of " + strings.Join(WebhookEvents, ", "),
+			})
+			continue
+		}
+		if !slices.Contains(subscribed, event) {
+			subscribed = append(subscribed, event)
+		}
+	}
+	if len(violations) > 0 {
+		return nil, "", &domain.ValidationError{Message: "request validation failed", Violations: violations}
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	webhook := &models.Webhook{URL: u.String(), Events: subscribed, Secret: secret}
+	if err := s.repo.CreateWebhook(ctx, webhook); err != nil {
+		return nil, "", err
+	}
+	return webhook, secret, nil
+}
+
+func (s *WebhookService) List(ctx context.Context) ([]models.Webhook, error) {
+	return s.repo.ListWebhooks(ctx)
+}
+
+func (s *WebhookService) Get(ctx context.Context, id int) (*models.Webhook, error) {
+	if id <= 0 {
+		return nil, domain.ErrInvalidInput
+	}
+	return s.repo.GetWebhook(ctx, id)
+}
+
+// Delete удаляет вебхук вместе с очередью и журналом его доставок
+func (s *WebhookService) Delete(ctx context.Context, id int) error {
+	if id <= 0 {
+		return domain.ErrInvalidInput
+	}
+	return s.repo.DeleteWebhook(ctx, id)
+}
+
+// Deliveries возвращает журнал доставок вебхука, начиная с последних
+func (s *WebhookService) Deliveries(ctx context.Context, webhookID, limit int) ([]models.WebhookDelivery, error) {
+	if _, err := s.Get(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 100 {
+		return nil, domain.ErrInvalidInput
+	}
+	return s.repo.ListDeliveries(ctx, webhookID, limit)
+}
+
+// Replay повторно отправляет событие из журнала новой доставкой, исходная запись не меняется
+func (s *WebhookService) Replay(ctx context.Context, webhookID int, deliveryID int64) (*models.WebhookDelivery, error) {
+	if webhookID <= 0 || deliveryID <= 0 {
+		return nil, domain.ErrInvalidInput
+	}
+	return s.repo.ReplayDelivery(ctx, webhookID, deliveryID)
+}
+
+// Publish ставит событие в очередь доставки. Ошибка записи только журналируется:
+// изменение цитаты уже сохранено и не должно откатываться из-за вебхуков.
+// Событие журнала разносят все экземпляры сервиса, но в очередь оно попадает один раз.
+func (s *WebhookService) Publish(ev Event) {
+	if !slices.Contains(WebhookEvents, ev.Type) {
+		return
+	}
+	var quote interface{} = ev.Quote
+	if ev.Type == EventDeleted {
+		quote = map[string]int{"id": ev.Quote.ID}
+	}
+	payload, err := json.Marshal(webhookPayload{Event: ev.Type, OccurredAt: ev.Time, Quote: quote})
+	if err != nil {
+		logger.Errorf("Ошибка кодирования события вебхука: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookEnqueueTimeout)
+	defer cancel()
+	if err := s.repo.EnqueueDeliveries(ctx, ev.ID, ev.Type, payload); err != nil {
+		logger.Errorf("Ошибка постановки события %s в очередь вебхуков: %v", ev.Type, err)
+	}
+}
+
+// Run отправляет доставки из очереди каждые interval, пока ctx не отменен
+func (s *WebhookService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				n, err := s.DeliverDue(ctx)
+				if err != nil {
+					logger.Errorf("Ошибка доставки вебхуков: %v", err)
+				}
+				// Полная пачка означает, что в очереди могут быть еще доставки
+				if err != nil || n < webhookBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+// DeliverDue выполняет одну попытку для доставок, срок которых наступил, и возвращает их число.
+// Доставки пачки отправляются по webhookWorkers одновременно, а аренда рассчитана на отправку
+// всей пачки с таймаутом каждой попытки, поэтому другой экземпляр не возьмет их повторно.
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	due, err := s.repo.ClaimDeliveries(ctx, webhookBatchSize, s.lease())
+	if err != nil {
+		return 0, err
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(webhookWorkers)
+	for i := range due {
+		d := &due[i]
+		g.Go(func() error {
+			return s.deliver(gctx, d)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return 0, err
+	}
+	return len(due), nil
+}
+
+// lease - на сколько доставки откладываются, пока их отправляет этот экземпляр сервиса
+func (s *WebhookService) lease() time.Duration {
+	rounds := (webhookBatchSize + webhookWorkers - 1) / webhookWorkers
+	return time.Duration(rounds)*s.timeout + webhookLeaseMargin
+}
+
+// deliver выполняет одну попытку и записывает ее итог
+func (s *WebhookService) deliver(ctx context.Context, d *models.DueDelivery) error {
+	status, err := s.send(ctx, d)
+	d.Attempts++
+	d.ResponseStatus = status
+	if err == nil {
+		d.Status = models.DeliveryDelivered
+		d.LastError = ""
+	} else {
+		d.Status = models.DeliveryPending
+		d.LastError = truncate(err.Error(), maxErrorLength)
+		if d.Attempts >= s.maxAttempts {
+			d.Status = models.DeliveryFailed
+		}
+	}
+	return s.repo.FinishDelivery(ctx, &d.WebhookDelivery, s.backoff(d.Attempts))
+}
+
+// send отправляет доставку; успехом считается любой ответ 2xx
+func (s *WebhookService) send(ctx context.Context, d *models.DueDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	timestamp := s.now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "quote-service-webhooks")
+	req.Header.Set(WebhookEventHeader, d.Event)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(d.Secret, timestamp, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff - задержка перед попыткой attempts+1: base, 2*base, 4*base, ... но не больше max
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	delay := s.baseBackoff
+	for i := 1; i < attempts && delay < s.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.maxBackoff)
+}
+
+// SignWebhook возвращает подпись доставки: HMAC-SHA256 от "<timestamp>.<тело>" на секрете вебхука.
+// Получатель вычисляет ее так же и сравнивает с заголовком X-Webhook-Signature.
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
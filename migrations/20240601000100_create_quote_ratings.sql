@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS quote_ratings (
+    quote_id INTEGER NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (quote_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quote_ratings_updated_at ON quote_ratings (updated_at);
+
+CREATE TABLE IF NOT EXISTS quote_likes (
+    quote_id INTEGER NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (quote_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quote_likes_created_at ON quote_likes (created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS quote_likes;
+DROP TABLE IF EXISTS quote_ratings;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS quote_views (
+    quote_id INTEGER PRIMARY KEY REFERENCES quotes(id) ON DELETE CASCADE,
+    get_count BIGINT NOT NULL DEFAULT 0,
+    random_count BIGINT NOT NULL DEFAULT 0,
+    daily_count BIGINT NOT NULL DEFAULT 0,
+    last_served_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS quote_views;
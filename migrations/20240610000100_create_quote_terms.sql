@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS quote_terms (
+    quote_id INTEGER NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    term VARCHAR(255) NOT NULL,
+    freq INTEGER NOT NULL,
+    PRIMARY KEY (quote_id, term)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quote_terms_term ON quote_terms (term);
+
+-- Уже сохраненные цитаты индексирует сервис при запуске (Storage.BackfillTerms):
+-- разбор текста на слова (textsim.Terms) должен совпадать с тем, что используется при создании цитат
+
+-- +goose Down
+DROP TABLE IF EXISTS quote_terms;
@@ -0,0 +1,10 @@
+-- +goose Up
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS owner_id VARCHAR(255);
+CREATE INDEX IF NOT EXISTS idx_quotes_owner_id ON quotes (owner_id);
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS roles TEXT[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE api_keys DROP COLUMN IF EXISTS roles;
+DROP INDEX IF EXISTS idx_quotes_owner_id;
+ALTER TABLE quotes DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,14 @@
+-- +goose Up
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'approved'
+    CHECK (status IN ('pending', 'approved', 'rejected'));
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS moderation_reason TEXT;
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS moderated_by VARCHAR(255);
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS moderated_at TIMESTAMP;
+CREATE INDEX IF NOT EXISTS idx_quotes_status ON quotes (status);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_quotes_status;
+ALTER TABLE quotes DROP COLUMN IF EXISTS moderated_at;
+ALTER TABLE quotes DROP COLUMN IF EXISTS moderated_by;
+ALTER TABLE quotes DROP COLUMN IF EXISTS moderation_reason;
+ALTER TABLE quotes DROP COLUMN IF EXISTS status;
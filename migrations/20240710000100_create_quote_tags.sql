@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS quote_tags (
+    quote_id INTEGER NOT NULL REFERENCES quotes(id) ON DELETE CASCADE,
+    tag VARCHAR(32) NOT NULL,
+    PRIMARY KEY (quote_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quote_tags_tag ON quote_tags (tag);
+CREATE INDEX IF NOT EXISTS idx_quotes_author ON quotes (author);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_quotes_author;
+DROP TABLE IF EXISTS quote_tags;
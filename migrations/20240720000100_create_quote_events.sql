@@ -0,0 +1,20 @@
+-- +goose Up
+-- Журнал событий (transactional outbox): строка пишется в транзакции изменения цитаты
+CREATE TABLE IF NOT EXISTS quote_events (
+    id BIGSERIAL PRIMARY KEY,
+    type VARCHAR(32) NOT NULL,
+    quote_id INTEGER NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_quote_events_created_at ON quote_events (created_at);
+
+-- Событие из журнала ставится в очередь вебхука один раз, сколько бы экземпляров сервиса его ни разослали
+ALTER TABLE webhook_deliveries ADD COLUMN IF NOT EXISTS event_id BIGINT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_webhook_deliveries_event ON webhook_deliveries (webhook_id, event_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_webhook_deliveries_event;
+ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS event_id;
+DROP TABLE IF EXISTS quote_events;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Время последнего изменения цитаты (текст, автор, модерация) для Last-Modified списков
+ALTER TABLE quotes ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE quotes DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Подборки, созданные до появления владельца, может менять только модератор
+ALTER TABLE collections ADD COLUMN IF NOT EXISTS owner_id VARCHAR(255);
+CREATE INDEX IF NOT EXISTS idx_collections_owner_id ON collections (owner_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_collections_owner_id;
+ALTER TABLE collections DROP COLUMN IF EXISTS owner_id;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Номер последнего события журнала, разосланного диспетчерами: после перезапуска всех экземпляров
+-- раздача продолжается с него, и вебхуки получают события, записанные, пока диспетчеры не работали
+CREATE TABLE IF NOT EXISTS quote_events_cursor (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    event_id BIGINT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS quote_events_cursor;
@@ -5,26 +5,31 @@ import (
 	"fmt"
 	"quote-service/pkg/logger"
 
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Postgres struct {
-	DB *pgx.Conn
+	DB *pgxpool.Pool
 }
 
 func NewPostgres(opts Options) (*Postgres, error) {
 	connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 		opts.Host, opts.Port, opts.User, opts.Password, opts.DBName)
-	conn, err := pgx.Connect(context.Background(), connStr)
+	pool, err := pgxpool.New(context.Background(), connStr)
 	if err != nil {
 		logger.Errorf("Ошибка подключения к PostgreSQL: %v", err)
 		return nil, err
 	}
-	return &Postgres{DB: conn}, nil
+	if err := pool.Ping(context.Background()); err != nil {
+		pool.Close()
+		logger.Errorf("Ошибка подключения к PostgreSQL: %v", err)
+		return nil, err
+	}
+	return &Postgres{DB: pool}, nil
 }
 
 func (p *Postgres) Close() {
 	if p.DB != nil {
-		p.DB.Close(context.Background())
+		p.DB.Close()
 	}
 }
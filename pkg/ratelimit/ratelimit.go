@@ -0,0 +1,136 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval - как часто из памяти удаляются полностью восстановившиеся корзины
+const sweepInterval = time.Minute
+
+// Config - ограничение для одного класса запросов. Rate = 0 отключает ограничение частоты,
+// DailyQuota = 0 - суточную квоту.
+type Config struct {
+	Rate       float64 // запросов в секунду
+	Burst      int     // сколько запросов можно сделать подряд
+	DailyQuota int     // запросов в сутки (UTC)
+}
+
+// Result описывает состояние корзины клиента после запроса
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration // через сколько корзина наполнится полностью
+	RetryAfter time.Duration // через сколько появится следующий токен, если запрос отклонен
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter - набор корзин токенов по ключу клиента
+type Limiter struct {
+	rate  float64
+	burst int
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewLimiter создает набор корзин, rate должен быть больше нуля
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &Limiter{
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow списывает токен из корзины клиента, если он есть
+func (l *Limiter) Allow(key string, now time.Time) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(l.burst), b.tokens+elapsed*l.rate)
+		b.last = now
+	}
+
+	res := Result{Limit: l.burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.duration(1 - b.tokens)
+	}
+	res.Remaining = int(b.tokens)
+	res.Reset = l.duration(float64(l.burst) - b.tokens)
+	return res
+}
+
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep удаляет корзины, которые успели наполниться: новая корзина создается полной, так что состояние не теряется
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Quota считает запросы клиентов за текущие сутки по UTC
+type Quota struct {
+	limit int
+
+	mu   sync.Mutex
+	day  string
+	used map[string]int
+}
+
+func NewQuota(limit int) *Quota {
+	return &Quota{limit: limit, used: make(map[string]int)}
+}
+
+// Use учитывает запрос клиента. Возвращает остаток квоты, время до ее сброса и false, если квота исчерпана.
+func (q *Quota) Use(key string, now time.Time) (remaining int, reset time.Duration, ok bool) {
+	now = now.UTC()
+	day := now.Format("2006-01-02")
+	reset = now.Truncate(24 * time.Hour).Add(24 * time.Hour).Sub(now)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if day != q.day {
+		q.day = day
+		q.used = make(map[string]int)
+	}
+	if q.used[key] >= q.limit {
+		return 0, reset, false
+	}
+	q.used[key]++
+	return q.limit - q.used[key], reset, true
+}
+
+func (q *Quota) Limit() int {
+	return q.limit
+}
@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter(1, 2)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	assert.True(t, l.Allow("a", now).Allowed)
+	res := l.Allow("a", now)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, 2*time.Second, res.Reset)
+
+	res = l.Allow("a", now)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, time.Second, res.RetryAfter)
+
+	assert.True(t, l.Allow("b", now).Allowed, "у каждого клиента своя корзина")
+	assert.True(t, l.Allow("a", now.Add(time.Second)).Allowed, "за секунду добавляется токен")
+}
+
+func TestQuota(t *testing.T) {
+	q := NewQuota(2)
+	now := time.Date(2024, 6, 1, 23, 0, 0, 0, time.UTC)
+
+	remaining, _, ok := q.Use("a", now)
+	assert.True(t, ok)
+	assert.Equal(t, 1, remaining)
+	_, _, ok = q.Use("a", now)
+	assert.True(t, ok)
+
+	_, reset, ok := q.Use("a", now)
+	assert.False(t, ok)
+	assert.Equal(t, time.Hour, reset)
+
+	_, _, ok = q.Use("a", now.Add(time.Hour))
+	assert.True(t, ok, "квота сбрасывается в полночь UTC")
+}
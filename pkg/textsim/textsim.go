@@ -0,0 +1,122 @@
+package textsim
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalize приводит текст к каноническому виду для сравнения: NFKC, нижний регистр,
+// пунктуация и пробельные символы схлопываются в одиночные пробелы
+func Normalize(s string) string {
+	s = norm.NFKC.String(s)
+	var b strings.Builder
+	b.Grow(len(s))
+	space := true
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			b.WriteRune(unicode.ToLower(r))
+			space = false
+			continue
+		}
+		if !space {
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// Trigrams возвращает множество символьных триграмм нормализованного текста
+func Trigrams(s string) map[string]struct{} {
+	runes := []rune(" " + Normalize(s) + " ")
+	grams := make(map[string]struct{}, len(runes))
+	for i := 0; i+3 <= len(runes); i++ {
+		grams[string(runes[i:i+3])] = struct{}{}
+	}
+	return grams
+}
+
+// Jaccard - доля общих элементов двух множеств, от 0 до 1
+func Jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	common := 0
+	for g := range a {
+		if _, ok := b[g]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// Similarity - триграммное сходство двух текстов после нормализации
+func Similarity(a, b string) float64 {
+	return Jaccard(Trigrams(a), Trigrams(b))
+}
+
+// stopWords - частые слова, не несущие смысла для сравнения текстов
+var stopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {}, "but": {}, "by": {},
+	"for": {}, "from": {}, "if": {}, "in": {}, "into": {}, "is": {}, "it": {}, "its": {}, "of": {},
+	"on": {}, "or": {}, "so": {}, "that": {}, "the": {}, "their": {}, "then": {}, "there": {},
+	"this": {}, "to": {}, "was": {}, "were": {}, "will": {}, "with": {}, "you": {}, "your": {},
+	"и": {}, "в": {}, "во": {}, "не": {}, "на": {}, "что": {}, "он": {}, "она": {}, "оно": {},
+	"с": {}, "со": {}, "как": {}, "а": {}, "то": {}, "все": {}, "так": {}, "его": {}, "но": {},
+	"да": {}, "ты": {}, "к": {}, "у": {}, "же": {}, "вы": {}, "за": {}, "бы": {}, "по": {},
+	"от": {}, "из": {}, "о": {}, "ли": {}, "если": {}, "или": {}, "это": {}, "для": {},
+}
+
+// MaxTermLength - самое длинное слово в символах, которое учитывается при сравнении. Более длинные
+// "слова" - это ссылки, хэши и т. п., а в индексе слова ограничены VARCHAR(255).
+const MaxTermLength = 64
+
+// Terms возвращает частоты значимых слов текста: после нормализации, без стоп-слов, однобуквенных
+// и длиннее MaxTermLength
+func Terms(s string) map[string]int {
+	terms := make(map[string]int)
+	for _, word := range strings.Fields(Normalize(s)) {
+		if n := len([]rune(word)); n < 2 || n > MaxTermLength {
+			continue
+		}
+		if _, ok := stopWords[word]; ok {
+			continue
+		}
+		terms[word]++
+	}
+	return terms
+}
+
+// Weights переводит частоты слов в веса TF-IDF. df - в скольких документах встречается слово, total - всего документов
+func Weights(terms map[string]int, df map[string]int, total int) map[string]float64 {
+	weights := make(map[string]float64, len(terms))
+	for term, freq := range terms {
+		idf := math.Log(float64(1+total)/float64(1+df[term])) + 1
+		weights[term] = (1 + math.Log(float64(freq))) * idf
+	}
+	return weights
+}
+
+// Cosine - косинусное сходство двух взвешенных векторов слов
+func Cosine(a, b map[string]float64) float64 {
+	var dot, normA, normB float64
+	for term, w := range a {
+		normA += w * w
+		if v, ok := b[term]; ok {
+			dot += w * v
+		}
+	}
+	for _, w := range b {
+		normB += w * w
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,36 @@
+package textsim
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "be yourself everyone else is already taken", Normalize("  Be yourself;  everyone else is ALREADY taken.  "))
+	assert.Equal(t, "fi", Normalize("ﬁ"))
+	assert.Equal(t, "", Normalize("...!"))
+}
+
+func TestSimilarity(t *testing.T) {
+	assert.Equal(t, 1.0, Similarity("Be yourself; everyone else is already taken.", "be yourself, everyone else is already taken "))
+	assert.Greater(t, Similarity("Be yourself; everyone else is already taken.", "Be yourself, everybody else is already taken"), 0.6)
+	assert.Less(t, Similarity("Know thyself", "Life is simple"), 0.2)
+}
+
+func TestTerms(t *testing.T) {
+	assert.Equal(t, map[string]int{"life": 1, "simple": 1, "really": 2}, Terms("Life is really, REALLY simple."))
+	assert.Empty(t, Terms("a the и в"))
+
+	long := strings.Repeat("я", 300)
+	assert.Equal(t, map[string]int{"life": 1}, Terms("life "+long))
+	assert.Len(t, Terms(strings.Repeat("x", MaxTermLength)), 1)
+}
+
+func TestCosine(t *testing.T) {
+	a := map[string]float64{"life": 1, "simple": 1}
+	assert.InDelta(t, 1.0, Cosine(a, a), 1e-9)
+	assert.Equal(t, 0.0, Cosine(a, map[string]float64{"know": 1}))
+	assert.Equal(t, 0.0, Cosine(a, nil))
+}